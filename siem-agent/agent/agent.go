@@ -3,11 +3,13 @@ package agent
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"sync"
 	"time"
 
 	"agent/buffer"
+	"agent/checkpoint"
 	"agent/collector"
 	"agent/processor"
 	"agent/sender"
@@ -19,6 +21,9 @@ type Agent struct {
 	collectors  []collector.Collector
 	buffer      buffer.Buffer
 	processor   processor.Processor
+	// outbox обработанные события, ожидающие отправки. Отдельная очередь,
+	// чтобы событие не попадало в обработчик повторно
+	outbox      buffer.Buffer
 	sender      sender.Sender
 
 	ctx        context.Context
@@ -26,6 +31,28 @@ type Agent struct {
 	wg         sync.WaitGroup
 	mu         sync.RWMutex
 	running    bool
+
+	// senderMu не даёт заменить отправителя во время отправки
+	senderMu sync.Mutex
+
+	// Источники в режиме наблюдения, см. watch.go
+	watched map[collector.Collector]watchHandle
+
+	// pollMu удерживается на время прохода collectLogs, чтобы снятый с
+	// регистрации сборщик не опрашивался после возврата из detach
+	pollMu sync.Mutex
+
+	// Сигналы циклам о смене интервалов
+	collectionReset chan struct{}
+	senderReset     chan struct{}
+
+	// Позиции чтения источников, см. checkpoints.go
+	checkpoints      *checkpoint.Store
+	checkpointMu     sync.Mutex
+	lastSeq          uint64
+	ackedSeq         uint64
+	acked            map[uint64]bool
+	pendingPositions []pendingPosition
 }
 
 // Config конфигурация агента
@@ -48,18 +75,124 @@ func NewAgent(config Config, bufferInstance buffer.Buffer, processorInstance pro
 		collectors:   make([]collector.Collector, 0),
 		buffer:      bufferInstance,
 		processor:   processorInstance,
+		outbox:      buffer.NewRingBuffer(config.BufferMaxSize),
 		sender:      senderInstance,
 		ctx:         ctx,
 		cancel:      cancel,
+
+		watched:         make(map[collector.Collector]watchHandle),
+		collectionReset: make(chan struct{}, 1),
+		senderReset:     make(chan struct{}, 1),
 	}
 }
 
 // RegisterCollector регистрирует источник логов
 func (a *Agent) RegisterCollector(col collector.Collector) {
+	a.restorePosition(col)
+	a.poll(col)
+}
+
+// ReattachCollector регистрирует снятый DetachCollector сборщик в режиме
+// наблюдения или опроса. Позиция не восстанавливается: сборщик продолжает
+// с того места, где остановился, а не с последней сохранённой позиции
+func (a *Agent) ReattachCollector(col collector.Collector, watch bool) {
+	if watch {
+		a.watch(col)
+	} else {
+		a.poll(col)
+	}
+}
+
+// poll добавляет источник в список опрашиваемых по таймеру
+func (a *Agent) poll(col collector.Collector) {
+	a.mu.Lock()
 	a.collectors = append(a.collectors, col)
+	a.mu.Unlock()
 	log.Printf("[Agent] Collector registered: %s (%s)", col.GetSourceName(), col.GetSourceType())
 }
 
+// UnregisterCollector удаляет источник логов. Уже собранные события остаются в буфере
+func (a *Agent) UnregisterCollector(col collector.Collector) {
+	a.detach(col)
+
+	// Сборщики с фоновыми процессами и соединениями освобождают ресурсы
+	closeCollector(col)
+}
+
+// DetachCollector удаляет источник, не закрывая его, чтобы зарегистрировать
+// тот же сборщик в другом режиме. Возвращается, когда сбор из источника
+// завершён: одновременных вызовов Collect не будет
+func (a *Agent) DetachCollector(col collector.Collector) {
+	a.detach(col)
+}
+
+// detach снимает источник с регистрации и дожидается окончания текущего сбора
+func (a *Agent) detach(col collector.Collector) {
+	a.mu.Lock()
+	handle, watched := a.watched[col]
+	found := watched
+	if watched {
+		handle.cancel()
+		delete(a.watched, col)
+	} else {
+		for i, registered := range a.collectors {
+			if registered == col {
+				a.collectors = append(a.collectors[:i:i], a.collectors[i+1:]...)
+				found = true
+				break
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	if !found {
+		return
+	}
+	if watched {
+		<-handle.done
+	} else {
+		a.pollMu.Lock()
+		a.pollMu.Unlock()
+	}
+	log.Printf("[Agent] Collector unregistered: %s (%s)", col.GetSourceName(), col.GetSourceType())
+}
+
+// Reconfigure применяет новые настройки к работающему агенту
+func (a *Agent) Reconfigure(config Config) {
+	a.mu.Lock()
+	old := a.config
+	// Размер буфера задаётся при создании и не меняется на лету
+	if config.BufferMaxSize != old.BufferMaxSize {
+		log.Printf("[Agent] Buffer size change (%d -> %d) requires restart, ignoring", old.BufferMaxSize, config.BufferMaxSize)
+		config.BufferMaxSize = old.BufferMaxSize
+	}
+	a.config = config
+	a.mu.Unlock()
+
+	if config.CollectionInterval != old.CollectionInterval {
+		notify(a.collectionReset)
+	}
+	if config.SenderInterval != old.SenderInterval {
+		notify(a.senderReset)
+	}
+
+	log.Printf("[Agent] Configuration updated: collection %d ms, send %d ms, batch %d",
+		config.CollectionInterval, config.SenderInterval, config.BatchSize)
+}
+
+// SetSender заменяет отправителя. Старый отправитель закрывается после завершения текущей отправки
+func (a *Agent) SetSender(senderInstance sender.Sender) {
+	a.senderMu.Lock()
+	old := a.sender
+	a.sender = senderInstance
+	a.senderMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	log.Println("[Agent] Sender replaced")
+}
+
 // Start запускает агент
 func (a *Agent) Start() error {
 	a.mu.Lock()
@@ -99,8 +232,23 @@ func (a *Agent) Stop() {
 	a.cancel()
 	a.wg.Wait()
 
+	// Освобождаем ресурсы сборщиков
+	a.mu.RLock()
+	for _, col := range a.collectors {
+		closeCollector(col)
+	}
+	for col := range a.watched {
+		closeCollector(col)
+	}
+	a.mu.RUnlock()
+
 	// Закрываем соединение с сервером
+	a.senderMu.Lock()
 	a.sender.Close()
+	a.senderMu.Unlock()
+
+	// Сохраняем подтверждённые позиции чтения
+	a.flushCheckpoints()
 
 	log.Println("[Agent] Agent stopped")
 }
@@ -112,16 +260,16 @@ func (a *Agent) IsRunning() bool {
 	return a.running
 }
 
-// GetBufferSize возвращает текущий размер буфера
+// GetBufferSize возвращает число событий, ожидающих обработки и отправки
 func (a *Agent) GetBufferSize() int {
-	return a.buffer.Size()
+	return a.buffer.Size() + a.outbox.Size()
 }
 
 // collectorLoop основной цикл сборщика
 func (a *Agent) collectorLoop() {
 	defer a.wg.Done()
 
-	ticker := time.NewTicker(time.Duration(a.config.CollectionInterval) * time.Millisecond)
+	ticker := time.NewTicker(time.Duration(a.currentConfig().CollectionInterval) * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
@@ -129,6 +277,8 @@ func (a *Agent) collectorLoop() {
 		case <-a.ctx.Done():
 			log.Println("[Collector] Stopping collector loop")
 			return
+		case <-a.collectionReset:
+			ticker.Reset(time.Duration(a.currentConfig().CollectionInterval) * time.Millisecond)
 		case <-ticker.C:
 			a.collectLogs()
 		}
@@ -137,22 +287,39 @@ func (a *Agent) collectorLoop() {
 
 // collectLogs собирает логи из всех источников
 func (a *Agent) collectLogs() {
-	for _, col := range a.collectors {
-		events, err := col. Collect()
-		if err != nil {
-			log.Printf("[Collector] Error collecting from %s: %v", col. GetSourceName(), err)
-			continue
-		}
+	a.pollMu.Lock()
+	defer a.pollMu.Unlock()
 
-		if len(events) > 0 {
-			// Конвертируем в buffer. Event
-			bufferEvents := convertEvents(events)
-			a.buffer.Push(bufferEvents)
-			log.Printf("[Collector] Collected %d events from %s", len(events), col.GetSourceName())
-		}
+	a.mu.RLock()
+	collectors := make([]collector.Collector, len(a.collectors))
+	copy(collectors, a.collectors)
+	a.mu.RUnlock()
+
+	for _, col := range collectors {
+		a.collectFrom(col)
 	}
 }
 
+// collectFrom собирает события одного источника и кладёт их в буфер
+func (a *Agent) collectFrom(col collector.Collector) {
+	events, err := col. Collect()
+	if err != nil {
+		log.Printf("[Collector] Error collecting from %s: %v", col. GetSourceName(), err)
+	}
+
+	// События, прочитанные до ошибки, тоже отправляем: позиция уже сдвинута
+	var seq uint64
+	if len(events) > 0 {
+		// Конвертируем в buffer. Event
+		bufferEvents := convertEvents(events)
+		seq = a.numberEvents(bufferEvents)
+		a.buffer.Push(bufferEvents)
+		log.Printf("[Collector] Collected %d events from %s", len(events), col.GetSourceName())
+	}
+
+	a.trackPosition(col, seq)
+}
+
 // processorLoop основной цикл обработчика
 func (a *Agent) processorLoop() {
 	defer a.wg.Done()
@@ -163,26 +330,38 @@ func (a *Agent) processorLoop() {
 			log.Println("[Processor] Stopping processor loop")
 			return
 		default:
-			// Извлекаем из буфера
-			events := a.buffer.Pop(a.config.BatchSize)
-			if len(events) == 0 {
-				time.Sleep(100 * time. Millisecond)
+			// Pop ждёт появления событий, поэтому пустой буфер проверяем заранее:
+			// иначе цикл не заметит остановки агента
+			if a.buffer.IsEmpty() {
+				time.Sleep(100 * time.Millisecond)
 				continue
 			}
+			a.processEvents()
+		}
+	}
+}
 
-			// Конвертируем в processor.Event
-			procEvents := convertBufferToProcessor(events)
+// processEvents обрабатывает пакет событий из буфера и передаёт результат
+// в очередь отправки
+func (a *Agent) processEvents() {
+	// Извлекаем из буфера
+	events := a.buffer.Pop(a.currentConfig().BatchSize)
 
-			// Обрабатываем
-			processedEvents := a.processor.ProcessBatch(procEvents)
+	// Конвертируем в processor.Event
+	procEvents := convertBufferToProcessor(events)
 
-			if len(processedEvents) > 0 {
-				// Конвертируем обратно в buffer.Event
-				bufferEvents := convertProcessorToBuffer(processedEvents)
-				a.buffer.Push(bufferEvents)
-				log.Printf("[Processor] Processed %d events", len(processedEvents))
-			}
-		}
+	// Обрабатываем
+	processedEvents := a.processor.ProcessBatch(procEvents)
+
+	// Конвертируем обратно в buffer.Event
+	bufferEvents := convertProcessorToBuffer(processedEvents)
+
+	// Отфильтрованные события считаем обработанными для позиций чтения
+	a.acknowledge(droppedSeqs(events, bufferEvents))
+
+	if len(bufferEvents) > 0 {
+		a.outbox.Push(bufferEvents)
+		log.Printf("[Processor] Processed %d events", len(bufferEvents))
 	}
 }
 
@@ -190,7 +369,7 @@ func (a *Agent) processorLoop() {
 func (a *Agent) senderLoop() {
 	defer a.wg.Done()
 
-	ticker := time.NewTicker(time.Duration(a.config.SenderInterval) * time.Millisecond)
+	ticker := time.NewTicker(time.Duration(a.currentConfig().SenderInterval) * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
@@ -198,6 +377,8 @@ func (a *Agent) senderLoop() {
 		case <-a.ctx.Done():
 			log.Println("[Sender] Stopping sender loop")
 			return
+		case <-a.senderReset:
+			ticker.Reset(time.Duration(a.currentConfig().SenderInterval) * time.Millisecond)
 		case <-ticker.C: 
 			a.sendEvents()
 		}
@@ -206,25 +387,53 @@ func (a *Agent) senderLoop() {
 
 // sendEvents отправляет события на сервер
 func (a *Agent) sendEvents() {
+	a.senderMu.Lock()
+	defer a.senderMu.Unlock()
+
 	if !a.sender.IsConnected() {
 		log.Println("[Sender] Server is not connected")
 		return
 	}
 
-	events := a.buffer.Pop(a.config.BatchSize)
-	if len(events) == 0 {
+	if a.outbox.IsEmpty() {
 		return
 	}
+	events := a.outbox.Pop(a.currentConfig().BatchSize)
 
 	// Конвертируем в sender.Event
 	senderEvents := convertBufferToSender(events)
 
 	if err := a.sender.Send(senderEvents); err != nil {
 		log.Printf("[Sender] Error sending events: %v", err)
-		// Возвращаем в буфер при ошибке
-		a. buffer.Push(events)
+		// Возвращаем в очередь отправки при ошибке
+		a.outbox.Push(events)
 	} else {
 		log.Printf("[Sender] Sent %d events to server", len(senderEvents))
+		a.acknowledge(eventSeqs(events))
+	}
+}
+
+// currentConfig возвращает текущую конфигурацию агента
+func (a *Agent) currentConfig() Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config
+}
+
+// closeCollector закрывает сборщик, если он держит ресурсы
+func closeCollector(col collector.Collector) {
+	if closer, ok := col.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("[Agent] Error closing collector %s: %v", col.GetSourceName(), err)
+		}
+	}
+}
+
+// notify неблокирующе сигнализирует в канал
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
 	}
 }
 
@@ -244,6 +453,7 @@ func convertEvents(events []collector.Event) []buffer.Event {
 			Process:   e.Process,
 			Command:   e.Command,
 			RawLog:    e.RawLog,
+			Fields:    e.Fields,
 		}
 	}
 	return result
@@ -263,6 +473,8 @@ func convertBufferToProcessor(events []buffer.Event) []processor.Event {
 			Process:   e.Process,
 			Command:   e.Command,
 			RawLog:    e.RawLog,
+			Fields:    e.Fields,
+			Seq:       e.Seq,
 		}
 	}
 	return result
@@ -282,6 +494,9 @@ func convertProcessorToBuffer(events []processor.Event) []buffer.Event {
 			Process:   e.Process,
 			Command:   e.Command,
 			RawLog:    e.RawLog,
+			Fields:    e.Fields,
+			RuleHits:  convertProcessorHits(e.RuleHits),
+			Seq:       e.Seq,
 		}
 	}
 	return result
@@ -301,7 +516,33 @@ func convertBufferToSender(events []buffer.Event) []sender.Event {
 			Process:   e.Process,
 			Command:   e.Command,
 			RawLog:    e. RawLog,
+			Fields:    e.Fields,
+			RuleHits:  convertBufferHits(e.RuleHits),
 		}
 	}
 	return result
+}
+
+// convertProcessorHits конвертирует processor.RuleHit в buffer.RuleHit
+func convertProcessorHits(hits []processor.RuleHit) []buffer.RuleHit {
+	if len(hits) == 0 {
+		return nil
+	}
+	result := make([]buffer.RuleHit, len(hits))
+	for i, h := range hits {
+		result[i] = buffer.RuleHit(h)
+	}
+	return result
+}
+
+// convertBufferHits конвертирует buffer.RuleHit в sender.RuleHit
+func convertBufferHits(hits []buffer.RuleHit) []sender.RuleHit {
+	if len(hits) == 0 {
+		return nil
+	}
+	result := make([]sender.RuleHit, len(hits))
+	for i, h := range hits {
+		result[i] = sender.RuleHit(h)
+	}
+	return result
 }
\ No newline at end of file
@@ -0,0 +1,416 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"agent/buffer"
+	"agent/checkpoint"
+	"agent/collector"
+	"agent/processor"
+	"agent/sender"
+)
+
+// fakeCollector отдаёт заданные события и сдвигает позицию на их число
+type fakeCollector struct {
+	events    []collector.Event
+	offset    int64
+	committed []int64
+}
+
+func (fc *fakeCollector) Collect() ([]collector.Event, error) {
+	events := fc.events
+	fc.events = nil
+	fc.offset += int64(len(events))
+	return events, nil
+}
+
+func (fc *fakeCollector) Position() checkpoint.Position {
+	return checkpoint.Position{Path: "/var/log/auth.log", Offset: fc.offset}
+}
+
+func (fc *fakeCollector) Restore(pos checkpoint.Position) { fc.offset = pos.Offset }
+func (fc *fakeCollector) Committed(pos checkpoint.Position) {
+	fc.committed = append(fc.committed, pos.Offset)
+}
+func (fc *fakeCollector) GetSourceName() string { return "authlog" }
+func (fc *fakeCollector) GetSourceType() string { return "auth" }
+
+// fakeSender запоминает отправленные события
+type fakeSender struct {
+	sent   []sender.Event
+	fail   bool
+	closed bool
+}
+
+func (fs *fakeSender) Send(events []sender.Event) error {
+	if fs.fail {
+		return fmt.Errorf("server unavailable")
+	}
+	fs.sent = append(fs.sent, events...)
+	return nil
+}
+
+func (fs *fakeSender) IsConnected() bool { return true }
+func (fs *fakeSender) Close() error      { fs.closed = true; return nil }
+
+func newTestAgent(t *testing.T, snd sender.Sender) (*Agent, *checkpoint.Store) {
+	t.Helper()
+	return newTestAgentWithState(t, snd, t.TempDir())
+}
+
+func newTestAgentWithState(t *testing.T, snd sender.Sender, stateDir string) (*Agent, *checkpoint.Store) {
+	t.Helper()
+	store, err := checkpoint.Open(stateDir)
+	if err != nil {
+		t.Fatalf("checkpoint.Open: %v", err)
+	}
+	config := Config{BatchSize: 100, BufferMaxSize: 1000, CollectionInterval: 1000, SenderInterval: 1000}
+	a := NewAgent(config, buffer.NewRingBuffer(config.BufferMaxSize), processor.NewLogProcessor(), snd)
+	a.SetCheckpointStore(store)
+	return a, store
+}
+
+func sshFailures(count int) []collector.Event {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]collector.Event, count)
+	for i := range events {
+		events[i] = collector.Event{
+			Timestamp: start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano),
+			Source:    "authlog",
+			EventType: "ssh_login_failed",
+			Severity:  "WARNING",
+			RawLog:    "Failed password for root from 203.0.113.7 port 22 ssh2",
+			Fields:    map[string]string{"src_ip": "203.0.113.7"},
+		}
+	}
+	return events
+}
+
+func TestProcessedEventsAreNotReprocessed(t *testing.T) {
+	snd := &fakeSender{}
+	a, _ := newTestAgent(t, snd)
+	col := &fakeCollector{events: sshFailures(1)}
+	a.collectFrom(col)
+
+	// Обработанное событие уходит в очередь отправки, а не обратно в буфер
+	a.processEvents()
+	if !a.buffer.IsEmpty() {
+		t.Fatalf("buffer has %d events after processing, want 0", a.buffer.Size())
+	}
+	if a.outbox.Size() != 1 {
+		t.Fatalf("outbox has %d events, want 1", a.outbox.Size())
+	}
+
+	a.sendEvents()
+	if len(snd.sent) != 1 {
+		t.Fatalf("sent %d events, want 1", len(snd.sent))
+	}
+	if hits := len(snd.sent[0].RuleHits); hits > 1 {
+		t.Errorf("event has %d rule hits", hits)
+	}
+}
+
+func TestCorrelationAlertsAreNotAcknowledged(t *testing.T) {
+	snd := &fakeSender{fail: true}
+	a, store := newTestAgent(t, snd)
+
+	// Одна из 11 попыток отбрасывается фильтром: пустое сообщение
+	events := sshFailures(10)
+	events = append(events, collector.Event{Source: "authlog", RawLog: "   "})
+	col := &fakeCollector{events: events}
+	a.collectFrom(col)
+	a.processEvents()
+
+	if a.outbox.Size() != 11 {
+		t.Fatalf("outbox has %d events, want 10 + 1 alert", a.outbox.Size())
+	}
+	// Отфильтрованное событие собрано последним: пока не отправлены
+	// предыдущие, подтверждённых событий нет
+	if a.ackedSeq != 0 {
+		t.Fatalf("acked up to %d after processing, want 0", a.ackedSeq)
+	}
+
+	// Пока отправка не удалась, позиция не сохраняется
+	a.sendEvents()
+	if _, ok := store.Get("authlog"); ok {
+		t.Fatal("position committed before events were sent")
+	}
+
+	snd.fail = false
+	a.sendEvents()
+	if a.ackedSeq != 11 {
+		t.Fatalf("acked up to %d, want 11 (alert is not counted)", a.ackedSeq)
+	}
+	if pos, ok := store.Get("authlog"); !ok || pos.Offset != 11 {
+		t.Fatalf("committed position = %+v, %v; want offset 11", pos, ok)
+	}
+
+	alerts := 0
+	for _, event := range snd.sent {
+		if event.Source == processor.CorrelationSource {
+			alerts++
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("sent %d correlation alerts, want 1", alerts)
+	}
+}
+
+func TestRepeatedProcessingDoesNotFireCorrelation(t *testing.T) {
+	snd := &fakeSender{fail: true}
+	a, _ := newTestAgent(t, snd)
+	a.collectFrom(&fakeCollector{events: sshFailures(1)})
+
+	// Сервер недоступен: событие остаётся в очереди отправки, но не
+	// проходит через обработчик снова
+	for i := 0; i < 20; i++ {
+		if !a.buffer.IsEmpty() {
+			a.processEvents()
+		}
+		a.sendEvents()
+	}
+	if a.outbox.Size() != 1 {
+		t.Fatalf("outbox has %d events, want 1", a.outbox.Size())
+	}
+}
+
+func TestUnchangedPositionsAreNotQueued(t *testing.T) {
+	snd := &fakeSender{fail: true}
+	a, store := newTestAgent(t, snd)
+	col := &fakeCollector{events: sshFailures(1)}
+
+	// Сервер недоступен, новых событий нет: очередь позиций не растёт
+	for i := 0; i < 50; i++ {
+		a.collectFrom(col)
+	}
+	if len(a.pendingPositions) != 1 {
+		t.Fatalf("%d pending positions, want 1", len(a.pendingPositions))
+	}
+
+	snd.fail = false
+	a.processEvents()
+	a.sendEvents()
+	if pos, ok := store.Get("authlog"); !ok || pos.Offset != 1 {
+		t.Fatalf("committed position = %+v, %v; want offset 1", pos, ok)
+	}
+
+	// Сохранённая позиция повторно в очередь не попадает
+	a.collectFrom(col)
+	if len(a.pendingPositions) != 0 {
+		t.Errorf("%d pending positions after commit, want 0", len(a.pendingPositions))
+	}
+}
+
+// blankEvents события, которые отбросит фильтр пустых сообщений
+func blankEvents(count int) []collector.Event {
+	events := make([]collector.Event, count)
+	for i := range events {
+		events[i] = collector.Event{Source: "authlog", RawLog: " "}
+	}
+	return events
+}
+
+func TestCommitStopsAtOldestUndeliveredEvent(t *testing.T) {
+	snd := &fakeSender{}
+	a, store := newTestAgent(t, snd)
+	a.config.BatchSize = 2
+	col := &fakeCollector{events: sshFailures(1)}
+	a.collectFrom(col)
+	a.processEvents()
+	a.sendEvents()
+
+	// Два события ждут отправки, следующие два отфильтрованы
+	col.events = sshFailures(2)
+	a.collectFrom(col)
+	a.processEvents()
+	col.events = blankEvents(2)
+	a.collectFrom(col)
+	a.processEvents()
+
+	col.events = sshFailures(2)
+	a.collectFrom(col)
+	a.processEvents()
+
+	// Отправка не удалась: пакет вернулся в конец очереди, за более новыми
+	snd.fail = true
+	a.sendEvents()
+
+	snd.fail = false
+	a.sendEvents()
+	if pos, ok := store.Get("authlog"); !ok || pos.Offset != 1 {
+		t.Fatalf("committed position = %+v, %v; want offset 1 (events 2-3 not sent)", pos, ok)
+	}
+
+	a.sendEvents()
+	if pos, ok := store.Get("authlog"); !ok || pos.Offset != 7 {
+		t.Fatalf("committed position = %+v, %v; want offset 7", pos, ok)
+	}
+}
+
+func TestCommitterNotifiedAfterDelivery(t *testing.T) {
+	snd := &fakeSender{fail: true}
+	a, _ := newTestAgent(t, snd)
+	col := &fakeCollector{events: sshFailures(2)}
+	a.collectFrom(col)
+	a.processEvents()
+	a.sendEvents()
+	if len(col.committed) != 0 {
+		t.Fatalf("collector notified before delivery: %v", col.committed)
+	}
+
+	snd.fail = false
+	a.sendEvents()
+	if len(col.committed) != 1 || col.committed[0] != 2 {
+		t.Fatalf("committed = %v, want [2]", col.committed)
+	}
+}
+
+// fakeMultiCollector читает несколько файлов; files — позиции файлов, которые ещё есть
+type fakeMultiCollector struct {
+	events []collector.Event
+	files  map[string]int64
+}
+
+func (fc *fakeMultiCollector) Collect() ([]collector.Event, error) {
+	events := fc.events
+	fc.events = nil
+	return events, nil
+}
+
+func (fc *fakeMultiCollector) Positions() map[string]checkpoint.Position {
+	positions := make(map[string]checkpoint.Position)
+	for path, offset := range fc.files {
+		positions[path] = checkpoint.Position{Path: path, Offset: offset}
+	}
+	return positions
+}
+
+func (fc *fakeMultiCollector) RestorePositions(map[string]checkpoint.Position) {}
+func (fc *fakeMultiCollector) GetSourceName() string                           { return "docker" }
+func (fc *fakeMultiCollector) GetSourceType() string                           { return "container" }
+
+func TestRemovedFilePositionIsDeleted(t *testing.T) {
+	snd := &fakeSender{}
+	stateDir := t.TempDir()
+	a, store := newTestAgentWithState(t, snd, stateDir)
+	col := &fakeMultiCollector{
+		events: sshFailures(1),
+		files:  map[string]int64{"/containers/a.log": 100, "/containers/b.log": 200},
+	}
+	a.collectFrom(col)
+	a.processEvents()
+	a.sendEvents()
+	if len(store.Prefixed("docker:")) != 2 {
+		t.Fatalf("saved positions = %v, want 2", store.Prefixed("docker:"))
+	}
+
+	// Контейнер удалён, но события, собранные до этого, ещё не отправлены
+	snd.fail = true
+	col.events = sshFailures(1)
+	col.files = map[string]int64{"/containers/a.log": 150}
+	a.collectFrom(col)
+	if _, ok := store.Get("docker:/containers/b.log"); !ok {
+		t.Fatal("position removed before pending events were sent")
+	}
+
+	snd.fail = false
+	a.processEvents()
+	a.sendEvents()
+	positions := store.Prefixed("docker:")
+	if len(positions) != 1 || positions["/containers/a.log"].Offset != 150 {
+		t.Fatalf("saved positions = %v, want only a.log at 150", positions)
+	}
+
+	// Удаление записано на диск
+	reopened, err := checkpoint.Open(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("docker:/containers/b.log"); ok {
+		t.Error("removed position is still on disk")
+	}
+}
+
+func TestReconfigureAppliesToRunningLoops(t *testing.T) {
+	snd := &fakeSender{}
+	a, _ := newTestAgent(t, snd)
+	col := &fakeCollector{events: sshFailures(5)}
+	a.collectFrom(col)
+	a.processEvents()
+
+	config := a.currentConfig()
+	config.BatchSize = 2
+	config.BufferMaxSize = 10
+	config.SenderInterval = 500
+	a.Reconfigure(config)
+
+	// Размер буфера не меняется на лету
+	if got := a.currentConfig().BufferMaxSize; got != 1000 {
+		t.Errorf("buffer size = %d after reconfigure, want 1000", got)
+	}
+	// Цикл отправки перезапускает таймер, цикл сбора — нет
+	select {
+	case <-a.senderReset:
+	default:
+		t.Error("sender loop not notified about the new interval")
+	}
+	select {
+	case <-a.collectionReset:
+		t.Error("collector loop notified though its interval did not change")
+	default:
+	}
+
+	a.sendEvents()
+	if len(snd.sent) != 2 {
+		t.Fatalf("sent %d events, want the new batch size 2", len(snd.sent))
+	}
+}
+
+func TestSetSenderClosesPrevious(t *testing.T) {
+	old := &fakeSender{fail: true}
+	a, store := newTestAgent(t, old)
+	col := &fakeCollector{events: sshFailures(2)}
+	a.collectFrom(col)
+	a.processEvents()
+	a.sendEvents()
+
+	// События, не принятые прежним сервером, уходят новому
+	replacement := &fakeSender{}
+	a.SetSender(replacement)
+	if !old.closed {
+		t.Error("previous sender not closed")
+	}
+	a.sendEvents()
+	if len(old.sent) != 0 || len(replacement.sent) != 2 {
+		t.Fatalf("sent %d via old and %d via new sender, want 0 and 2", len(old.sent), len(replacement.sent))
+	}
+	if pos, ok := store.Get("authlog"); !ok || pos.Offset != 2 {
+		t.Errorf("committed position = %+v, %v; want offset 2", pos, ok)
+	}
+}
+
+func TestUnregisteredCollectorKeepsCollectedEvents(t *testing.T) {
+	snd := &fakeSender{}
+	a, _ := newTestAgent(t, snd)
+	col := &fakeCollector{}
+	a.RegisterCollector(col)
+
+	col.events = sshFailures(2)
+	a.collectLogs()
+	a.UnregisterCollector(col)
+
+	// Новые события источника больше не собираются
+	col.events = sshFailures(3)
+	a.collectLogs()
+	if a.GetBufferSize() != 2 {
+		t.Fatalf("buffer has %d events, want 2 collected before removal", a.GetBufferSize())
+	}
+
+	a.processEvents()
+	a.sendEvents()
+	if len(snd.sent) != 2 {
+		t.Errorf("sent %d events, want 2", len(snd.sent))
+	}
+}
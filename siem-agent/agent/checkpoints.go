@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"log"
+	"strings"
+
+	"agent/buffer"
+	"agent/checkpoint"
+	"agent/collector"
+)
+
+// pendingPosition позиция чтения, которая станет постоянной после отправки
+// всех событий, собранных до неё
+type pendingPosition struct {
+	seq     uint64 // номер последнего события, собранного до позиции
+	source  string
+	pos     checkpoint.Position
+	col     collector.Collector
+	removed bool // файл или контейнер исчез: позицию нужно удалить
+}
+
+// SetCheckpointStore подключает хранилище позиций чтения.
+// Вызывается до регистрации сборщиков, чтобы они продолжили с сохранённых позиций
+func (a *Agent) SetCheckpointStore(store *checkpoint.Store) {
+	a.checkpointMu.Lock()
+	defer a.checkpointMu.Unlock()
+	a.checkpoints = store
+}
+
+// multiPositionKey ключ позиции одного файла сборщика нескольких файлов
+func multiPositionKey(source, key string) string {
+	return source + ":" + key
+}
+
+// restorePosition восстанавливает сохранённую позицию сборщика
+func (a *Agent) restorePosition(col collector.Collector) {
+	a.checkpointMu.Lock()
+	store := a.checkpoints
+	a.checkpointMu.Unlock()
+	if store == nil {
+		return
+	}
+
+	if mcp, ok := col.(collector.MultiCheckpointer); ok {
+		positions := store.Prefixed(multiPositionKey(col.GetSourceName(), ""))
+		if len(positions) > 0 {
+			mcp.RestorePositions(positions)
+			log.Printf("[Agent] Restored %s positions for %d files", col.GetSourceName(), len(positions))
+		}
+		return
+	}
+
+	cp, ok := col.(collector.Checkpointer)
+	if !ok {
+		return
+	}
+
+	if pos, ok := store.Get(col.GetSourceName()); ok {
+		cp.Restore(pos)
+		log.Printf("[Agent] Restored %s position: offset %d", col.GetSourceName(), cp.Position().Offset)
+	}
+}
+
+// numberEvents присваивает собранным событиям номера и возвращает последний
+func (a *Agent) numberEvents(events []buffer.Event) uint64 {
+	a.checkpointMu.Lock()
+	defer a.checkpointMu.Unlock()
+	for i := range events {
+		a.lastSeq++
+		events[i].Seq = a.lastSeq
+	}
+	return a.lastSeq
+}
+
+// trackPosition запоминает позицию сборщика после передачи в буфер событий
+// с номерами до seq (0 — сборщик ничего не прочитал). Позиция сохраняется,
+// только когда все события с номерами до неё отправлены или отфильтрованы
+func (a *Agent) trackPosition(col collector.Collector, seq uint64) {
+	positions := make(map[string]checkpoint.Position)
+	prefix := ""
+	switch cp := col.(type) {
+	case collector.MultiCheckpointer:
+		prefix = multiPositionKey(col.GetSourceName(), "")
+		for key, pos := range cp.Positions() {
+			positions[prefix+key] = pos
+		}
+	case collector.Checkpointer:
+		positions[col.GetSourceName()] = cp.Position()
+	default:
+		return
+	}
+
+	a.checkpointMu.Lock()
+	defer a.checkpointMu.Unlock()
+	if a.checkpoints == nil {
+		return
+	}
+
+	if seq == 0 {
+		seq = a.lastSeq
+	}
+	for source, pos := range positions {
+		a.queuePosition(col, source, pos, seq)
+	}
+	// Файлы, которых сборщик больше не читает, удаляем из хранилища
+	if prefix != "" {
+		for _, source := range a.trackedSources(prefix) {
+			if _, ok := positions[source]; !ok {
+				a.queueRemoval(col, source, seq)
+			}
+		}
+	}
+	a.commitPositions()
+}
+
+// queuePosition добавляет позицию в очередь на сохранение. Неизменившаяся
+// позиция не добавляется, а позиция с тем же номером заменяет прежнюю,
+// чтобы очередь не росла, пока сервер недоступен. Вызывается под checkpointMu
+func (a *Agent) queuePosition(col collector.Collector, source string, pos checkpoint.Position, seq uint64) {
+	latest := a.latestPending(source)
+
+	switch {
+	case latest >= 0 && !a.pendingPositions[latest].removed && a.pendingPositions[latest].pos == pos:
+		return
+	case latest >= 0 && a.pendingPositions[latest].seq == seq:
+		a.pendingPositions[latest].pos = pos
+		a.pendingPositions[latest].removed = false
+		return
+	case latest < 0:
+		if saved, ok := a.checkpoints.Get(source); ok && saved == pos {
+			return
+		}
+	}
+
+	a.pendingPositions = append(a.pendingPositions, pendingPosition{
+		seq:    seq,
+		source: source,
+		pos:    pos,
+		col:    col,
+	})
+}
+
+// queueRemoval ставит в очередь удаление позиции. Позиция удаляется после
+// отправки событий, собранных до этого момента, иначе её восстановила бы
+// ожидающая в очереди запись. Вызывается под checkpointMu
+func (a *Agent) queueRemoval(col collector.Collector, source string, seq uint64) {
+	latest := a.latestPending(source)
+	switch {
+	case latest >= 0 && a.pendingPositions[latest].removed:
+		return
+	case latest >= 0 && a.pendingPositions[latest].seq == seq:
+		a.pendingPositions[latest].removed = true
+		return
+	case latest < 0:
+		if _, ok := a.checkpoints.Get(source); !ok {
+			return
+		}
+	}
+
+	a.pendingPositions = append(a.pendingPositions, pendingPosition{
+		seq:     seq,
+		source:  source,
+		col:     col,
+		removed: true,
+	})
+}
+
+// latestPending номер последней ожидающей записи источника, -1 если её нет
+func (a *Agent) latestPending(source string) int {
+	for i := len(a.pendingPositions) - 1; i >= 0; i-- {
+		if a.pendingPositions[i].source == source {
+			return i
+		}
+	}
+	return -1
+}
+
+// trackedSources ключи с префиксом prefix, сохранённые или ожидающие сохранения
+func (a *Agent) trackedSources(prefix string) []string {
+	seen := make(map[string]bool)
+	var sources []string
+	add := func(source string) {
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	for key := range a.checkpoints.Prefixed(prefix) {
+		add(prefix + key)
+	}
+	for _, pending := range a.pendingPositions {
+		if strings.HasPrefix(pending.source, prefix) {
+			add(pending.source)
+		}
+	}
+	return sources
+}
+
+// acknowledge отмечает события, покинувшие конвейер, и сохраняет ставшие
+// подтверждёнными позиции. События отправляются не по порядку номеров,
+// поэтому ackedSeq сдвигается, только когда подтверждены все предыдущие
+func (a *Agent) acknowledge(seqs []uint64) {
+	if len(seqs) == 0 {
+		return
+	}
+
+	a.checkpointMu.Lock()
+	if a.checkpoints == nil {
+		a.checkpointMu.Unlock()
+		return
+	}
+	if a.acked == nil {
+		a.acked = make(map[uint64]bool)
+	}
+	for _, seq := range seqs {
+		if seq > a.ackedSeq {
+			a.acked[seq] = true
+		}
+	}
+	for a.acked[a.ackedSeq+1] {
+		delete(a.acked, a.ackedSeq+1)
+		a.ackedSeq++
+	}
+	committed := a.commitPositions()
+	a.checkpointMu.Unlock()
+
+	if committed {
+		a.flushCheckpoints()
+	}
+}
+
+// commitPositions переносит подтверждённые позиции в хранилище. Очередь
+// упорядочена по номерам в пределах источника, но не между источниками:
+// сборщики в режиме наблюдения работают параллельно. Вызывается под checkpointMu
+func (a *Agent) commitPositions() bool {
+	committed := 0
+	waiting := a.pendingPositions[:0]
+	for _, pending := range a.pendingPositions {
+		if pending.seq > a.ackedSeq {
+			waiting = append(waiting, pending)
+			continue
+		}
+		committed++
+		if pending.removed {
+			a.checkpoints.Delete(pending.source)
+			continue
+		}
+		a.checkpoints.Set(pending.source, pending.pos)
+		if committer, ok := pending.col.(collector.Committer); ok {
+			committer.Committed(pending.pos)
+		}
+	}
+
+	a.pendingPositions = waiting
+	return committed > 0
+}
+
+// eventSeqs номера собранных событий пакета. События правил корреляции
+// создаёт обработчик, позиций чтения за ними нет
+func eventSeqs(events []buffer.Event) []uint64 {
+	var seqs []uint64
+	for _, event := range events {
+		if event.Seq != 0 {
+			seqs = append(seqs, event.Seq)
+		}
+	}
+	return seqs
+}
+
+// droppedSeqs номера событий пакета, отброшенных обработчиком
+func droppedSeqs(input, output []buffer.Event) []uint64 {
+	kept := make(map[uint64]bool, len(output))
+	for _, event := range output {
+		kept[event.Seq] = true
+	}
+	var dropped []uint64
+	for _, event := range input {
+		if event.Seq != 0 && !kept[event.Seq] {
+			dropped = append(dropped, event.Seq)
+		}
+	}
+	return dropped
+}
+
+// flushCheckpoints записывает позиции на диск
+func (a *Agent) flushCheckpoints() {
+	a.checkpointMu.Lock()
+	store := a.checkpoints
+	a.checkpointMu.Unlock()
+	if store == nil {
+		return
+	}
+
+	if err := store.Flush(); err != nil {
+		log.Printf("[Agent] Failed to save checkpoints: %v", err)
+	}
+}
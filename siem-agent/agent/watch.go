@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"agent/collector"
+)
+
+const (
+	// watchCoalesceDelay окно, в котором серия изменений файла схлопывается в один сбор
+	watchCoalesceDelay = 200 * time.Millisecond
+
+	// watchRescanInterval страховочный сбор на случай пропущенных уведомлений
+	// (например, на сетевых файловых системах)
+	watchRescanInterval = time.Minute
+)
+
+// watchHandle горутина наблюдения за источником
+type watchHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{} // закрывается после выхода из watchLoop
+}
+
+// WatchCollector регистрирует источник в режиме наблюдения: события собираются
+// сразу после записи в файл. Если inotify недоступен, источник опрашивается по таймеру
+func (a *Agent) WatchCollector(col collector.Collector) {
+	a.restorePosition(col)
+	a.watch(col)
+}
+
+// watch запускает наблюдение за источником
+func (a *Agent) watch(col collector.Collector) {
+	watchable, ok := col.(collector.Watchable)
+	if !ok {
+		log.Printf("[Agent] %s does not support watch mode, polling instead", col.GetSourceName())
+		a.poll(col)
+		return
+	}
+
+	watcher, paths, err := newFileWatcher(watchable.WatchPaths())
+	if err != nil {
+		log.Printf("[Agent] Watch unavailable for %s, polling instead: %v", col.GetSourceName(), err)
+		a.poll(col)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	handle := watchHandle{cancel: cancel, done: make(chan struct{})}
+	a.mu.Lock()
+	a.watched[col] = handle
+	a.mu.Unlock()
+
+	a.wg.Add(1)
+	go func() {
+		defer close(handle.done)
+		a.watchLoop(ctx, col, watcher, paths)
+	}()
+
+	log.Printf("[Agent] Collector registered in watch mode: %s (%s)", col.GetSourceName(), col.GetSourceType())
+}
+
+// watchLoop собирает события источника по уведомлениям fsnotify
+func (a *Agent) watchLoop(ctx context.Context, col collector.Collector, watcher *fsnotify.Watcher, paths map[string]bool) {
+	defer a.wg.Done()
+	defer watcher.Close()
+
+	// Сначала дочитываем то, что накопилось до начала наблюдения
+	a.collectFrom(col)
+
+	rescan := time.NewTicker(watchRescanInterval)
+	defer rescan.Stop()
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !relevantEvent(event, paths) {
+				continue
+			}
+			// Первое изменение запускает таймер, последующие до его срабатывания схлопываются
+			if pending == nil {
+				pending = time.After(watchCoalesceDelay)
+			}
+		case <-pending:
+			pending = nil
+			a.collectFrom(col)
+		case <-rescan.C:
+			a.collectFrom(col)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// При переполнении очереди inotify часть уведомлений потеряна: собираем сразу
+			log.Printf("[Collector] Watch error for %s: %v", col.GetSourceName(), err)
+			a.collectFrom(col)
+		}
+	}
+}
+
+// newFileWatcher создаёт наблюдатель за каталогами файлов. Следим за каталогами,
+// а не за файлами, чтобы видеть пересоздание файла при ротации
+func newFileWatcher(files []string) (*fsnotify.Watcher, map[string]bool, error) {
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no paths to watch")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		paths[file] = true
+
+		dir := filepath.Dir(file)
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			dir = file
+		}
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		dirs[dir] = true
+	}
+
+	return watcher, paths, nil
+}
+
+// relevantEvent проверяет, относится ли уведомление к наблюдаемым файлам
+func relevantEvent(event fsnotify.Event, paths map[string]bool) bool {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	return paths[name] || paths[filepath.Dir(name)]
+}
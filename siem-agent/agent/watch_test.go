@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"agent/collector"
+)
+
+// slowCollector долго собирает события и замечает одновременные вызовы Collect
+type slowCollector struct {
+	path string
+
+	mu       sync.Mutex
+	inFlight int
+	calls    int
+	overlap  bool
+	closed   bool
+}
+
+func (sc *slowCollector) Collect() ([]collector.Event, error) {
+	sc.mu.Lock()
+	sc.inFlight++
+	if sc.inFlight > 1 {
+		sc.overlap = true
+	}
+	sc.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	sc.mu.Lock()
+	sc.inFlight--
+	sc.calls++
+	sc.mu.Unlock()
+	return nil, nil
+}
+
+func (sc *slowCollector) WatchPaths() []string  { return []string{sc.path} }
+func (sc *slowCollector) GetSourceName() string { return "slow" }
+func (sc *slowCollector) GetSourceType() string { return "test" }
+func (sc *slowCollector) Close() error          { sc.mu.Lock(); sc.closed = true; sc.mu.Unlock(); return nil }
+func (sc *slowCollector) state() (int, int, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.inFlight, sc.calls, sc.closed
+}
+
+func newSlowCollector(t *testing.T) *slowCollector {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &slowCollector{path: path}
+}
+
+func TestDetachWatchedCollectorWaitsForLoop(t *testing.T) {
+	a, _ := newTestAgent(t, &fakeSender{})
+	col := newSlowCollector(t)
+
+	// watchLoop сразу начинает сбор; detach должен дождаться его окончания
+	a.WatchCollector(col)
+	time.Sleep(10 * time.Millisecond)
+	a.DetachCollector(col)
+	inFlight, calls, closed := col.state()
+	if inFlight != 0 || calls != 1 {
+		t.Fatalf("after detach: %d collections in flight, %d done; want 0 and 1", inFlight, calls)
+	}
+	if closed {
+		t.Fatal("detached collector was closed")
+	}
+
+	// Цикл наблюдения остановлен: запись в файл не вызывает сбор
+	if err := os.WriteFile(col.path, []byte("line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(watchCoalesceDelay + 200*time.Millisecond)
+	if _, calls, _ := col.state(); calls != 1 {
+		t.Fatalf("detached collector collected %d times, want 1", calls)
+	}
+
+	// Тот же сборщик в режиме опроса
+	a.RegisterCollector(col)
+	a.collectLogs()
+	a.UnregisterCollector(col)
+	if _, calls, closed := col.state(); calls != 2 || !closed {
+		t.Fatalf("after re-register: %d collections, closed %v; want 2 and true", calls, closed)
+	}
+	if col.overlap {
+		t.Error("Collect was called concurrently")
+	}
+}
+
+func TestDetachPolledCollectorWaitsForPass(t *testing.T) {
+	a, _ := newTestAgent(t, &fakeSender{})
+	col := newSlowCollector(t)
+	a.RegisterCollector(col)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.collectLogs()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	a.DetachCollector(col)
+	if inFlight, _, closed := col.state(); inFlight != 0 || closed {
+		t.Fatalf("after detach: %d collections in flight, closed %v", inFlight, closed)
+	}
+	<-done
+}
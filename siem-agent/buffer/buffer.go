@@ -15,6 +15,23 @@ type Event struct {
 	Process   string `json:"process,omitempty"`
 	Command   string `json:"command,omitempty"`
 	RawLog    string `json:"raw_log"`
+
+	// Fields дополнительные поля, извлечённые из записи (pid, src_ip, tty и т.д.)
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// RuleHits сработавшие правила обнаружения
+	RuleHits []RuleHit `json:"rule_hits,omitempty"`
+
+	// Seq номер события, собранного сборщиком, для подтверждения позиций
+	// чтения. У событий, созданных обработчиком, 0
+	Seq uint64 `json:"-"`
+}
+
+// RuleHit сработавшее на событии правило
+type RuleHit struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	EventType string `json:"event_type"`
 }
 
 // Buffer интерфейс для буфера событий
@@ -58,26 +75,35 @@ func NewRingBuffer(maxSize int) *RingBuffer {
 	return rb
 }
 
-// Push добавляет события в буфер
+// Push добавляет события в буфер. Пакет больше ёмкости буфера добавляется
+// частями по мере того, как события извлекаются
 func (rb *RingBuffer) Push(events []Event) error {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	// Ждём, пока будет свободное место
-	for len(events) > rb.maxSize-rb.count {
-		rb. notFull.Wait()
+	for len(events) > 0 {
+		chunk := events
+		if len(chunk) > rb.maxSize {
+			chunk = chunk[:rb.maxSize]
+		}
+		events = events[len(chunk):]
+
+		// Ждём, пока будет свободное место
+		for len(chunk) > rb.maxSize-rb.count {
+			rb. notFull.Wait()
+		}
+
+		// Добавляем события в буфер
+		for _, event := range chunk {
+			rb.entries[rb.tail] = event
+			rb.tail = (rb.tail + 1) % rb.maxSize
+			rb.count++
+		}
+
+		// Сигнализируем, что буфер не пуст
+		rb.notEmpty. Broadcast()
 	}
 
-	// Добавляем события в буфер
-	for _, event := range events {
-		rb.entries[rb.tail] = event
-		rb.tail = (rb.tail + 1) % rb.maxSize
-		rb.count++
-	}
-
-	// Сигнализируем, что буфер не пуст
-	rb.notEmpty. Broadcast()
-
 	return nil
 }
 
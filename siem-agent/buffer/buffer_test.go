@@ -0,0 +1,82 @@
+package buffer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func numberedEvents(count int) []Event {
+	events := make([]Event, count)
+	for i := range events {
+		events[i] = Event{RawLog: strconv.Itoa(i)}
+	}
+	return events
+}
+
+func TestPushLargerThanCapacity(t *testing.T) {
+	rb := NewRingBuffer(4)
+
+	done := make(chan struct{})
+	go func() {
+		rb.Push(numberedEvents(10))
+		close(done)
+	}()
+
+	// Пакет уходит в буфер частями по мере извлечения событий
+	var got []Event
+	deadline := time.After(5 * time.Second)
+	for len(got) < 10 {
+		select {
+		case <-deadline:
+			t.Fatalf("Push blocked, popped %d of 10 events", len(got))
+		default:
+		}
+		if rb.IsEmpty() {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		got = append(got, rb.Pop(3)...)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Push did not return")
+	}
+	for i, event := range got {
+		if event.RawLog != strconv.Itoa(i) {
+			t.Fatalf("event %d = %s, order not preserved", i, event.RawLog)
+		}
+	}
+	if !rb.IsEmpty() {
+		t.Errorf("buffer has %d events left", rb.Size())
+	}
+}
+
+func TestPushWaitsForSpace(t *testing.T) {
+	rb := NewRingBuffer(4)
+	rb.Push(numberedEvents(3))
+
+	done := make(chan struct{})
+	go func() {
+		rb.Push(numberedEvents(2))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Push returned without free space")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rb.Pop(1)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Push did not return after Pop")
+	}
+	if rb.Size() != 4 {
+		t.Errorf("size = %d, want 4", rb.Size())
+	}
+}
@@ -0,0 +1,165 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// stateFileName имя файла с позициями чтения в каталоге состояния
+const stateFileName = "checkpoints.json"
+
+// Position позиция чтения источника
+type Position struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Inode  uint64 `json:"inode"`
+	Device uint64 `json:"device"`
+
+	// Отпечаток начала файла позволяет найти его после ротации и сжатия
+	Fingerprint     string `json:"fingerprint,omitempty"`
+	FingerprintSize int64  `json:"fingerprint_size,omitempty"`
+
+	// Cursor позиция в источниках без смещения в файле (курсор journald)
+	Cursor string `json:"cursor,omitempty"`
+
+	// Seen недавно выданные записи файлов, которые переписываются целиком
+	// (история zsh и fish), в формате сборщика
+	Seen string `json:"seen,omitempty"`
+}
+
+// Store хранилище позиций чтения источников
+type Store struct {
+	path      string
+	positions map[string]Position
+	dirty     bool
+	mu        sync.Mutex
+}
+
+// Open загружает позиции из каталога состояния. Если файла ещё нет, хранилище пустое
+func Open(stateDir string) (*Store, error) {
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+
+	store := &Store{
+		path:      filepath.Join(stateDir, stateFileName),
+		positions: make(map[string]Position),
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.positions); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints %s: %w", store.path, err)
+	}
+
+	return store, nil
+}
+
+// Get возвращает сохранённую позицию источника
+func (s *Store) Get(source string) (Position, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos, ok := s.positions[source]
+	return pos, ok
+}
+
+// Prefixed возвращает позиции, ключ которых начинается с prefix. Ключи
+// возвращаются без префикса
+func (s *Store) Prefixed(prefix string) map[string]Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions := make(map[string]Position)
+	for key, pos := range s.positions {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			positions[rest] = pos
+		}
+	}
+	return positions
+}
+
+// Set запоминает позицию источника. На диск она попадёт при Flush
+func (s *Store) Set(source string, pos Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.positions[source]; ok && current == pos {
+		return
+	}
+	s.positions[source] = pos
+	s.dirty = true
+}
+
+// Delete удаляет позицию источника, которого больше нет (удалённый контейнер
+// или файл). На диске она исчезнет при Flush
+func (s *Store) Delete(source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.positions[source]; !ok {
+		return
+	}
+	delete(s.positions, source)
+	s.dirty = true
+}
+
+// Flush атомарно записывает позиции на диск
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.positions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoints: %w", err)
+	}
+
+	if err := WriteFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// WriteFileAtomic записывает файл атомарно: во временный файл рядом, затем rename.
+// После сбоя на диске остаётся либо старое, либо новое содержимое
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace: %w", err)
+	}
+	return nil
+}
@@ -1,51 +1,137 @@
 package collector
 
 import (
-	"bufio"
+	"encoding/hex"
 	"fmt"
-	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// auditGroupTimeout сколько ждём окончания многострочной записи (EOE),
+// прежде чем отдать её как есть
+const auditGroupTimeout = 2 * time.Second
+
+// auditUnsetID значение auid/uid, означающее «не задан» (-1)
+const auditUnsetID = "4294967295"
+
+var (
+	auditIDPattern    = regexp.MustCompile(`msg=audit\((\d+)\.(\d+):(\d+)\)`)
+	auditFieldPattern = regexp.MustCompile(`([A-Za-z_][\w-]*(?:\[\d+\])?)=("[^"]*"|'[^']*'|\S*)`)
+	auditArgPattern   = regexp.MustCompile(`^a(\d+)(?:\[(\d+)\])?$`)
 )
 
+// auditEncodedFields поля, которые auditd пишет в hex, если значение содержит
+// пробелы, кавычки или управляющие символы
+var auditEncodedFields = map[string]bool{
+	"comm": true, "exe": true, "cwd": true, "name": true, "proctitle": true,
+	"path": true, "key": true, "cmd": true, "acct": true,
+}
+
+// auditRecord одна строка audit.log
+type auditRecord struct {
+	recordType string
+	id         string // "секунды.миллисекунды:serial"
+	seconds    int64
+	fields     map[string]string
+	raw        string
+}
+
+// auditGroup записи одного события с общим serial
+type auditGroup struct {
+	id      string
+	records []auditRecord
+	start   int64 // смещение первой строки группы
+	inode   uint64
+	seenAt  time.Time
+}
+
+// AuditCollector читает audit.log и собирает записи одного события
+// (SYSCALL, EXECVE, CWD, PATH, PROCTITLE) в одно событие
 type AuditCollector struct {
 	filePath string
-	lastPos  int64
+	tailer   *fileTailer
+	pending  *auditGroup // незавершённая группа, ждёт остальных строк
 }
 
 func NewAuditCollector(filePath string) *AuditCollector {
 	return &AuditCollector{
 		filePath: filePath,
-		lastPos:  0,
+		tailer:   newFileTailer(filePath),
 	}
 }
 
 func (ac *AuditCollector) Collect() ([]Event, error) {
 	var events []Event
 
-	file, err := os.Open(ac.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open audit log file: %w", err)
-	}
-	defer file.Close()
+	err := ac.tailer.readLines(func(line string) {
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+
+		record := parseAuditRecord(line)
+		if ac.pending != nil && ac.pending.id != record.id {
+			events = append(events, ac.flushPending())
+		}
 
-	// Ищем последнюю прочитанную позицию
-	file. Seek(ac.lastPos, 0)
+		// EOE завершает многострочную запись
+		if record.recordType == "EOE" {
+			if ac.pending != nil {
+				events = append(events, ac.flushPending())
+			}
+			return
+		}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			event := ac.parseAuditLine(line)
-			events = append(events, event)
+		if ac.pending == nil {
+			ac.pending = &auditGroup{
+				id:     record.id,
+				start:  ac.tailer.offset,
+				inode:  ac.tailer.inode,
+				seenAt: time.Now(),
+			}
 		}
+		ac.pending.records = append(ac.pending.records, record)
+
+		// Записи без serial не группируются
+		if record.id == "" {
+			events = append(events, ac.flushPending())
+		}
+	})
+
+	// Одиночные записи не завершаются EOE, а многострочную ждём не дольше таймаута
+	if ac.pending != nil && (!ac.pending.awaitsMore() || time.Since(ac.pending.seenAt) > auditGroupTimeout) {
+		events = append(events, ac.flushPending())
 	}
 
-	// Сохраняем новую позицию
-	if pos, err := file.Seek(0, 1); err == nil {
-		ac.lastPos = pos
+	if err != nil {
+		return events, fmt.Errorf("failed to read audit log file: %w", err)
+	}
+
+	return events, nil
+}
+
+// Position возвращает текущую позицию чтения. Незавершённая группа будет
+// прочитана заново целиком после перезапуска
+func (ac *AuditCollector) Position() checkpoint.Position {
+	pos := ac.tailer.position()
+	if ac.pending != nil && ac.pending.inode == pos.Inode {
+		pos.Offset = ac.pending.start
 	}
+	return pos
+}
+
+// Restore восстанавливает сохранённую позицию чтения
+func (ac *AuditCollector) Restore(pos checkpoint.Position) {
+	ac.tailer.restore(pos)
+}
 
-	return events, scanner.Err()
+// WatchPaths возвращает файл, за которым нужно следить
+func (ac *AuditCollector) WatchPaths() []string {
+	return []string{ac.tailer.path}
 }
 
 func (ac *AuditCollector) GetSourceName() string {
@@ -56,30 +142,216 @@ func (ac *AuditCollector) GetSourceType() string {
 	return "audit"
 }
 
-func (ac *AuditCollector) parseAuditLine(rawLog string) Event {
+// flushPending превращает накопленную группу в событие
+func (ac *AuditCollector) flushPending() Event {
+	event := ac.pending.event()
+	ac.pending = nil
+	return event
+}
+
+// awaitsMore проверяет, может ли у группы быть продолжение: события системных
+// вызовов состоят из нескольких строк и заканчиваются EOE
+func (g *auditGroup) awaitsMore() bool {
+	for _, record := range g.records {
+		if record.recordType == "SYSCALL" {
+			return true
+		}
+	}
+	return false
+}
+
+// event собирает событие из всех записей группы
+func (g *auditGroup) event() Event {
+	first := g.records[0]
+
 	event := Event{
-		RawLog:    rawLog,
 		Source:    "audit",
-		Timestamp: extractAuditTimestamp(rawLog),
 		Hostname:  getHostname(),
 		Process:   "auditd",
+		EventType: first.recordType,
+		Fields:    make(map[string]string),
 	}
 
-	// Извлекаем тип события
-	event.EventType = extractAuditEventType(rawLog)
+	if first.seconds > 0 {
+		event.Timestamp = time.Unix(first.seconds, 0).Format(time.RFC3339)
+	} else {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if _, serial, ok := strings.Cut(g.id, ":"); ok {
+		event.Fields["serial"] = serial
+	}
 
-	// Определяем серьёзность
-	event.Severity = determineAuditSeverity(event.EventType)
+	var raw []string
+	var argv []string
+	var paths []string
+	for _, record := range g.records {
+		raw = append(raw, record.raw)
 
-	// Извлекаем команду
-	if command := extractAuditCommand(rawLog); command != "" {
-		event.Command = command
+		switch record.recordType {
+		case "SYSCALL":
+			for _, key := range []string{"arch", "syscall", "success", "exit", "ppid", "pid",
+				"auid", "uid", "gid", "euid", "suid", "fsuid", "egid", "tty", "ses", "comm", "exe", "key"} {
+				if value, ok := record.fields[key]; ok && value != "(null)" {
+					event.Fields[key] = value
+				}
+			}
+			// В формате ENRICHED auditd дописывает имя системного вызова
+			if name := record.fields["SYSCALL"]; name != "" {
+				event.Fields["syscall_name"] = name
+			}
+		case "EXECVE":
+			event.EventType = "EXECVE"
+			argv = auditArgv(record.fields)
+			event.Fields["argc"] = record.fields["argc"]
+		case "CWD":
+			event.Fields["cwd"] = record.fields["cwd"]
+		case "PATH":
+			if name := record.fields["name"]; name != "" && name != "(null)" {
+				paths = append(paths, name)
+			}
+		case "PROCTITLE":
+			event.Fields["proctitle"] = strings.TrimSpace(strings.ReplaceAll(record.fields["proctitle"], "\x00", " "))
+		default:
+			// Одиночные записи (USER_LOGIN, USER_CMD, ...) копируем целиком
+			for key, value := range record.fields {
+				if _, exists := event.Fields[key]; !exists && key != "msg" && key != "type" {
+					event.Fields[key] = value
+				}
+			}
+		}
 	}
 
-	// Извлекаем пользователя
-	if user := extractAuditUser(rawLog); user != "" {
-		event.User = user
+	if len(argv) > 0 {
+		event.Fields["argv"] = strings.Join(argv, " ")
+	}
+	for i, path := range paths {
+		event.Fields["path"+strconv.Itoa(i)] = path
+	}
+
+	switch {
+	case len(argv) > 0:
+		event.Command = strings.Join(argv, " ")
+	case event.Fields["proctitle"] != "":
+		event.Command = event.Fields["proctitle"]
+	case event.Fields["cmd"] != "":
+		event.Command = event.Fields["cmd"]
+	}
+
+	// Пользователь — тот, кто вошёл в систему (auid), а не эффективный uid
+	if auid := event.Fields["auid"]; auid != "" && auid != auditUnsetID {
+		event.User = auid
+	} else if uid := event.Fields["uid"]; uid != "" && uid != auditUnsetID {
+		event.User = uid
 	}
 
+	event.Severity = determineAuditSeverity(event.EventType)
+	event.RawLog = strings.Join(raw, "\n")
 	return event
-}
\ No newline at end of file
+}
+
+// parseAuditRecord разбирает одну строку audit.log
+func parseAuditRecord(line string) auditRecord {
+	record := auditRecord{
+		recordType: extractAuditEventType(line),
+		fields:     parseAuditFields(line),
+		raw:        line,
+	}
+
+	if m := auditIDPattern.FindStringSubmatch(line); m != nil {
+		record.id = m[1] + "." + m[2] + ":" + m[3]
+		record.seconds, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+
+	return record
+}
+
+// parseAuditFields разбирает пары key=value. Значения в кавычках берутся как есть,
+// без кавычек в кодируемых полях — декодируются из hex. Вложенное msg='...'
+// записей пользовательского пространства тоже разбирается
+func parseAuditFields(line string) map[string]string {
+	fields := make(map[string]string)
+
+	// После 0x1d auditd (ENRICHED) дописывает расшифрованные значения: UID="root"
+	line = strings.ReplaceAll(line, "\x1d", " ")
+
+	for _, m := range auditFieldPattern.FindAllStringSubmatch(line, -1) {
+		key, value := m[1], m[2]
+		if _, exists := fields[key]; exists {
+			continue
+		}
+		// msg=audit(...) — идентификатор записи, его разбирает parseAuditRecord
+		if key == "msg" && strings.HasPrefix(value, "audit(") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			fields[key] = strings.Trim(value, `"`)
+		case strings.HasPrefix(value, "'"):
+			inner := strings.Trim(value, "'")
+			fields[key] = inner
+			for nestedKey, nestedValue := range parseAuditFields(inner) {
+				if _, exists := fields[nestedKey]; !exists {
+					fields[nestedKey] = nestedValue
+				}
+			}
+		case auditEncodedFields[key] || auditArgPattern.MatchString(key):
+			fields[key] = decodeAuditHex(value)
+		default:
+			fields[key] = value
+		}
+	}
+
+	return fields
+}
+
+// auditArgv собирает аргументы EXECVE: a0, a1, ... Длинные аргументы
+// auditd разбивает на части a1[0], a1[1], ...
+func auditArgv(fields map[string]string) []string {
+	type part struct {
+		index int
+		value string
+	}
+	args := make(map[int][]part)
+	maxIndex := -1
+
+	for key, value := range fields {
+		m := auditArgPattern.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		argIndex, _ := strconv.Atoi(m[1])
+		partIndex := -1
+		if m[2] != "" {
+			partIndex, _ = strconv.Atoi(m[2])
+		}
+		args[argIndex] = append(args[argIndex], part{index: partIndex, value: value})
+		if argIndex > maxIndex {
+			maxIndex = argIndex
+		}
+	}
+
+	argv := make([]string, 0, maxIndex+1)
+	for i := 0; i <= maxIndex; i++ {
+		parts := args[i]
+		sort.Slice(parts, func(a, b int) bool { return parts[a].index < parts[b].index })
+		var arg strings.Builder
+		for _, p := range parts {
+			arg.WriteString(p.value)
+		}
+		argv = append(argv, arg.String())
+	}
+	return argv
+}
+
+// decodeAuditHex декодирует hex-значение. Если это не hex, возвращает как есть
+func decodeAuditHex(value string) string {
+	if value == "" || value == "(null)" || len(value)%2 != 0 {
+		return value
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return value
+	}
+	return string(decoded)
+}
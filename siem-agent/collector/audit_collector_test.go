@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	auditSyscall   = `type=SYSCALL msg=audit(1709294400.123:4242): arch=c000003e syscall=59 success=yes exit=0 a0=55d1 a1=55d2 a2=55d3 a3=0 items=2 ppid=1000 pid=1001 auid=1000 uid=0 gid=0 euid=0 suid=0 fsuid=0 egid=0 sgid=0 fsgid=0 tty=pts0 ses=3 comm="cat" exe="/usr/bin/cat" key="shadow"`
+	auditExecve    = `type=EXECVE msg=audit(1709294400.123:4242): argc=2 a0="cat" a1="/etc/shadow"`
+	auditCwd       = `type=CWD msg=audit(1709294400.123:4242): cwd=2F746D702F6D7920646972`
+	auditPath0     = `type=PATH msg=audit(1709294400.123:4242): item=0 name="/usr/bin/cat" inode=100 dev=08:01 mode=0100755`
+	auditPath1     = `type=PATH msg=audit(1709294400.123:4242): item=1 name="/etc/shadow" inode=200 dev=08:01 mode=0100640`
+	auditProctitle = `type=PROCTITLE msg=audit(1709294400.123:4242): proctitle=636174002F6574632F736861646F77`
+	auditEOE       = `type=EOE msg=audit(1709294400.123:4242):`
+	auditUserLogin = `type=USER_LOGIN msg=audit(1709294500.000:4300): pid=2000 uid=0 auid=1000 ses=4 msg='op=login id=1000 exe="/usr/sbin/sshd" hostname=? addr=203.0.113.7 terminal=ssh res=failed'`
+)
+
+func TestAuditCollectorReassembly(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		events []map[string]string // ожидаемые поля; "type", "command", "user" — поля события
+	}{
+		{
+			name:  "syscall with execve",
+			lines: []string{auditSyscall, auditExecve, auditCwd, auditPath0, auditPath1, auditProctitle, auditEOE},
+			events: []map[string]string{{
+				"type":      "EXECVE",
+				"command":   "cat /etc/shadow",
+				"user":      "1000",
+				"serial":    "4242",
+				"syscall":   "59",
+				"exe":       "/usr/bin/cat",
+				"key":       "shadow",
+				"argv":      "cat /etc/shadow",
+				"cwd":       "/tmp/my dir",
+				"path0":     "/usr/bin/cat",
+				"path1":     "/etc/shadow",
+				"proctitle": "cat /etc/shadow",
+			}},
+		},
+		{
+			name: "split argument",
+			lines: []string{
+				strings.Replace(auditSyscall, "4242", "5000", 1),
+				`type=EXECVE msg=audit(1709294400.123:5000): argc=2 a0="echo" a1_len=24 a1[1]=776F726C6420 a1[0]=68656C6C6F20`,
+				`type=EOE msg=audit(1709294400.123:5000):`,
+			},
+			events: []map[string]string{{"type": "EXECVE", "command": "echo hello world ", "argc": "2"}},
+		},
+		{
+			name:  "single user record",
+			lines: []string{auditUserLogin},
+			events: []map[string]string{{
+				"type": "USER_LOGIN",
+				"user": "1000",
+				"op":   "login",
+				"addr": "203.0.113.7",
+				"res":  "failed",
+				"exe":  "/usr/sbin/sshd",
+			}},
+		},
+		{
+			name:  "group without EOE ends at next serial",
+			lines: []string{auditSyscall, auditExecve, auditUserLogin},
+			events: []map[string]string{
+				{"type": "EXECVE", "command": "cat /etc/shadow", "serial": "4242"},
+				{"type": "USER_LOGIN", "serial": "4300"},
+			},
+		},
+		{
+			name:   "unset auid falls back to uid",
+			lines:  []string{strings.Replace(auditSyscall, "auid=1000", "auid=4294967295", 1), auditEOE},
+			events: []map[string]string{{"type": "SYSCALL", "user": "0", "command": ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			appendFile(t, path, strings.Join(tt.lines, "\n")+"\n")
+
+			events, err := NewAuditCollector(path).Collect()
+			if err != nil {
+				t.Fatalf("Collect: %v", err)
+			}
+			if len(events) != len(tt.events) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.events))
+			}
+			for i, want := range tt.events {
+				event := events[i]
+				for key, value := range want {
+					var got string
+					switch key {
+					case "type":
+						got = event.EventType
+					case "command":
+						got = event.Command
+					case "user":
+						got = event.User
+					default:
+						got = event.Fields[key]
+					}
+					if got != value {
+						t.Errorf("event %d: %s = %q, want %q", i, key, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAuditCollectorPartialGroup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	appendFile(t, path, auditUserLogin+"\n"+auditSyscall+"\n"+auditExecve+"\n")
+
+	ac := NewAuditCollector(path)
+	events, err := ac.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != "USER_LOGIN" {
+		t.Fatalf("first read: %d events, want only USER_LOGIN", len(events))
+	}
+
+	// Незавершённая группа после перезапуска читается заново целиком
+	pos := ac.Position()
+	if want := int64(len(auditUserLogin) + 1); pos.Offset != want {
+		t.Errorf("position = %d, want start of pending group %d", pos.Offset, want)
+	}
+
+	appendFile(t, path, auditCwd+"\n"+auditEOE+"\n")
+	events, err = ac.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 1 || events[0].Command != "cat /etc/shadow" || events[0].Fields["cwd"] != "/tmp/my dir" {
+		t.Fatalf("completed group = %+v", events)
+	}
+
+	restarted := NewAuditCollector(path)
+	restarted.Restore(pos)
+	events, _ = restarted.Collect()
+	if len(events) != 1 || events[0].Fields["cwd"] != "/tmp/my dir" || events[0].Fields["argv"] != "cat /etc/shadow" {
+		t.Fatalf("after restart = %+v", events)
+	}
+}
@@ -0,0 +1,305 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// authLogPaths расположение журнала аутентификации в Debian/Ubuntu и RHEL
+var authLogPaths = []string{"/var/log/auth.log", "/var/log/secure"}
+
+var (
+	sshAcceptedPattern = regexp.MustCompile(`Accepted (\S+) for (\S+) from (\S+) port (\d+)`)
+	sshFailedPattern   = regexp.MustCompile(`Failed (\S+) for (invalid user )?(\S+) from (\S+) port (\d+)`)
+	sshInvalidPattern  = regexp.MustCompile(`Invalid user (\S*) from (\S+)(?: port (\d+))?`)
+	sudoPattern        = regexp.MustCompile(`sudo(?:\[\d+\])?:\s+(\S+)\s+:\s+(.*)$`)
+	suSessionPattern   = regexp.MustCompile(`pam_unix\(su(?:-l)?:session\): session (opened|closed) for user ([^\s(]+)(?:\(uid=\d+\))?(?: by ([^\s(]*))?`)
+	suResultPattern    = regexp.MustCompile(`(Successful|FAILED) su for (\S+) by (\S+)`)
+	pamAuthFailPattern = regexp.MustCompile(`pam_unix\(([\w-]+):auth\): authentication failure;`)
+	pamFieldPattern    = regexp.MustCompile(`\b(logname|uid|tty|ruser|rhost|user)=(\S*)`)
+	userAddPattern     = regexp.MustCompile(`new user: name=([^,]+), UID=(\d+)`)
+	groupAddPattern    = regexp.MustCompile(`new group: name=([^,]+), GID=(\d+)`)
+	userDelPattern     = regexp.MustCompile(`delete user '([^']+)'`)
+	userModPattern     = regexp.MustCompile(`add '([^']+)' to (?:shadow )?group '([^']+)'`)
+	passwdPattern      = regexp.MustCompile(`password changed for (\S+)`)
+)
+
+// AuthLogCollector читает /var/log/auth.log (или /var/log/secure) и разбирает
+// входы по SSH, sudo, su и изменения учётных записей
+type AuthLogCollector struct {
+	filePath string
+	tailer   *fileTailer
+}
+
+// NewAuthLogCollector создаёт сборщик журнала аутентификации. При пустом пути
+// используется первый существующий из auth.log и secure
+func NewAuthLogCollector(filePath string) *AuthLogCollector {
+	if filePath == "" {
+		filePath = DefaultAuthLogPath()
+	}
+	return &AuthLogCollector{
+		filePath: filePath,
+		tailer:   newFileTailer(filePath),
+	}
+}
+
+// DefaultAuthLogPath возвращает журнал аутентификации, существующий в системе
+func DefaultAuthLogPath() string {
+	for _, path := range authLogPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return authLogPaths[0]
+}
+
+func (alc *AuthLogCollector) Collect() ([]Event, error) {
+	var events []Event
+
+	err := alc.tailer.readLines(func(line string) {
+		if strings.TrimSpace(line) != "" {
+			events = append(events, alc.parseAuthLine(line))
+		}
+	})
+	if err != nil {
+		return events, fmt.Errorf("failed to read auth log file: %w", err)
+	}
+
+	return events, nil
+}
+
+// Position возвращает текущую позицию чтения
+func (alc *AuthLogCollector) Position() checkpoint.Position {
+	return alc.tailer.position()
+}
+
+// Restore восстанавливает сохранённую позицию чтения
+func (alc *AuthLogCollector) Restore(pos checkpoint.Position) {
+	alc.tailer.restore(pos)
+}
+
+// WatchPaths возвращает файл, за которым нужно следить
+func (alc *AuthLogCollector) WatchPaths() []string {
+	return []string{alc.tailer.path}
+}
+
+func (alc *AuthLogCollector) GetSourceName() string {
+	return "authlog"
+}
+
+func (alc *AuthLogCollector) GetSourceType() string {
+	return "authlog"
+}
+
+func (alc *AuthLogCollector) parseAuthLine(rawLog string) Event {
+	event := Event{
+		RawLog:    rawLog,
+		Source:    "authlog",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Process:   extractAuthLogProcess(rawLog),
+		User:      extractAuthLogUser(rawLog),
+		EventType: "auth_event",
+		Severity:  determineSeverity(rawLog),
+		Fields:    make(map[string]string),
+	}
+	if t, ok := parseSyslogTimestamp(rawLog); ok {
+		event.Timestamp = t.Format(time.RFC3339Nano)
+	}
+
+	switch {
+	case sshAcceptedPattern.MatchString(rawLog):
+		m := sshAcceptedPattern.FindStringSubmatch(rawLog)
+		event.EventType = "ssh_login_success"
+		event.User = m[2]
+		event.Fields["auth_method"] = m[1]
+		event.Fields["src_ip"] = m[3]
+		event.Fields["src_port"] = m[4]
+		event.Severity = "INFO"
+		if m[2] == "root" {
+			event.Severity = "WARNING"
+		}
+
+	case sshFailedPattern.MatchString(rawLog):
+		m := sshFailedPattern.FindStringSubmatch(rawLog)
+		event.EventType = "ssh_login_failed"
+		event.User = m[3]
+		event.Fields["auth_method"] = m[1]
+		event.Fields["src_ip"] = m[4]
+		event.Fields["src_port"] = m[5]
+		if m[2] != "" {
+			event.Fields["invalid_user"] = "true"
+		}
+		event.Severity = "WARNING"
+
+	case sshInvalidPattern.MatchString(rawLog):
+		m := sshInvalidPattern.FindStringSubmatch(rawLog)
+		event.EventType = "ssh_invalid_user"
+		event.User = m[1]
+		event.Fields["src_ip"] = m[2]
+		if m[3] != "" {
+			event.Fields["src_port"] = m[3]
+		}
+		event.Severity = "WARNING"
+
+	case sudoPattern.MatchString(rawLog):
+		m := sudoPattern.FindStringSubmatch(rawLog)
+		parseSudoLine(&event, m[1], m[2])
+
+	case suSessionPattern.MatchString(rawLog):
+		m := suSessionPattern.FindStringSubmatch(rawLog)
+		event.EventType = "su_session_" + m[1]
+		event.Fields["target_user"] = m[2]
+		event.User = m[3]
+		event.Severity = "INFO"
+		if m[1] == "opened" && m[2] == "root" {
+			event.Severity = "WARNING"
+		}
+
+	case suResultPattern.MatchString(rawLog):
+		m := suResultPattern.FindStringSubmatch(rawLog)
+		event.EventType = "su_success"
+		event.Severity = "INFO"
+		if m[1] == "FAILED" {
+			event.EventType = "su_failed"
+			event.Severity = "WARNING"
+		}
+		event.Fields["target_user"] = m[2]
+		event.User = m[3]
+
+	case pamAuthFailPattern.MatchString(rawLog):
+		m := pamAuthFailPattern.FindStringSubmatch(rawLog)
+		parsePamAuthFailure(&event, m[1], rawLog)
+
+	case userAddPattern.MatchString(rawLog):
+		m := userAddPattern.FindStringSubmatch(rawLog)
+		event.EventType = "user_created"
+		event.Fields["target_user"] = m[1]
+		event.Fields["target_uid"] = m[2]
+		event.Severity = "WARNING"
+
+	case groupAddPattern.MatchString(rawLog):
+		m := groupAddPattern.FindStringSubmatch(rawLog)
+		event.EventType = "group_created"
+		event.Fields["group"] = m[1]
+		event.Fields["gid"] = m[2]
+		event.Severity = "INFO"
+
+	case userDelPattern.MatchString(rawLog):
+		m := userDelPattern.FindStringSubmatch(rawLog)
+		event.EventType = "user_deleted"
+		event.Fields["target_user"] = m[1]
+		event.Severity = "WARNING"
+
+	case userModPattern.MatchString(rawLog):
+		m := userModPattern.FindStringSubmatch(rawLog)
+		event.EventType = "user_modified"
+		event.Fields["target_user"] = m[1]
+		event.Fields["group"] = m[2]
+		event.Severity = "WARNING"
+
+	case passwdPattern.MatchString(rawLog):
+		m := passwdPattern.FindStringSubmatch(rawLog)
+		event.EventType = "password_changed"
+		event.Fields["target_user"] = m[1]
+		event.Severity = "WARNING"
+	}
+
+	if len(event.Fields) == 0 {
+		event.Fields = nil
+	}
+	return event
+}
+
+// parseSudoLine разбирает строку sudo вида
+// "alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/id".
+// Перед TTY может стоять сообщение об ошибке ("3 incorrect password attempts")
+func parseSudoLine(event *Event, user, details string) {
+	event.User = user
+	event.EventType = "sudo_execution"
+	event.Severity = "INFO"
+
+	var messages []string
+	parts := strings.Split(details, " ; ")
+	for i, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || strings.ContainsAny(key, " \t") {
+			messages = append(messages, strings.TrimSpace(part))
+			continue
+		}
+
+		switch key {
+		case "TTY":
+			event.Fields["tty"] = value
+		case "PWD":
+			event.Fields["pwd"] = value
+		case "USER":
+			event.Fields["target_user"] = value
+		case "COMMAND":
+			// Команда может содержать " ; ", поэтому забираем остаток строки
+			command := strings.Join(append([]string{value}, parts[i+1:]...), " ; ")
+			event.Command = command
+			event.Fields["sudo_command"] = command
+		}
+		if key == "COMMAND" {
+			break
+		}
+	}
+
+	if len(messages) > 0 {
+		message := strings.Join(messages, "; ")
+		event.Fields["message"] = message
+		event.EventType = "sudo_auth_failure"
+		event.Severity = "WARNING"
+	}
+
+	if event.Fields["target_user"] == "root" && event.EventType == "sudo_execution" {
+		event.Severity = determineBashCommandSeverity(event.Command)
+	}
+}
+
+// parsePamAuthFailure разбирает "pam_unix(service:auth): authentication failure; logname=... ruser=... user=..."
+func parsePamAuthFailure(event *Event, service, rawLog string) {
+	fields := make(map[string]string)
+	for _, m := range pamFieldPattern.FindAllStringSubmatch(rawLog, -1) {
+		fields[m[1]] = m[2]
+	}
+
+	event.Severity = "WARNING"
+	event.Fields["pam_service"] = service
+	if fields["rhost"] != "" {
+		event.Fields["src_ip"] = fields["rhost"]
+	}
+	if fields["tty"] != "" {
+		event.Fields["tty"] = fields["tty"]
+	}
+
+	// Кто пытался войти: ruser/logname, под кем: user
+	actor := fields["ruser"]
+	if actor == "" {
+		actor = fields["logname"]
+	}
+
+	switch strings.TrimSuffix(service, "-l") {
+	case "sudo":
+		event.EventType = "sudo_auth_failure"
+		event.User = fields["user"]
+	case "su":
+		event.EventType = "su_failed"
+		event.User = actor
+		event.Fields["target_user"] = fields["user"]
+	case "sshd":
+		// Ту же попытку sshd записывает строкой "Failed password", которая
+		// даёт ssh_login_failed; отдельный тип, чтобы попытка не считалась дважды
+		event.EventType = "pam_auth_failure"
+		event.User = fields["user"]
+	default:
+		event.EventType = "auth_failure"
+		event.User = fields["user"]
+	}
+}
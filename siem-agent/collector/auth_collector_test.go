@@ -0,0 +1,98 @@
+package collector
+
+import "testing"
+
+func TestParseAuthLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		eventType string
+		severity  string
+		user      string
+		fields    map[string]string
+	}{
+		{
+			name:      "ssh accepted",
+			line:      "Mar  1 12:00:00 host sshd[100]: Accepted publickey for alice from 192.0.2.10 port 50022 ssh2",
+			eventType: "ssh_login_success",
+			severity:  "INFO",
+			user:      "alice",
+			fields:    map[string]string{"auth_method": "publickey", "src_ip": "192.0.2.10", "src_port": "50022"},
+		},
+		{
+			name:      "ssh failed password",
+			line:      "Mar  1 12:00:01 host sshd[101]: Failed password for invalid user admin from 203.0.113.7 port 40000 ssh2",
+			eventType: "ssh_login_failed",
+			severity:  "WARNING",
+			user:      "admin",
+			fields:    map[string]string{"src_ip": "203.0.113.7", "invalid_user": "true"},
+		},
+		{
+			// Та же попытка, записанная PAM, не должна давать второй ssh_login_failed
+			name:      "pam sshd failure",
+			line:      "Mar  1 12:00:01 host sshd[101]: pam_unix(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=203.0.113.7  user=root",
+			eventType: "pam_auth_failure",
+			severity:  "WARNING",
+			user:      "root",
+			fields:    map[string]string{"pam_service": "sshd", "src_ip": "203.0.113.7"},
+		},
+		{
+			name:      "pam sudo failure",
+			line:      "Mar  1 12:00:02 host sudo: pam_unix(sudo:auth): authentication failure; logname=bob uid=1000 euid=0 tty=/dev/pts/0 ruser=bob rhost=  user=bob",
+			eventType: "sudo_auth_failure",
+			severity:  "WARNING",
+			user:      "bob",
+		},
+		{
+			name:      "sudo command",
+			line:      "Mar  1 12:00:03 host sudo:      bob : TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/usr/bin/id",
+			eventType: "sudo_execution",
+			user:      "bob",
+			fields:    map[string]string{"tty": "pts/0", "target_user": "root", "sudo_command": "/usr/bin/id"},
+		},
+		{
+			name:      "sudo incorrect password",
+			line:      "Mar  1 12:00:04 host sudo:      bob : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/bin/sh",
+			eventType: "sudo_auth_failure",
+			severity:  "WARNING",
+			user:      "bob",
+			fields:    map[string]string{"message": "3 incorrect password attempts"},
+		},
+		{
+			name:      "su failed",
+			line:      "Mar  1 12:00:05 host su[300]: FAILED su for root by bob",
+			eventType: "su_failed",
+			severity:  "WARNING",
+			user:      "bob",
+			fields:    map[string]string{"target_user": "root"},
+		},
+		{
+			name:      "user created",
+			line:      "Mar  1 12:00:06 host useradd[400]: new user: name=mallory, UID=1001, GID=1001, home=/home/mallory",
+			eventType: "user_created",
+			severity:  "WARNING",
+			fields:    map[string]string{"target_user": "mallory", "target_uid": "1001"},
+		},
+	}
+
+	alc := NewAuthLogCollector("/nonexistent/auth.log")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := alc.parseAuthLine(tt.line)
+			if event.EventType != tt.eventType {
+				t.Errorf("event_type = %q, want %q", event.EventType, tt.eventType)
+			}
+			if tt.severity != "" && event.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", event.Severity, tt.severity)
+			}
+			if event.User != tt.user {
+				t.Errorf("user = %q, want %q", event.User, tt.user)
+			}
+			for key, value := range tt.fields {
+				if event.Fields[key] != value {
+					t.Errorf("Fields[%q] = %q, want %q", key, event.Fields[key], value)
+				}
+			}
+		})
+	}
+}
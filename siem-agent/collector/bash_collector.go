@@ -1,57 +1,78 @@
 package collector
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"agent/checkpoint"
 )
 
+// bashTimestampPattern строка-метка времени, которую bash пишет в историю
+// перед командой, если задан HISTTIMEFORMAT: "#1700000000"
+var bashTimestampPattern = regexp.MustCompile(`^#(\d{9,11})$`)
+
 type BashCollector struct {
-	homeDir string
-	user    string
-	lastPos int64
+	user   string
+	tailer *fileTailer
+
+	// Метка времени для следующей команды и смещение её строки
+	timestamp       time.Time
+	timestampOffset int64
+	timestampInode  uint64
 }
 
-func NewBashCollector(user, homeDir string) *BashCollector {
+// NewBashCollector создаёт сборщик истории bash пользователя
+func NewBashCollector(user, historyPath string) *BashCollector {
 	return &BashCollector{
-		homeDir:  homeDir,
-		user:    user,
-		lastPos: 0,
+		user:   user,
+		tailer: newFileTailer(historyPath),
 	}
 }
 
 func (bc *BashCollector) Collect() ([]Event, error) {
 	var events []Event
 
-	bashHistoryPath := filepath.Join(bc.homeDir, ".bash_history")
-
-	file, err := os.Open(bashHistoryPath)
+	err := readHistory(bc.tailer, func(line string) {
+		if m := bashTimestampPattern.FindStringSubmatch(line); m != nil {
+			seconds, _ := strconv.ParseInt(m[1], 10, 64)
+			bc.timestamp = time.Unix(seconds, 0)
+			bc.timestampOffset = bc.tailer.offset
+			bc.timestampInode = bc.tailer.inode
+			return
+		}
+		if strings.TrimSpace(line) != "" {
+			events = append(events, bc.parseBashHistoryLine(line))
+		}
+		bc.timestamp = time.Time{}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bash history file: %w", err)
+		return events, fmt.Errorf("failed to read bash history file: %w", err)
 	}
-	defer file.Close()
 
-	// Ищем последнюю прочитанную позицию
-	file.Seek(bc. lastPos, 0)
+	return events, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			event := bc. parseBashHistoryLine(line)
-			events = append(events, event)
-		}
+// Position возвращает текущую позицию чтения. Если метка времени прочитана,
+// а команда ещё нет, после перезапуска метка будет прочитана заново
+func (bc *BashCollector) Position() checkpoint.Position {
+	pos := bc.tailer.position()
+	if !bc.timestamp.IsZero() && bc.timestampInode == pos.Inode {
+		pos.Offset = bc.timestampOffset
 	}
+	return pos
+}
 
-	// Сохраняем новую позицию
-	if pos, err := file.Seek(0, 1); err == nil {
-		bc.lastPos = pos
-	}
+// Restore восстанавливает сохранённую позицию чтения
+func (bc *BashCollector) Restore(pos checkpoint.Position) {
+	bc.tailer.restore(pos)
+}
 
-	return events, scanner.Err()
+// WatchPaths возвращает файл, за которым нужно следить
+func (bc *BashCollector) WatchPaths() []string {
+	return []string{bc.tailer.path}
 }
 
 func (bc *BashCollector) GetSourceName() string {
@@ -62,18 +83,8 @@ func (bc *BashCollector) GetSourceType() string {
 	return "bash_history"
 }
 
+// parseBashHistoryLine создаёт событие команды. Без HISTTIMEFORMAT время
+// выполнения неизвестно, и берётся время чтения
 func (bc *BashCollector) parseBashHistoryLine(rawLog string) Event {
-	event := Event{
-		RawLog:    rawLog,
-		Source:    "bash_history",
-		EventType: "command_execution",
-		Severity:  determineBashCommandSeverity(rawLog),
-		Timestamp: time.Now().Format(time.RFC3339),
-		Hostname:  getHostname(),
-		User:      bc.user,
-		Process:   "bash",
-		Command:   rawLog,
-	}
-
-	return event
-}
\ No newline at end of file
+	return shellCommandEvent("bash_history", "bash", bc.user, rawLog, bc.timestamp)
+}
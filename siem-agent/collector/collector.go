@@ -3,9 +3,9 @@ package collector
 import (
 	"os"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
+
+	"agent/checkpoint"
 )
 
 // Event - структура нормализованного события
@@ -19,6 +19,9 @@ type Event struct {
 	Process   string `json:"process,omitempty"`
 	Command   string `json:"command,omitempty"`
 	RawLog    string `json:"raw_log"`
+
+	// Fields дополнительные поля, извлечённые из записи (pid, src_ip, tty и т.д.)
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 // Collector интерфейс для сборщиков логов
@@ -33,6 +36,37 @@ type Collector interface {
 	GetSourceType() string
 }
 
+// Checkpointer сборщик, позицию чтения которого можно сохранить между перезапусками
+type Checkpointer interface {
+	// Position возвращает текущую позицию чтения
+	Position() checkpoint.Position
+
+	// Restore восстанавливает сохранённую позицию чтения
+	Restore(pos checkpoint.Position)
+}
+
+// MultiCheckpointer сборщик нескольких файлов, позиция чтения сохраняется для каждого
+type MultiCheckpointer interface {
+	// Positions возвращает позиции чтения по ключу (пути файла)
+	Positions() map[string]checkpoint.Position
+
+	// RestorePositions восстанавливает сохранённые позиции чтения
+	RestorePositions(positions map[string]checkpoint.Position)
+}
+
+// Committer сборщик, которому нужно знать о сохранении позиции: все события,
+// собранные до неё, доставлены
+type Committer interface {
+	// Committed вызывается после сохранения позиции pos
+	Committed(pos checkpoint.Position)
+}
+
+// Watchable сборщик, которому можно сообщать об изменении его файлов
+type Watchable interface {
+	// WatchPaths возвращает файлы (или каталоги), изменения которых означают новые события
+	WatchPaths() []string
+}
+
 
 func getHostname() string {
 	hostname, err := os.Hostname()
@@ -78,7 +112,7 @@ func determineSeverity(log string) string {
 
 func extractAuthLogUser(log string) string {
 	patterns := []string{
-		`(?:for invalid user|for)\s+([a-zA-Z0-9\-_]+)`,
+		`(?:for invalid user|for user|for)\s+([a-zA-Z0-9\-_]+)`,
 		`sudo:\s+([a-zA-Z0-9\-_]+)\s+:`,
 	}
 
@@ -94,7 +128,8 @@ func extractAuthLogUser(log string) string {
 }
 
 func extractAuthLogProcess(log string) string {
-	re := regexp. MustCompile(`^.*\s([a-zA-Z0-9\-_]+)\[?\d*\]? :\s`)
+	// Имя процесса — первое слово вида "name:" или "name[pid]:" после даты и хоста
+	re := regexp.MustCompile(`^(?:\S+\s+)+?([a-zA-Z0-9\-_.]+)(?:\[\d+\])?:\s`)
 	matches := re.FindStringSubmatch(log)
 	if len(matches) > 1 {
 		return matches[1]
@@ -142,40 +177,6 @@ func extractAuditEventType(log string) string {
 	return "UNKNOWN"
 }
 
-func extractAuditTimestamp(log string) string {
-	// Извлекаем timestamp из audit:  msg=audit(1234567890.123: 4567)
-	re := regexp.MustCompile(`audit\((\d+)\.(\d+):`)
-	matches := re.FindStringSubmatch(log)
-	if len(matches) > 2 {
-		unixTime, err := strconv.ParseInt(matches[1], 10, 64)
-		if err == nil {
-			t := time.Unix(unixTime, 0)
-			return t.Format(time.RFC3339)
-		}
-	}
-	return time.Now().Format(time.RFC3339)
-}
-
-func extractAuditCommand(log string) string {
-	// Ищет команду в EXECVE событиях:  a0="command" a1="arg1" ... 
-	re := regexp.MustCompile(`a0="([^"]+)"`)
-	matches := re.FindStringSubmatch(log)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
-
-func extractAuditUser(log string) string {
-	// Ищет uid
-	re := regexp.MustCompile(`uid=(\d+)`)
-	matches := re.FindStringSubmatch(log)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
-
 func extractSyslogService(log string) (service, eventType string) {
 	// Ищем паттерны типа:  "sudo:", "kernel:", "systemd:", "sshd:" и т.д.
 	re := regexp.MustCompile(`^.*\s([a-zA-Z0-9\-_]+)\[?\d*\]?:\s`)
@@ -184,19 +185,21 @@ func extractSyslogService(log string) (service, eventType string) {
 		service = matches[1]
 	}
 
-	// Определяем тип события по сервису
+	return service, syslogEventType(service)
+}
+
+// syslogEventType определяет тип события по имени сервиса
+func syslogEventType(service string) string {
 	switch service {
 	case "kernel":
-		eventType = "kernel_message"
+		return "kernel_message"
 	case "sudo":
-		eventType = "sudo_execution"
+		return "sudo_execution"
 	case "sshd":
-		eventType = "ssh_event"
-	case "systemd": 
-		eventType = "systemd_event"
-	default: 
-		eventType = "system_event"
+		return "ssh_event"
+	case "systemd":
+		return "systemd_event"
+	default:
+		return "system_event"
 	}
-
-	return service, eventType
 }
\ No newline at end of file
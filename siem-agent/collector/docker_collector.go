@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// dockerLogEntry строка журнала драйвера json-file
+type dockerLogEntry struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// dockerContainerConfig нужные поля config.v2.json
+type dockerContainerConfig struct {
+	ID     string `json:"ID"`
+	Name   string `json:"Name"`
+	Image  string `json:"Image"` // sha256 образа
+	Config struct {
+		Image string `json:"Image"` // имя образа, с которым создан контейнер
+	} `json:"Config"`
+}
+
+// dockerPartial начало длинной строки, продолжение которой ещё не прочитано
+type dockerPartial struct {
+	text   strings.Builder
+	time   string
+	offset int64 // смещение первой части в журнале
+	inode  uint64
+}
+
+// containerLog журнал одного контейнера
+type containerLog struct {
+	id       string
+	name     string
+	image    string
+	imageID  string
+	metaTime time.Time // mtime config.v2.json при последнем чтении
+	tailer   *fileTailer
+
+	// Длинные строки Docker разбивает на части по 16 КБ, последняя
+	// заканчивается переводом строки. Части stdout и stderr могут
+	// чередоваться, поэтому собираются отдельно для каждого потока
+	partials map[string]*dockerPartial
+}
+
+// DockerCollector читает журналы контейнеров драйвера json-file
+// (<root>/<id>/<id>-json.log). Каталог проверяется при каждом сборе,
+// поэтому новые контейнеры подхватываются, а удалённые забываются
+type DockerCollector struct {
+	root       string
+	containers map[string]*containerLog       // id -> журнал
+	restored   map[string]checkpoint.Position // позиции ещё не найденных журналов
+}
+
+// NewDockerCollector создаёт сборщик для каталога контейнеров (обычно /var/lib/docker/containers)
+func NewDockerCollector(root string) *DockerCollector {
+	return &DockerCollector{
+		root:       root,
+		containers: make(map[string]*containerLog),
+		restored:   make(map[string]checkpoint.Position),
+	}
+}
+
+func (dc *DockerCollector) Collect() ([]Event, error) {
+	dc.discover()
+
+	ids := make([]string, 0, len(dc.containers))
+	for id := range dc.containers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var events []Event
+	var errs []error
+	for _, id := range ids {
+		container := dc.containers[id]
+		dc.refreshMetadata(container)
+
+		err := container.tailer.readLines(func(line string) {
+			if event, ok := dc.parseLine(container, line); ok {
+				events = append(events, event)
+			}
+		})
+		switch {
+		case os.IsNotExist(err):
+			// Контейнер удалён вместе с каталогом
+			log.Printf("[Collector] docker: container %s (%s) removed", shortContainerID(id), container.name)
+			delete(dc.containers, id)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to read log of container %s: %w", shortContainerID(id), err))
+		}
+	}
+
+	return events, errors.Join(errs...)
+}
+
+// Positions возвращает позиции чтения журналов контейнеров. Пока собирается
+// разбитая на части строка, позиция указывает на её начало
+func (dc *DockerCollector) Positions() map[string]checkpoint.Position {
+	positions := make(map[string]checkpoint.Position, len(dc.containers))
+	for _, container := range dc.containers {
+		pos := container.tailer.position()
+		for _, partial := range container.partials {
+			if partial.inode == pos.Inode && partial.offset < pos.Offset {
+				pos.Offset = partial.offset
+			}
+		}
+		positions[pos.Path] = pos
+	}
+	return positions
+}
+
+// RestorePositions запоминает сохранённые позиции. Они применяются, когда
+// журнал контейнера будет найден
+func (dc *DockerCollector) RestorePositions(positions map[string]checkpoint.Position) {
+	for path, pos := range positions {
+		dc.restored[path] = pos
+	}
+	for _, container := range dc.containers {
+		if pos, ok := dc.restored[container.tailer.path]; ok {
+			container.tailer.restore(pos)
+			delete(dc.restored, container.tailer.path)
+		}
+	}
+}
+
+func (dc *DockerCollector) GetSourceName() string {
+	return "docker"
+}
+
+func (dc *DockerCollector) GetSourceType() string {
+	return "container"
+}
+
+// discover находит журналы новых контейнеров
+func (dc *DockerCollector) discover() {
+	matches, err := filepath.Glob(filepath.Join(dc.root, "*", "*-json.log"))
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		id := filepath.Base(filepath.Dir(path))
+		if _, known := dc.containers[id]; known || filepath.Base(path) != id+"-json.log" {
+			continue
+		}
+
+		container := &containerLog{id: id, tailer: newFileTailer(path), partials: make(map[string]*dockerPartial)}
+		if pos, ok := dc.restored[path]; ok {
+			container.tailer.restore(pos)
+			delete(dc.restored, path)
+		}
+		dc.refreshMetadata(container)
+		dc.containers[id] = container
+		log.Printf("[Collector] docker: following container %s (%s, %s)", shortContainerID(id), container.name, container.image)
+	}
+}
+
+// refreshMetadata перечитывает имя и образ контейнера, если config.v2.json
+// изменился (например, контейнер переименовали)
+func (dc *DockerCollector) refreshMetadata(container *containerLog) {
+	path := filepath.Join(dc.root, container.id, "config.v2.json")
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().Equal(container.metaTime) {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var config dockerContainerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("[Collector] docker: failed to parse %s: %v", path, err)
+		return
+	}
+
+	container.name = strings.TrimPrefix(config.Name, "/")
+	container.image = config.Config.Image
+	container.imageID = config.Image
+	container.metaTime = info.ModTime()
+}
+
+// parseLine разбирает строку журнала. false — строка пустая или это часть
+// длинной строки, продолжение которой ещё не прочитано
+func (dc *DockerCollector) parseLine(container *containerLog, line string) (Event, bool) {
+	if strings.TrimSpace(line) == "" {
+		return Event{}, false
+	}
+
+	var entry dockerLogEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		event := dc.newEvent(container, line, "", "")
+		event.Fields["parse_error"] = "true"
+		return event, true
+	}
+
+	partial := container.partials[entry.Stream]
+	if !strings.HasSuffix(entry.Log, "\n") {
+		if partial == nil {
+			partial = &dockerPartial{
+				time:   entry.Time,
+				offset: container.tailer.offset,
+				inode:  container.tailer.inode,
+			}
+			container.partials[entry.Stream] = partial
+		}
+		partial.text.WriteString(entry.Log)
+		return Event{}, false
+	}
+
+	message := entry.Log
+	if partial != nil {
+		partial.text.WriteString(message)
+		message = partial.text.String()
+		entry.Time = partial.time
+		delete(container.partials, entry.Stream)
+	}
+
+	return dc.newEvent(container, strings.TrimRight(message, "\r\n"), entry.Stream, entry.Time), true
+}
+
+// newEvent создаёт событие строки журнала контейнера
+func (dc *DockerCollector) newEvent(container *containerLog, message, stream, timestamp string) Event {
+	event := Event{
+		RawLog:    message,
+		Source:    "docker",
+		EventType: "container_log",
+		Severity:  determineSeverity(message),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Process:   container.name,
+		Fields: map[string]string{
+			"container_id":   container.id,
+			"container_name": container.name,
+			"image":          container.image,
+			"image_id":       container.imageID,
+			"stream":         stream,
+		},
+	}
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		event.Timestamp = t.Format(time.RFC3339Nano)
+	}
+	return event
+}
+
+// shortContainerID первые 12 символов идентификатора, как в docker ps
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testContainerID = "3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e"
+
+// newDockerRoot создаёт каталог контейнеров с одним контейнером и возвращает путь к его журналу
+func newDockerRoot(t *testing.T) (root, logPath string) {
+	t.Helper()
+	root = t.TempDir()
+	dir := filepath.Join(root, testContainerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"ID":"` + testContainerID + `","Name":"/web","Image":"sha256:abc","Config":{"Image":"nginx:1.25"}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.v2.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logPath = filepath.Join(dir, testContainerID+"-json.log")
+	if err := os.WriteFile(logPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root, logPath
+}
+
+func dockerLine(stream, text string) string {
+	return `{"log":"` + text + `","stream":"` + stream + `","time":"2024-03-01T12:00:00.123456789Z"}` + "\n"
+}
+
+func TestDockerCollectorParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    string
+		messages []string
+		streams  []string
+	}{
+		{
+			name:     "complete lines",
+			lines:    dockerLine("stdout", `GET / 200\n`) + dockerLine("stderr", `error: upstream timed out\n`),
+			messages: []string{"GET / 200", "error: upstream timed out"},
+			streams:  []string{"stdout", "stderr"},
+		},
+		{
+			name:     "split line",
+			lines:    dockerLine("stdout", "part one ") + dockerLine("stdout", "part two ") + dockerLine("stdout", `end\n`),
+			messages: []string{"part one part two end"},
+			streams:  []string{"stdout"},
+		},
+		{
+			name: "interleaved streams",
+			lines: dockerLine("stdout", "out start ") + dockerLine("stderr", "err start ") +
+				dockerLine("stdout", `out end\n`) + dockerLine("stderr", `err end\n`),
+			messages: []string{"out start out end", "err start err end"},
+			streams:  []string{"stdout", "stderr"},
+		},
+		{
+			name:     "crlf and blank lines",
+			lines:    dockerLine("stdout", `windows\r\n`) + "\n",
+			messages: []string{"windows"},
+			streams:  []string{"stdout"},
+		},
+		{
+			name:     "not json",
+			lines:    "garbage\n",
+			messages: []string{"garbage"},
+			streams:  []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, logPath := newDockerRoot(t)
+			appendFile(t, logPath, tt.lines)
+
+			events, err := NewDockerCollector(root).Collect()
+			if err != nil {
+				t.Fatalf("Collect: %v", err)
+			}
+			if len(events) != len(tt.messages) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.messages))
+			}
+			for i, event := range events {
+				if event.RawLog != tt.messages[i] || event.Fields["stream"] != tt.streams[i] {
+					t.Errorf("event %d = %q (%s), want %q (%s)", i, event.RawLog, event.Fields["stream"], tt.messages[i], tt.streams[i])
+				}
+				if event.Process != "web" || event.Fields["image"] != "nginx:1.25" || event.Fields["container_id"] != testContainerID {
+					t.Errorf("event %d metadata = %s %v", i, event.Process, event.Fields)
+				}
+			}
+			if events[0].Fields["parse_error"] == "" && events[0].Timestamp != "2024-03-01T12:00:00.123456789Z" {
+				t.Errorf("timestamp = %s", events[0].Timestamp)
+			}
+		})
+	}
+}
+
+func TestDockerCollectorPartialPosition(t *testing.T) {
+	root, logPath := newDockerRoot(t)
+	complete := dockerLine("stdout", `ready\n`)
+	appendFile(t, logPath, complete+dockerLine("stderr", "half "))
+
+	dc := NewDockerCollector(root)
+	if events, _ := dc.Collect(); len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	// Позиция указывает на начало незавершённой строки
+	positions := dc.Positions()
+	if pos := positions[logPath]; pos.Offset != int64(len(complete)) {
+		t.Fatalf("position = %d, want %d", pos.Offset, len(complete))
+	}
+
+	appendFile(t, logPath, dockerLine("stdout", `other\n`)+dockerLine("stderr", `done\n`))
+	events, _ := dc.Collect()
+	if len(events) != 2 || events[0].RawLog != "other" || events[1].RawLog != "half done" {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// После перезапуска строка читается заново целиком
+	restarted := NewDockerCollector(root)
+	restarted.RestorePositions(positions)
+	events, _ = restarted.Collect()
+	var messages []string
+	for _, event := range events {
+		messages = append(messages, event.RawLog)
+	}
+	if strings.Join(messages, "|") != "other|half done" {
+		t.Fatalf("after restart: %v", messages)
+	}
+}
+
+func TestDockerCollectorRemovedContainer(t *testing.T) {
+	root, logPath := newDockerRoot(t)
+	appendFile(t, logPath, dockerLine("stdout", `hello\n`))
+
+	dc := NewDockerCollector(root)
+	dc.Collect()
+	if _, ok := dc.Positions()[logPath]; !ok {
+		t.Fatal("container position missing")
+	}
+
+	if err := os.RemoveAll(filepath.Dir(logPath)); err != nil {
+		t.Fatal(err)
+	}
+	dc.Collect()
+	if positions := dc.Positions(); len(positions) != 0 {
+		t.Fatalf("positions after removal = %v", positions)
+	}
+}
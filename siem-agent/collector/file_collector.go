@@ -0,0 +1,218 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// FileCollectorConfig настройки сборщика произвольных файлов
+type FileCollectorConfig struct {
+	Name            string
+	Paths           []string          // маски файлов
+	Format          string            // plain, regex, json, kv, cef, leef
+	Pattern         string            // выражение для формата regex
+	TimestampLayout string            // формат времени в нотации Go
+	Mapping         map[string]string // поле события -> поле записи
+}
+
+// FileCollector читает все файлы, подходящие под маски, и разбирает строки
+// заданным форматом. Маски проверяются при каждом сборе, поэтому новые файлы
+// и каталоги подхватываются по мере появления
+type FileCollector struct {
+	name     string
+	patterns []string
+	parse    recordParser
+	layout   string
+	mapping  map[string]string
+
+	tailers  map[string]*fileTailer
+	restored map[string]checkpoint.Position // позиции ещё не найденных файлов
+}
+
+// NewFileCollector создаёт сборщик файлов по маскам
+func NewFileCollector(cfg FileCollectorConfig) (*FileCollector, error) {
+	parse, err := newRecordParser(cfg.Format, cfg.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Явное отображение дополняет стандартное для формата
+	mapping := make(map[string]string)
+	for target, field := range defaultMappings[cfg.Format] {
+		mapping[target] = field
+	}
+	for target, field := range cfg.Mapping {
+		mapping[target] = field
+	}
+
+	return &FileCollector{
+		name:     cfg.Name,
+		patterns: cfg.Paths,
+		parse:    parse,
+		layout:   cfg.TimestampLayout,
+		mapping:  mapping,
+		tailers:  make(map[string]*fileTailer),
+		restored: make(map[string]checkpoint.Position),
+	}, nil
+}
+
+func (fc *FileCollector) Collect() ([]Event, error) {
+	fc.discover()
+
+	paths := make([]string, 0, len(fc.tailers))
+	for path := range fc.tailers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var events []Event
+	var errs []error
+	for _, path := range paths {
+		tailer := fc.tailers[path]
+		err := tailer.readLines(func(line string) {
+			if strings.TrimSpace(line) != "" {
+				events = append(events, fc.parseLine(path, line))
+			}
+		})
+		switch {
+		case os.IsNotExist(err):
+			// Файл удалён: если он снова появится, прочитаем его с начала
+			delete(fc.tailers, path)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to read %s: %w", path, err))
+		}
+	}
+
+	return events, errors.Join(errs...)
+}
+
+// discover находит новые файлы, подходящие под маски
+func (fc *FileCollector) discover() {
+	for _, pattern := range fc.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			if _, known := fc.tailers[path]; known || strings.HasSuffix(path, ".gz") {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+
+			tailer := newFileTailer(path)
+			if pos, ok := fc.restored[path]; ok {
+				tailer.restore(pos)
+				delete(fc.restored, path)
+			}
+			fc.tailers[path] = tailer
+			log.Printf("[Collector] %s: following %s", fc.name, path)
+		}
+	}
+}
+
+// Positions возвращает позиции чтения всех найденных файлов
+func (fc *FileCollector) Positions() map[string]checkpoint.Position {
+	positions := make(map[string]checkpoint.Position, len(fc.tailers))
+	for path, tailer := range fc.tailers {
+		positions[path] = tailer.position()
+	}
+	return positions
+}
+
+// RestorePositions запоминает сохранённые позиции. Они применяются, когда
+// файл будет найден по маске
+func (fc *FileCollector) RestorePositions(positions map[string]checkpoint.Position) {
+	for path, pos := range positions {
+		if tailer, ok := fc.tailers[path]; ok {
+			tailer.restore(pos)
+			continue
+		}
+		fc.restored[path] = pos
+	}
+}
+
+// WatchPaths возвращает каталоги масок. Для масок с шаблоном в имени каталога
+// наблюдаем за ближайшим каталогом без шаблона
+func (fc *FileCollector) WatchPaths() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, pattern := range fc.patterns {
+		dir := filepath.Dir(pattern)
+		for strings.ContainsAny(dir, `*?[\`) {
+			dir = filepath.Dir(dir)
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (fc *FileCollector) GetSourceName() string {
+	return fc.name
+}
+
+func (fc *FileCollector) GetSourceType() string {
+	return "file"
+}
+
+// parseLine разбирает строку и заполняет событие по отображению полей
+func (fc *FileCollector) parseLine(path, line string) Event {
+	event := Event{
+		RawLog:    line,
+		Source:    fc.name,
+		EventType: "file_event",
+		Severity:  determineSeverity(line),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+	}
+
+	fields, ok := fc.parse(line)
+	if !ok {
+		event.Fields = map[string]string{"file": path, "parse_error": "true"}
+		return event
+	}
+	if _, exists := fields["file"]; !exists {
+		fields["file"] = path
+	}
+	event.Fields = fields
+
+	for target, field := range fc.mapping {
+		value := fields[field]
+		if value == "" {
+			continue
+		}
+
+		switch target {
+		case "timestamp":
+			if t, ok := parseTimestamp(value, fc.layout); ok {
+				event.Timestamp = t.Format(time.RFC3339)
+			}
+		case "hostname":
+			event.Hostname = value
+		case "event_type":
+			event.EventType = value
+		case "severity":
+			event.Severity = normalizeSeverity(value)
+		case "user":
+			event.User = value
+		case "process":
+			event.Process = value
+		case "command":
+			event.Command = value
+		}
+	}
+
+	return event
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCollectorFollowsGlob(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "app-1.log")
+	appendFile(t, first, "level=info msg=started\n")
+
+	fc, err := NewFileCollector(FileCollectorConfig{Name: "app", Paths: []string{filepath.Join(dir, "*.log")}, Format: "kv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collectRawLogs(t, fc); len(got) != 1 {
+		t.Fatalf("first read: %v", got)
+	}
+
+	// Новый файл по маске подхватывается при следующем сборе
+	second := filepath.Join(dir, "app-2.log")
+	appendFile(t, second, "level=error msg=failed\n")
+	if got := collectRawLogs(t, fc); len(got) != 1 || got[0] != "level=error msg=failed" {
+		t.Fatalf("new file: %v", got)
+	}
+	if positions := fc.Positions(); len(positions) != 2 {
+		t.Fatalf("positions = %v, want 2 files", positions)
+	}
+
+	// Удалённый файл пропадает из позиций, и агент удаляет его позицию
+	if err := os.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	collectRawLogs(t, fc)
+	positions := fc.Positions()
+	if _, ok := positions[first]; ok || len(positions) != 1 {
+		t.Fatalf("positions after removal = %v, want only %s", positions, second)
+	}
+}
+
+func TestFileCollectorRestoresFoundFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old line\n")
+	cfg := FileCollectorConfig{Name: "app", Paths: []string{filepath.Join(dir, "*.log")}, Format: "plain"}
+
+	fc, err := NewFileCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectRawLogs(t, fc)
+	positions := fc.Positions()
+
+	// Позиция применяется, когда файл найден по маске
+	appendFile(t, path, "new line\n")
+	restarted, err := NewFileCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.RestorePositions(positions)
+	if got := collectRawLogs(t, restarted); len(got) != 1 || got[0] != "new line" {
+		t.Fatalf("after restore: %v, want [new line]", got)
+	}
+}
+
+func collectRawLogs(t *testing.T, col Collector) []string {
+	t.Helper()
+	events, err := col.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var lines []string
+	for _, event := range events {
+		lines = append(lines, event.RawLog)
+	}
+	return lines
+}
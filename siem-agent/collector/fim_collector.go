@@ -0,0 +1,495 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"agent/checkpoint"
+)
+
+const (
+	// fimBaselineFile имя файла эталона в каталоге состояния
+	fimBaselineFile = "fim_baseline.json"
+
+	// fimMaxHashSize файлы больше этого размера не хешируются, изменения
+	// определяются по размеру и времени модификации
+	fimMaxHashSize = 64 * 1024 * 1024
+)
+
+// fimCriticalPaths файлы, изменение которых — критическое событие
+var fimCriticalPaths = []string{
+	"/etc/passwd", "/etc/shadow", "/etc/group", "/etc/gshadow",
+	"/etc/sudoers", "/etc/sudoers.d/*", "/etc/ssh/sshd_config",
+}
+
+// fimEntry состояние файла в эталоне
+type fimEntry struct {
+	SHA256 string `json:"sha256"`
+	Mode   string `json:"mode"`
+	UID    string `json:"uid"`
+	GID    string `json:"gid"`
+	Size   int64  `json:"size"`
+	Mtime  int64  `json:"mtime"` // наносекунды
+	Ctime  int64  `json:"ctime"` // наносекунды
+	Inode  uint64 `json:"inode"`
+}
+
+// fimState эталон на диске: маски, для которых он снят, и состояния файлов
+type fimState struct {
+	Patterns []string            `json:"patterns"`
+	Files    map[string]fimEntry `json:"files"`
+}
+
+// fimSnapshot состояние эталона, ожидающее доставки событий о его изменениях
+type fimSnapshot struct {
+	generation int64
+	files      map[string]fimEntry
+}
+
+// FIMCollector контролирует целостность файлов: сравнивает SHA-256, права,
+// владельца и время модификации с сохранённым эталоном. Эталон сохраняется
+// на диск только после доставки событий об изменениях, иначе после
+// перезапуска они были бы потеряны
+type FIMCollector struct {
+	patterns     []string
+	baselinePath string
+
+	mu         sync.Mutex
+	baseline   map[string]fimEntry // состояние, о котором уже сообщили
+	loaded     bool
+	unreadable map[string]bool // файлы, об ошибке чтения которых уже сообщили
+	generation int64           // номер последнего состояния эталона
+	pending    []fimSnapshot   // состояния, ещё не сохранённые на диск
+}
+
+// NewFIMCollector создаёт сборщик для масок patterns. Эталон хранится в stateDir
+func NewFIMCollector(patterns []string, stateDir string) *FIMCollector {
+	return &FIMCollector{
+		patterns:     patterns,
+		baselinePath: filepath.Join(stateDir, fimBaselineFile),
+		unreadable:   make(map[string]bool),
+	}
+}
+
+func (fc *FIMCollector) Collect() ([]Event, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	firstRun := false
+	var previousPatterns []string
+	if !fc.loaded {
+		state, err := loadFIMBaseline(fc.baselinePath)
+		if err != nil {
+			return nil, err
+		}
+		firstRun = state == nil
+		if firstRun {
+			state = &fimState{Files: make(map[string]fimEntry)}
+		}
+		fc.baseline = state.Files
+		previousPatterns = state.Patterns
+		fc.loaded = true
+	}
+
+	current := make(map[string]fimEntry)
+	for _, path := range fc.matchingFiles() {
+		entry, err := fc.scanFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			// Файл есть, но не читается (нет прав): не считаем его удалённым
+			if !fc.unreadable[path] {
+				log.Printf("[Collector] FIM: failed to scan %s: %v", path, err)
+				fc.unreadable[path] = true
+			}
+			if previous, ok := fc.baseline[path]; ok {
+				current[path] = previous
+			}
+			continue
+		}
+		delete(fc.unreadable, path)
+		current[path] = entry
+	}
+
+	if firstRun {
+		log.Printf("[Collector] FIM: baseline created for %d files", len(current))
+		fc.baseline = current
+		return nil, fc.save(current)
+	}
+
+	// Маски изменились в конфигурации: файлы вне масок забываем, файлы,
+	// попавшие под новые маски, берём в эталон без событий
+	adjusted := fc.applyPatternChange(current, previousPatterns)
+
+	events := fc.compare(current)
+	fc.baseline = current
+	if len(events) > 0 || adjusted {
+		fc.generation++
+		fc.pending = append(fc.pending, fimSnapshot{generation: fc.generation, files: current})
+	}
+
+	return events, nil
+}
+
+// applyPatternChange приводит загруженный эталон к текущим маскам.
+// true — эталон изменился
+func (fc *FIMCollector) applyPatternChange(current map[string]fimEntry, previousPatterns []string) bool {
+	adjusted := false
+	for path := range fc.baseline {
+		if !fimCovered(fc.patterns, path) {
+			delete(fc.baseline, path)
+			adjusted = true
+		}
+	}
+
+	if previousPatterns == nil || samePatterns(previousPatterns, fc.patterns) {
+		return adjusted
+	}
+	added := 0
+	for path, entry := range current {
+		if _, known := fc.baseline[path]; !known && !fimCovered(previousPatterns, path) {
+			fc.baseline[path] = entry
+			added++
+		}
+	}
+	if added > 0 {
+		log.Printf("[Collector] FIM: %d files added to baseline after pattern change", added)
+	}
+	return true
+}
+
+// Position возвращает номер текущего состояния эталона
+func (fc *FIMCollector) Position() checkpoint.Position {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return checkpoint.Position{Path: fc.baselinePath, Offset: fc.generation}
+}
+
+// Restore продолжает нумерацию состояний эталона. Сам эталон читается из файла
+func (fc *FIMCollector) Restore(pos checkpoint.Position) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.generation = pos.Offset
+}
+
+// Committed сохраняет эталон, события об изменениях которого доставлены
+func (fc *FIMCollector) Committed(pos checkpoint.Position) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	delivered := -1
+	for i, snapshot := range fc.pending {
+		if snapshot.generation <= pos.Offset {
+			delivered = i
+		}
+	}
+	if delivered < 0 {
+		return
+	}
+	if err := fc.save(fc.pending[delivered].files); err != nil {
+		log.Printf("[Collector] FIM: %v", err)
+		return
+	}
+	fc.pending = fc.pending[delivered+1:]
+}
+
+// save записывает эталон вместе с текущими масками
+func (fc *FIMCollector) save(files map[string]fimEntry) error {
+	return saveFIMBaseline(fc.baselinePath, &fimState{Patterns: fc.patterns, Files: files})
+}
+
+// WatchPaths возвращает каталоги контролируемых файлов
+func (fc *FIMCollector) WatchPaths() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, pattern := range fc.patterns {
+		dir := filepath.Dir(pattern)
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			dir = pattern
+		}
+		for strings.ContainsAny(dir, `*?[\`) {
+			dir = filepath.Dir(dir)
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (fc *FIMCollector) GetSourceName() string {
+	return "fim"
+}
+
+func (fc *FIMCollector) GetSourceType() string {
+	return "fim"
+}
+
+// matchingFiles возвращает обычные файлы, подходящие под маски. Каталог
+// в масках означает все файлы в нём
+func (fc *FIMCollector) matchingFiles() []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range fc.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				add(filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// scanFile снимает состояние файла. Хеш пересчитывается, только если
+// изменились размер, mtime, ctime или inode
+func (fc *FIMCollector) scanFile(path string) (fimEntry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fimEntry{}, err
+	}
+
+	entry := fimEntry{
+		Mode:  formatFIMMode(info.Mode()),
+		Size:  info.Size(),
+		Mtime: info.ModTime().UnixNano(),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.UID = strconv.FormatUint(uint64(stat.Uid), 10)
+		entry.GID = strconv.FormatUint(uint64(stat.Gid), 10)
+		entry.Ctime = time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec)).UnixNano()
+		entry.Inode = uint64(stat.Ino)
+	}
+
+	previous, known := fc.baseline[path]
+	if known && previous.Size == entry.Size && previous.Mtime == entry.Mtime &&
+		previous.Ctime == entry.Ctime && previous.Inode == entry.Inode {
+		entry.SHA256 = previous.SHA256
+		return entry, nil
+	}
+
+	if entry.Size <= fimMaxHashSize {
+		hash, err := hashFile(path)
+		if err != nil {
+			return fimEntry{}, err
+		}
+		entry.SHA256 = hash
+	}
+	return entry, nil
+}
+
+// compare сравнивает текущее состояние с эталоном и создаёт события
+func (fc *FIMCollector) compare(current map[string]fimEntry) []Event {
+	paths := make([]string, 0, len(current)+len(fc.baseline))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	for path := range fc.baseline {
+		if _, ok := current[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var events []Event
+	for _, path := range paths {
+		before, existed := fc.baseline[path]
+		after, exists := current[path]
+
+		switch {
+		case !existed:
+			event := fimEvent("file_created", path)
+			addFIMValues(event.Fields, "after", after)
+			events = append(events, event)
+		case !exists:
+			event := fimEvent("file_deleted", path)
+			addFIMValues(event.Fields, "before", before)
+			events = append(events, event)
+		default:
+			contentChanged := before.SHA256 != after.SHA256 || before.Size != after.Size ||
+				(after.SHA256 == "" && before.Mtime != after.Mtime)
+			permsChanged := before.Mode != after.Mode || before.UID != after.UID || before.GID != after.GID
+
+			if contentChanged {
+				event := fimEvent("file_modified", path)
+				addFIMValues(event.Fields, "before", before)
+				addFIMValues(event.Fields, "after", after)
+				events = append(events, event)
+			}
+			if permsChanged {
+				event := fimEvent("permission_changed", path)
+				addFIMValues(event.Fields, "before", before)
+				addFIMValues(event.Fields, "after", after)
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// fimEvent создаёт событие изменения файла
+func fimEvent(eventType, path string) Event {
+	severity := "WARNING"
+	for _, pattern := range fimCriticalPaths {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			severity = "CRITICAL"
+			break
+		}
+	}
+
+	return Event{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Source:    "fim",
+		EventType: eventType,
+		Severity:  severity,
+		Process:   "fim",
+		RawLog:    eventType + " " + path,
+		Fields:    map[string]string{"path": path},
+	}
+}
+
+// addFIMValues добавляет значения состояния файла с суффиксом _before или _after
+func addFIMValues(fields map[string]string, suffix string, entry fimEntry) {
+	fields["sha256_"+suffix] = entry.SHA256
+	fields["mode_"+suffix] = entry.Mode
+	fields["uid_"+suffix] = entry.UID
+	fields["gid_"+suffix] = entry.GID
+	fields["size_"+suffix] = strconv.FormatInt(entry.Size, 10)
+	fields["mtime_"+suffix] = time.Unix(0, entry.Mtime).Format(time.RFC3339)
+}
+
+// formatFIMMode права файла в восьмеричном виде с битами setuid/setgid/sticky
+func formatFIMMode(mode os.FileMode) string {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+	return fmt.Sprintf("%04o", perm)
+}
+
+// hashFile считает SHA-256 содержимого файла
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fimCovered проверяет, попадает ли файл под маски: под саму маску или
+// в каталог, подходящий под маску
+func fimCovered(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Dir(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// samePatterns сравнивает наборы масок без учёта порядка
+func samePatterns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// loadFIMBaseline читает эталон. nil без ошибки означает, что эталона ещё нет
+func loadFIMBaseline(path string) (*fimState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FIM baseline: %w", err)
+	}
+
+	var state fimState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse FIM baseline %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]fimEntry)
+	}
+	return &state, nil
+}
+
+// saveFIMBaseline сохраняет эталон
+func saveFIMBaseline(path string, state *fimState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal FIM baseline: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	if err := checkpoint.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to save FIM baseline: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFIMFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collectFIM(t *testing.T, fc *FIMCollector) []string {
+	t.Helper()
+	events, err := fc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var changes []string
+	for _, event := range events {
+		changes = append(changes, event.EventType+" "+filepath.Base(event.Fields["path"]))
+	}
+	return changes
+}
+
+func TestFIMBaselineSavedAfterDelivery(t *testing.T) {
+	watched, state := t.TempDir(), t.TempDir()
+	patterns := []string{filepath.Join(watched, "*")}
+	writeFIMFile(t, filepath.Join(watched, "sudoers"), "root ALL=(ALL) ALL\n")
+
+	fc := NewFIMCollector(patterns, state)
+	if changes := collectFIM(t, fc); len(changes) != 0 {
+		t.Fatalf("first run reported %v", changes)
+	}
+
+	writeFIMFile(t, filepath.Join(watched, "sudoers"), "root ALL=(ALL) ALL\nmallory ALL=(ALL) NOPASSWD: ALL\n")
+	if changes := collectFIM(t, fc); len(changes) != 1 || changes[0] != "file_modified sudoers" {
+		t.Fatalf("changes = %v, want [file_modified sudoers]", changes)
+	}
+	if changes := collectFIM(t, fc); len(changes) != 0 {
+		t.Fatalf("change reported twice: %v", changes)
+	}
+
+	// События не доставлены: после перезапуска изменение обнаруживается снова
+	restarted := NewFIMCollector(patterns, state)
+	if changes := collectFIM(t, restarted); len(changes) != 1 || changes[0] != "file_modified sudoers" {
+		t.Fatalf("after restart before delivery: %v, want [file_modified sudoers]", changes)
+	}
+
+	fc.Committed(fc.Position())
+	restarted = NewFIMCollector(patterns, state)
+	if changes := collectFIM(t, restarted); len(changes) != 0 {
+		t.Fatalf("after delivery and restart: %v, want none", changes)
+	}
+}
+
+func TestFIMCommittedSavesDeliveredState(t *testing.T) {
+	watched, state := t.TempDir(), t.TempDir()
+	patterns := []string{watched}
+	writeFIMFile(t, filepath.Join(watched, "a"), "1")
+
+	fc := NewFIMCollector(patterns, state)
+	collectFIM(t, fc)
+
+	writeFIMFile(t, filepath.Join(watched, "a"), "22")
+	collectFIM(t, fc)
+	delivered := fc.Position()
+
+	writeFIMFile(t, filepath.Join(watched, "b"), "3")
+	collectFIM(t, fc)
+
+	// Доставлено только первое изменение: второе после перезапуска повторится
+	fc.Committed(delivered)
+	restarted := NewFIMCollector(patterns, state)
+	if changes := collectFIM(t, restarted); len(changes) != 1 || changes[0] != "file_created b" {
+		t.Fatalf("changes = %v, want [file_created b]", changes)
+	}
+}
+
+func TestFIMPatternChange(t *testing.T) {
+	watched, state := t.TempDir(), t.TempDir()
+	writeFIMFile(t, filepath.Join(watched, "app.conf"), "a")
+	writeFIMFile(t, filepath.Join(watched, "db.conf"), "b")
+	writeFIMFile(t, filepath.Join(watched, "shared.conf"), "c")
+
+	old := []string{filepath.Join(watched, "app.conf"), filepath.Join(watched, "shared.conf")}
+	collectFIM(t, NewFIMCollector(old, state))
+
+	// Новые маски: app.conf больше не контролируется, db.conf контролируется
+	updated := []string{filepath.Join(watched, "db.conf"), filepath.Join(watched, "shared.conf"), filepath.Join(watched, "new.conf")}
+	writeFIMFile(t, filepath.Join(watched, "shared.conf"), "changed")
+	fc := NewFIMCollector(updated, state)
+	if changes := collectFIM(t, fc); len(changes) != 1 || changes[0] != "file_modified shared.conf" {
+		t.Fatalf("changes = %v, want only [file_modified shared.conf]", changes)
+	}
+
+	writeFIMFile(t, filepath.Join(watched, "new.conf"), "d")
+	writeFIMFile(t, filepath.Join(watched, "db.conf"), "bb")
+	changes := collectFIM(t, fc)
+	if len(changes) != 2 || changes[0] != "file_modified db.conf" || changes[1] != "file_created new.conf" {
+		t.Fatalf("changes = %v, want [file_modified db.conf file_created new.conf]", changes)
+	}
+
+	fc.Committed(fc.Position())
+	if changes := collectFIM(t, NewFIMCollector(updated, state)); len(changes) != 0 {
+		t.Fatalf("after restart: %v, want none", changes)
+	}
+}
+
+func TestFIMCovered(t *testing.T) {
+	patterns := []string{"/etc/passwd", "/etc/sudoers.d", "/etc/ssh/*.conf"}
+	tests := map[string]bool{
+		"/etc/passwd":               true,
+		"/etc/sudoers.d/90-cloud":   true,
+		"/etc/ssh/sshd.conf":        true,
+		"/etc/ssh/sshd_config":      false,
+		"/etc/shadow":               false,
+		"/etc/sudoers.d/sub/nested": false,
+	}
+	for path, want := range tests {
+		if got := fimCovered(patterns, path); got != want {
+			t.Errorf("fimCovered(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
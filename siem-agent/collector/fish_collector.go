@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// fishEntry запись fish_history: строка "- cmd: ...", за ней "when: <epoch>"
+// и необязательный список "paths:"
+type fishEntry struct {
+	command string
+	when    time.Time
+	paths   []string
+	raw     []string
+}
+
+// FishCollector читает историю fish (~/.local/share/fish/fish_history)
+type FishCollector struct {
+	user   string
+	tailer *fileTailer
+	seen   historySeen
+}
+
+// NewFishCollector создаёт сборщик истории fish пользователя
+func NewFishCollector(user, historyPath string) *FishCollector {
+	return &FishCollector{
+		user:   user,
+		tailer: newFileTailer(historyPath),
+	}
+}
+
+func (fc *FishCollector) Collect() ([]Event, error) {
+	var events []Event
+	var entry *fishEntry
+
+	flush := func() {
+		if entry == nil {
+			return
+		}
+		if event, ok := fc.parseEntry(entry); ok {
+			events = append(events, event)
+		}
+		entry = nil
+	}
+
+	err := readHistory(fc.tailer, func(line string) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "- cmd:"):
+			flush()
+			entry = &fishEntry{command: unescapeFish(strings.TrimSpace(strings.TrimPrefix(line, "- cmd:")))}
+		case entry == nil:
+			return
+		case strings.HasPrefix(trimmed, "when:"):
+			seconds, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(trimmed, "when:")), 10, 64)
+			if err == nil {
+				entry.when = time.Unix(seconds, 0)
+			}
+		case strings.HasPrefix(trimmed, "- "):
+			entry.paths = append(entry.paths, unescapeFish(strings.TrimPrefix(trimmed, "- ")))
+		}
+		entry.raw = append(entry.raw, line)
+	})
+	// fish дописывает запись целиком, поэтому последняя прочитанная запись завершена
+	flush()
+
+	if err != nil {
+		return events, fmt.Errorf("failed to read fish history file: %w", err)
+	}
+
+	return events, nil
+}
+
+// Position возвращает текущую позицию чтения и недавно выданные команды
+func (fc *FishCollector) Position() checkpoint.Position {
+	pos := fc.tailer.position()
+	pos.Seen = fc.seen.encode()
+	return pos
+}
+
+// Restore восстанавливает сохранённую позицию чтения и уже выданные команды
+func (fc *FishCollector) Restore(pos checkpoint.Position) {
+	fc.tailer.restore(pos)
+	fc.seen.decode(pos.Seen)
+}
+
+// WatchPaths возвращает файл, за которым нужно следить
+func (fc *FishCollector) WatchPaths() []string {
+	return []string{fc.tailer.path}
+}
+
+func (fc *FishCollector) GetSourceName() string {
+	return "fish_history_" + fc.user
+}
+
+func (fc *FishCollector) GetSourceType() string {
+	return "fish_history"
+}
+
+// parseEntry превращает запись истории в событие. Возвращает false для пустой
+// или уже выданной команды
+func (fc *FishCollector) parseEntry(entry *fishEntry) (Event, bool) {
+	if entry.command == "" {
+		return Event{}, false
+	}
+	if !entry.when.IsZero() && !fc.seen.isNew(entry.when, entry.command) {
+		return Event{}, false
+	}
+
+	event := shellCommandEvent("fish_history", "fish", fc.user, entry.command, entry.when)
+	event.RawLog = strings.Join(entry.raw, "\n")
+	if len(entry.paths) > 0 {
+		event.Fields = map[string]string{"paths": strings.Join(entry.paths, " ")}
+	}
+	return event, true
+}
+
+// unescapeFish раскрывает экранирование fish: "\n" — перевод строки, "\\" — обратная косая черта
+func unescapeFish(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			switch value[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
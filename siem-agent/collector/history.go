@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readHistory читает новые строки файла истории. Пока файла нет (пользователь
+// ещё не выполнил ни одной команды), это не ошибка: чтение начнётся, когда он появится
+func readHistory(tailer *fileTailer, handle func(line string)) error {
+	if err := tailer.readLines(handle); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// historySeenLimit сколько последних по времени команд помнит historySeen
+const historySeenLimit = 256
+
+// historyKey команда истории: время выполнения и хеш текста
+type historyKey struct {
+	when int64
+	hash uint64
+}
+
+// historySeen отбрасывает команды, уже выданные ранее. zsh и fish иногда
+// переписывают файл истории целиком (через новый файл и rename), и тогда он
+// читается с начала. Команды, записанные в файл позже более новых (zsh
+// с INC_APPEND_HISTORY, параллельные сессии), выдаются: сравнивается пара
+// время и команда, а не только время. Помнятся historySeenLimit самых новых
+// команд; всё, что не новее вытесненных, считается выданным
+type historySeen struct {
+	floor   int64        // время последней вытесненной команды, 0 — вытеснений не было
+	entries []historyKey // по возрастанию времени
+	keys    map[historyKey]bool
+}
+
+// isNew проверяет, что команда ещё не выдавалась, и запоминает её
+func (hs *historySeen) isNew(when time.Time, command string) bool {
+	key := historyKey{when: when.Unix(), hash: hashCommand(command)}
+	if hs.keys[key] || (hs.floor != 0 && key.when <= hs.floor) {
+		return false
+	}
+	if hs.keys == nil {
+		hs.keys = make(map[historyKey]bool)
+	}
+
+	i := sort.Search(len(hs.entries), func(i int) bool { return hs.entries[i].when > key.when })
+	hs.entries = append(hs.entries, historyKey{})
+	copy(hs.entries[i+1:], hs.entries[i:])
+	hs.entries[i] = key
+	hs.keys[key] = true
+
+	if len(hs.entries) > historySeenLimit {
+		evicted := hs.entries[0]
+		hs.entries = hs.entries[1:]
+		delete(hs.keys, evicted)
+		if evicted.when > hs.floor {
+			hs.floor = evicted.when
+		}
+	}
+	return true
+}
+
+// encode сохраняет состояние для позиции чтения: "floor;when:hash,..." в hex
+func (hs *historySeen) encode() string {
+	if len(hs.entries) == 0 && hs.floor == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatInt(hs.floor, 16))
+	sb.WriteByte(';')
+	for i, key := range hs.entries {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatInt(key.when, 16))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.FormatUint(key.hash, 16))
+	}
+	return sb.String()
+}
+
+// decode восстанавливает состояние из позиции чтения. Повреждённое состояние
+// отбрасывается целиком
+func (hs *historySeen) decode(value string) {
+	*hs = historySeen{}
+	if value == "" {
+		return
+	}
+
+	floorValue, list, ok := strings.Cut(value, ";")
+	floor, err := strconv.ParseInt(floorValue, 16, 64)
+	if !ok || err != nil {
+		return
+	}
+	restored := historySeen{floor: floor, keys: make(map[historyKey]bool)}
+	if list != "" {
+		for _, item := range strings.Split(list, ",") {
+			whenValue, hashValue, ok := strings.Cut(item, ":")
+			when, err1 := strconv.ParseInt(whenValue, 16, 64)
+			hash, err2 := strconv.ParseUint(hashValue, 16, 64)
+			if !ok || err1 != nil || err2 != nil {
+				return
+			}
+			key := historyKey{when: when, hash: hash}
+			restored.entries = append(restored.entries, key)
+			restored.keys[key] = true
+		}
+	}
+	sort.Slice(restored.entries, func(i, j int) bool { return restored.entries[i].when < restored.entries[j].when })
+	*hs = restored
+}
+
+// hashCommand хеш текста команды
+func hashCommand(command string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(command))
+	return h.Sum64()
+}
+
+// shellCommandEvent создаёт событие выполнения команды из истории оболочки.
+// Нулевое when означает, что время выполнения неизвестно
+func shellCommandEvent(source, shell, user, command string, when time.Time) Event {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	return Event{
+		RawLog:    command,
+		Source:    source,
+		EventType: "command_execution",
+		Severity:  determineBashCommandSeverity(command),
+		Timestamp: when.Format(time.RFC3339),
+		Hostname:  getHostname(),
+		User:      user,
+		Process:   shell,
+		Command:   command,
+	}
+}
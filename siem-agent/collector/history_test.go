@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistorySeen(t *testing.T) {
+	var hs historySeen
+	at := func(seconds int64) time.Time { return time.Unix(seconds, 0) }
+
+	steps := []struct {
+		when    int64
+		command string
+		isNew   bool
+	}{
+		{200, "make", true},
+		{200, "make", false},
+		{200, "make test", true},
+		// Команда другой сессии, записанная после более новых
+		{150, "vim main.go", true},
+		{150, "vim main.go", false},
+		{300, "git push", true},
+	}
+	for i, step := range steps {
+		if got := hs.isNew(at(step.when), step.command); got != step.isNew {
+			t.Errorf("step %d: isNew(%d, %q) = %v, want %v", i, step.when, step.command, got, step.isNew)
+		}
+	}
+}
+
+func TestHistorySeenLimit(t *testing.T) {
+	var hs historySeen
+	for i := 0; i <= historySeenLimit; i++ {
+		hs.isNew(time.Unix(int64(1000+i), 0), "ls")
+	}
+	if len(hs.entries) != historySeenLimit {
+		t.Fatalf("remembered %d commands, want %d", len(hs.entries), historySeenLimit)
+	}
+	// Вытеснена самая старая команда: всё, что не новее её, считается выданным
+	if hs.isNew(time.Unix(1000, 0), "ls") || hs.isNew(time.Unix(999, 0), "rm") {
+		t.Error("command older than evicted one reported as new")
+	}
+	if !hs.isNew(time.Unix(1001, 0), "cat") {
+		t.Error("unseen command newer than floor reported as seen")
+	}
+}
+
+func TestHistorySeenEncode(t *testing.T) {
+	var hs historySeen
+	for i := 0; i < historySeenLimit+10; i++ {
+		hs.isNew(time.Unix(int64(5000-i), 0), "echo")
+	}
+
+	var restored historySeen
+	restored.decode(hs.encode())
+	if restored.floor != hs.floor || len(restored.entries) != len(hs.entries) {
+		t.Fatalf("restored floor %d with %d entries, want %d with %d", restored.floor, len(restored.entries), hs.floor, len(hs.entries))
+	}
+	if restored.isNew(time.Unix(5000, 0), "echo") {
+		t.Error("restored state forgot a command")
+	}
+
+	restored.decode("garbage")
+	if len(restored.entries) != 0 || restored.floor != 0 {
+		t.Error("corrupted state was not discarded")
+	}
+}
+
+// rewriteFile заменяет файл новым, как zsh при сохранении истории
+func rewriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collectCommands(t *testing.T, col Collector) []string {
+	t.Helper()
+	events, err := col.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var commands []string
+	for _, event := range events {
+		commands = append(commands, event.Command)
+	}
+	return commands
+}
+
+func TestZshHistoryLateAppendAndRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zsh_history")
+	appendFile(t, path, ": 1700000100:0;make\n: 1700000200:0;make test\n")
+
+	zc := NewZshCollector("alice", path)
+	if got := collectCommands(t, zc); len(got) != 2 {
+		t.Fatalf("first read: %v", got)
+	}
+
+	// Вторая сессия при выходе дописывает команду, выполненную раньше
+	appendFile(t, path, ": 1700000150:0;vim main.go\n")
+	if got := collectCommands(t, zc); len(got) != 1 || got[0] != "vim main.go" {
+		t.Fatalf("late append: %v, want [vim main.go]", got)
+	}
+
+	// zsh переписывает файл целиком: ничего нового нет
+	history := ": 1700000100:0;make\n: 1700000150:0;vim main.go\n: 1700000200:0;make test\n"
+	rewriteFile(t, path, history)
+	if got := collectCommands(t, zc); len(got) != 0 {
+		t.Fatalf("rewrite re-shipped %v", got)
+	}
+
+	// После перезапуска агент восстанавливает позицию, файл снова переписан
+	pos := zc.Position()
+	rewriteFile(t, path, history+": 1700000300:0;git push\n")
+	restarted := NewZshCollector("alice", path)
+	restarted.Restore(pos)
+	if got := collectCommands(t, restarted); len(got) != 1 || got[0] != "git push" {
+		t.Fatalf("after restart: %v, want [git push]", got)
+	}
+}
+
+func TestFishHistoryRewriteAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fish_history")
+	history := "- cmd: ls -la\n  when: 1700000100\n- cmd: cd /tmp\n  when: 1700000200\n  paths:\n    - /tmp\n"
+	appendFile(t, path, history)
+
+	fc := NewFishCollector("bob", path)
+	if got := collectCommands(t, fc); len(got) != 2 {
+		t.Fatalf("first read: %v", got)
+	}
+
+	pos := fc.Position()
+	rewriteFile(t, path, "- cmd: echo hi\n  when: 1700000150\n"+history)
+	restarted := NewFishCollector("bob", path)
+	restarted.Restore(pos)
+	if got := collectCommands(t, restarted); len(got) != 1 || got[0] != "echo hi" {
+		t.Fatalf("after restart: %v, want [echo hi]", got)
+	}
+}
+
+func TestHistoryWaitsForMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zsh_history")
+	zc := NewZshCollector("alice", path)
+	if got := collectCommands(t, zc); len(got) != 0 {
+		t.Fatalf("missing file: %v", got)
+	}
+
+	appendFile(t, path, ": 1700000100:0;make\n")
+	if got := collectCommands(t, zc); len(got) != 1 || got[0] != "make" {
+		t.Fatalf("after file appeared: %v, want [make]", got)
+	}
+}
+
+func TestBashHistoryCustomPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bash_history.log")
+	appendFile(t, path, "#1700000100\nmake\nls -la\n")
+
+	bc := NewBashCollector("carol", path)
+	events, err := bc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 2 || events[0].Command != "make" || events[1].Command != "ls -la" {
+		t.Fatalf("events = %+v, want make and ls -la", events)
+	}
+	if when, _ := time.Parse(time.RFC3339, events[0].Timestamp); when.Unix() != 1700000100 {
+		t.Errorf("timestamp = %s, want HISTTIMEFORMAT time", events[0].Timestamp)
+	}
+}
@@ -0,0 +1,255 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// journalBacklog сколько записей journalctl держим в памяти до следующего Collect.
+// При заполнении чтение приостанавливается, и journalctl ждёт на записи в pipe
+const journalBacklog = 10000
+
+// journalEntry событие журнала вместе с его курсором
+type journalEntry struct {
+	event  Event
+	cursor string
+}
+
+// JournalCollector читает systemd journal через `journalctl -o json --follow`
+// и продолжает с курсора последнего выданного события
+type JournalCollector struct {
+	command   string
+	directory string
+	startTime time.Time
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	entries chan journalEntry
+	done    chan error
+	cursor  string // курсор последнего выданного события
+}
+
+// NewJournalCollector создаёт сборщик журнала. directory задаёт каталог журнала
+// (--directory), пустая строка означает системный журнал
+func NewJournalCollector(directory string) *JournalCollector {
+	return &JournalCollector{
+		command:   "journalctl",
+		directory: directory,
+		startTime: time.Now(),
+	}
+}
+
+func (jc *JournalCollector) Collect() ([]Event, error) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.cmd == nil {
+		if err := jc.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []Event
+	for {
+		select {
+		case entry, ok := <-jc.entries:
+			if ok {
+				events = append(events, entry.event)
+				if entry.cursor != "" {
+					jc.cursor = entry.cursor
+				}
+				continue
+			}
+
+			// journalctl завершился: при следующем Collect перезапустим его с курсора
+			err := <-jc.done
+			jc.cmd = nil
+			if err != nil {
+				return events, fmt.Errorf("journalctl exited: %w", err)
+			}
+			return events, nil
+		default:
+			return events, nil
+		}
+	}
+}
+
+func (jc *JournalCollector) GetSourceName() string {
+	return "journald"
+}
+
+func (jc *JournalCollector) GetSourceType() string {
+	return "journald"
+}
+
+// Position возвращает курсор последнего выданного события
+func (jc *JournalCollector) Position() checkpoint.Position {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	return checkpoint.Position{Path: jc.directory, Cursor: jc.cursor}
+}
+
+// Restore продолжает чтение журнала с сохранённого курсора
+func (jc *JournalCollector) Restore(pos checkpoint.Position) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	if pos.Cursor != "" {
+		jc.cursor = pos.Cursor
+	}
+}
+
+// Close останавливает journalctl
+func (jc *JournalCollector) Close() error {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.cmd == nil {
+		return nil
+	}
+	jc.cmd.Process.Kill()
+
+	// Непрочитанные записи отбрасываем: после перезапуска они будут прочитаны
+	// заново с курсора последнего выданного события
+	for range jc.entries {
+	}
+	<-jc.done
+	jc.cmd = nil
+	return nil
+}
+
+// start запускает journalctl с позиции последнего выданного события
+func (jc *JournalCollector) start() error {
+	args := []string{"-o", "json", "--follow", "--no-pager"}
+	if jc.cursor != "" {
+		args = append(args, "--after-cursor="+jc.cursor)
+	} else {
+		args = append(args, fmt.Sprintf("--since=@%d", jc.startTime.Unix()))
+	}
+	if jc.directory != "" {
+		args = append(args, "--directory="+jc.directory)
+	}
+
+	cmd := exec.Command(jc.command, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open journalctl output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start journalctl: %w", err)
+	}
+
+	entries := make(chan journalEntry, journalBacklog)
+	done := make(chan error, 1)
+	jc.cmd = cmd
+	jc.entries = entries
+	jc.done = done
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if entry, ok := parseJournalEntry(scanner.Bytes()); ok {
+				entries <- entry
+			}
+		}
+		close(entries)
+		done <- cmd.Wait()
+	}()
+
+	return nil
+}
+
+// parseJournalEntry разбирает одну запись `journalctl -o json`
+func parseJournalEntry(line []byte) (journalEntry, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return journalEntry{}, false
+	}
+
+	message := journalField(fields, "MESSAGE")
+	process := journalField(fields, "SYSLOG_IDENTIFIER")
+	if process == "" {
+		process = journalField(fields, "_COMM")
+	}
+
+	event := Event{
+		Timestamp: journalTimestamp(journalField(fields, "__REALTIME_TIMESTAMP")),
+		Hostname:  journalField(fields, "_HOSTNAME"),
+		Source:    "journald",
+		EventType: syslogEventType(process),
+		Severity:  journalSeverity(journalField(fields, "PRIORITY"), message),
+		User:      journalField(fields, "_UID"),
+		Process:   process,
+		Command:   journalField(fields, "_CMDLINE"),
+		RawLog:    message,
+		Fields:    make(map[string]string),
+	}
+	if event.Hostname == "" {
+		event.Hostname = getHostname()
+	}
+
+	for key, name := range map[string]string{
+		"_PID":          "pid",
+		"_UID":          "uid",
+		"_GID":          "gid",
+		"_EXE":          "exe",
+		"_SYSTEMD_UNIT": "unit",
+		"PRIORITY":      "priority",
+	} {
+		if value := journalField(fields, key); value != "" {
+			event.Fields[name] = value
+		}
+	}
+
+	return journalEntry{event: event, cursor: journalField(fields, "__CURSOR")}, true
+}
+
+// journalField возвращает значение поля. journalctl выводит бинарные значения
+// массивом байт, а не строкой
+func journalField(fields map[string]json.RawMessage, name string) string {
+	raw, ok := fields[name]
+	if !ok {
+		return ""
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+
+	var bytes []byte
+	var numbers []int
+	if err := json.Unmarshal(raw, &numbers); err == nil {
+		for _, n := range numbers {
+			bytes = append(bytes, byte(n))
+		}
+		return string(bytes)
+	}
+
+	return ""
+}
+
+// journalTimestamp переводит __REALTIME_TIMESTAMP (микросекунды) в RFC3339
+func journalTimestamp(realtime string) string {
+	usec, err := strconv.ParseInt(realtime, 10, 64)
+	if err != nil {
+		return time.Now().Format(time.RFC3339)
+	}
+	return time.UnixMicro(usec).Format(time.RFC3339)
+}
+
+// journalSeverity переводит syslog PRIORITY в уровень серьёзности
+func journalSeverity(priority, message string) string {
+	level, err := strconv.Atoi(priority)
+	if err != nil {
+		return determineSeverity(message)
+	}
+	return syslogSeverity(level)
+}
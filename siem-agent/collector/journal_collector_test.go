@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// fakeJournalctl создаёт скрипт, который записывает свои аргументы в args
+// и выводит содержимое output, как journalctl -o json
+func fakeJournalctl(t *testing.T, output string) (command, dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "output"), []byte(output), 0600); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "args") + "\ncat " + filepath.Join(dir, "output") + "\n"
+	command = filepath.Join(dir, "journalctl")
+	if err := os.WriteFile(command, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return command, dir
+}
+
+// setJournalOutput заменяет вывод, который получит следующий запуск
+func setJournalOutput(t *testing.T, dir, output string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "output"), []byte(output), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// journalArgs аргументы всех запусков, по строке на запуск
+func journalArgs(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// collectJournalRun собирает события, пока запущенный journalctl не завершится
+func collectJournalRun(t *testing.T, jc *JournalCollector) []Event {
+	t.Helper()
+	var events []Event
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		batch, err := jc.Collect()
+		if err != nil {
+			t.Fatalf("Collect: %v", err)
+		}
+		events = append(events, batch...)
+
+		jc.mu.Lock()
+		exited := jc.cmd == nil
+		jc.mu.Unlock()
+		if exited {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("journalctl did not exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+const journalFixture = `{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1709294400000000","_HOSTNAME":"web1","SYSLOG_IDENTIFIER":"sshd","_PID":"100","_UID":"0","PRIORITY":"6","MESSAGE":"Accepted publickey for alice from 192.0.2.10 port 50022 ssh2"}
+not json
+{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1709294401000000","_HOSTNAME":"web1","_COMM":"python3","_SYSTEMD_UNIT":"app.service","PRIORITY":"3","MESSAGE":"Traceback (most recent call last):\n  File \"app.py\", line 1\nValueError"}
+{"__CURSOR":"s=1;i=3","__REALTIME_TIMESTAMP":"1709294402000000","_HOSTNAME":"web1","SYSLOG_IDENTIFIER":"kernel","PRIORITY":"4","MESSAGE":[98,97,100,32,255,98,121,116,101,10,110,101,120,116]}
+`
+
+func TestJournalCollectorParsesEntries(t *testing.T) {
+	command, _ := fakeJournalctl(t, journalFixture)
+	jc := NewJournalCollector("")
+	jc.command = command
+
+	events := collectJournalRun(t, jc)
+	if len(events) != 3 {
+		t.Fatalf("collected %d events, want 3", len(events))
+	}
+
+	ssh := events[0]
+	if ssh.Process != "sshd" || ssh.Hostname != "web1" || ssh.Severity != "INFO" || ssh.Fields["pid"] != "100" {
+		t.Errorf("sshd event = %+v", ssh)
+	}
+	if want := time.UnixMicro(1709294400000000).Format(time.RFC3339); ssh.Timestamp != want {
+		t.Errorf("timestamp = %s, want %s", ssh.Timestamp, want)
+	}
+
+	// Многострочное сообщение остаётся одним событием
+	multiline := events[1]
+	if multiline.RawLog != "Traceback (most recent call last):\n  File \"app.py\", line 1\nValueError" {
+		t.Errorf("multi-line MESSAGE = %q", multiline.RawLog)
+	}
+	if multiline.Process != "python3" || multiline.Fields["unit"] != "app.service" || multiline.Severity != "CRITICAL" {
+		t.Errorf("multi-line event = %+v", multiline)
+	}
+
+	// Сообщение с невалидным UTF-8 journalctl выводит массивом байт
+	if binary := events[2]; binary.RawLog != "bad \xffbyte\nnext" {
+		t.Errorf("byte-array MESSAGE = %q", binary.RawLog)
+	}
+
+	if pos := jc.Position(); pos.Cursor != "s=1;i=3" {
+		t.Errorf("cursor = %q, want s=1;i=3", pos.Cursor)
+	}
+}
+
+func TestJournalCollectorResumesFromCursor(t *testing.T) {
+	command, dir := fakeJournalctl(t, journalFixture)
+	jc := NewJournalCollector("/var/log/journal/remote")
+	jc.command = command
+	collectJournalRun(t, jc)
+
+	// journalctl завершился: следующий запуск продолжает после последнего курсора
+	setJournalOutput(t, dir, `{"__CURSOR":"s=1;i=4","MESSAGE":"after restart"}`+"\n")
+	events := collectJournalRun(t, jc)
+	if len(events) != 1 || events[0].RawLog != "after restart" {
+		t.Fatalf("second run collected %+v", events)
+	}
+
+	// Новый сборщик после перезапуска агента продолжает с сохранённой позиции
+	restarted := NewJournalCollector("/var/log/journal/remote")
+	restarted.command = command
+	restarted.Restore(checkpoint.Position{Path: "/var/log/journal/remote", Cursor: jc.Position().Cursor})
+	setJournalOutput(t, dir, "")
+	collectJournalRun(t, restarted)
+
+	args := journalArgs(t, dir)
+	if len(args) != 3 {
+		t.Fatalf("journalctl started %d times, want 3", len(args))
+	}
+	if !strings.Contains(args[0], "--since=@") || strings.Contains(args[0], "--after-cursor") {
+		t.Errorf("first run args = %q, want --since", args[0])
+	}
+	if !strings.Contains(args[1], "--after-cursor=s=1;i=3") {
+		t.Errorf("restart args = %q, want --after-cursor=s=1;i=3", args[1])
+	}
+	if !strings.Contains(args[2], "--after-cursor=s=1;i=4") {
+		t.Errorf("restored args = %q, want --after-cursor=s=1;i=4", args[2])
+	}
+	for _, run := range args {
+		if !strings.Contains(run, "--directory=/var/log/journal/remote") {
+			t.Errorf("args = %q, want --directory", run)
+		}
+	}
+}
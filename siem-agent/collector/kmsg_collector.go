@@ -0,0 +1,322 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// kmsgRecordSize максимальный размер записи /dev/kmsg: read с меньшим буфером
+// возвращает EINVAL
+const kmsgRecordSize = 8192
+
+// bootIDPath идентификатор текущей загрузки: номера записей kmsg после
+// перезагрузки начинаются заново
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+var (
+	oomKillPattern     = regexp.MustCompile(`(?:Out of memory|Memory cgroup out of memory): Killed process (\d+) \(([^)]*)\)`)
+	segfaultPattern    = regexp.MustCompile(`^(\S+)\[(\d+)\]: segfault at (\S+) ip (\S+) sp (\S+) error (\d+)(?: in (\S+))?`)
+	moduleTaintPattern = regexp.MustCompile(`^(\S+): (loading out-of-tree module taints kernel|module verification failed|module is from the staging directory|module license '[^']*' taints kernel)`)
+	selinuxAVCPattern  = regexp.MustCompile(`avc:\s+denied\s+\{ ([^}]*) \}`)
+)
+
+// kmsgRecord запись /dev/kmsg: "pri,seq,usec,flags;сообщение" и строки
+// продолжения " KEY=value"
+type kmsgRecord struct {
+	facility  int
+	level     int
+	sequence  uint64
+	monotonic time.Duration // время с загрузки
+	message   string
+	metadata  map[string]string
+}
+
+// KmsgCollector читает кольцевой буфер ядра из /dev/kmsg напрямую, без
+// syslog, и выделяет OOM, segfault, загрузку модулей и отказы AppArmor/SELinux
+type KmsgCollector struct {
+	path string
+
+	mu       sync.Mutex
+	fd       int
+	opened   bool
+	bootID   string
+	bootTime time.Time
+	lastSeq  uint64 // номер последней выданной записи
+	hasSeq   bool
+	restored checkpoint.Position
+}
+
+// NewKmsgCollector создаёт сборщик для устройства path (обычно /dev/kmsg)
+func NewKmsgCollector(path string) *KmsgCollector {
+	return &KmsgCollector{path: path}
+}
+
+func (kc *KmsgCollector) Collect() ([]Event, error) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if !kc.opened {
+		if err := kc.open(); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []Event
+	buf := make([]byte, kmsgRecordSize)
+	for {
+		n, err := syscall.Read(kc.fd, buf)
+		switch {
+		case err == syscall.EAGAIN:
+			return events, nil
+		case err == syscall.EINTR:
+			continue
+		case err == syscall.EPIPE:
+			// Буфер ядра перезаписан быстрее, чем мы его читали
+			log.Printf("[Collector] kmsg: ring buffer overrun, some kernel messages were lost")
+			continue
+		case err != nil:
+			return events, fmt.Errorf("failed to read %s: %w", kc.path, err)
+		case n == 0:
+			return events, nil
+		}
+
+		record, ok := parseKmsgRecord(string(buf[:n]))
+		if !ok {
+			continue
+		}
+		// После восстановления позиции пропускаем уже отправленные записи
+		if kc.hasSeq && record.sequence <= kc.lastSeq {
+			continue
+		}
+		kc.lastSeq, kc.hasSeq = record.sequence, true
+		events = append(events, kc.recordEvent(record))
+	}
+}
+
+// Position возвращает номер последней выданной записи и идентификатор загрузки
+func (kc *KmsgCollector) Position() checkpoint.Position {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	if !kc.opened {
+		return kc.restored
+	}
+	pos := checkpoint.Position{Path: kc.path, Cursor: kc.bootID}
+	if kc.hasSeq {
+		pos.Offset = int64(kc.lastSeq) + 1
+	}
+	return pos
+}
+
+// Restore продолжает чтение после сохранённой записи, если система
+// с тех пор не перезагружалась
+func (kc *KmsgCollector) Restore(pos checkpoint.Position) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	kc.restored = pos
+}
+
+// Close закрывает устройство
+func (kc *KmsgCollector) Close() error {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	if !kc.opened {
+		return nil
+	}
+	kc.opened = false
+	return syscall.Close(kc.fd)
+}
+
+func (kc *KmsgCollector) GetSourceName() string {
+	return "kmsg"
+}
+
+func (kc *KmsgCollector) GetSourceType() string {
+	return "kernel"
+}
+
+// open открывает устройство в неблокирующем режиме: Collect читает записи,
+// пока не получит EAGAIN
+func (kc *KmsgCollector) open() error {
+	bootTime, err := readBootTime("/proc")
+	if err != nil {
+		return err
+	}
+	kc.bootTime = bootTime
+	if data, err := os.ReadFile(bootIDPath); err == nil {
+		kc.bootID = strings.TrimSpace(string(data))
+	}
+
+	fd, err := syscall.Open(kc.path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", kc.path, err)
+	}
+	kc.fd = fd
+	kc.opened = true
+
+	// Позиция относится к текущей загрузке, иначе читаем буфер с начала
+	if kc.restored.Offset > 0 && kc.restored.Cursor == kc.bootID && kc.bootID != "" {
+		kc.lastSeq, kc.hasSeq = uint64(kc.restored.Offset-1), true
+	}
+	return nil
+}
+
+// recordEvent создаёт событие по записи ядра
+func (kc *KmsgCollector) recordEvent(record kmsgRecord) Event {
+	// Время записи — монотонные часы с загрузки. Они не идут во время сна,
+	// поэтому после suspend время может отставать от настоящего
+	timestamp := kc.bootTime.Add(record.monotonic)
+
+	event := Event{
+		RawLog:    record.message,
+		Source:    "kmsg",
+		EventType: "kernel",
+		Severity:  syslogSeverity(record.level),
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		Hostname:  getHostname(),
+		Process:   "kernel",
+		Fields: map[string]string{
+			"sequence":  strconv.FormatUint(record.sequence, 10),
+			"monotonic": fmt.Sprintf("%.6f", record.monotonic.Seconds()),
+			"facility":  strconv.Itoa(record.facility),
+			"level":     strconv.Itoa(record.level),
+		},
+	}
+	for key, value := range record.metadata {
+		event.Fields[strings.ToLower(key)] = value
+	}
+
+	message := record.message
+	switch {
+	case oomKillPattern.MatchString(message):
+		m := oomKillPattern.FindStringSubmatch(message)
+		event.EventType = "oom_kill"
+		event.Severity = "WARNING"
+		event.Fields["pid"] = m[1]
+		event.Fields["killed_process"] = m[2]
+
+	case segfaultPattern.MatchString(message):
+		m := segfaultPattern.FindStringSubmatch(message)
+		event.EventType = "segfault"
+		event.Severity = "WARNING"
+		event.Fields["segfault_process"] = m[1]
+		event.Fields["pid"] = m[2]
+		event.Fields["address"] = m[3]
+		event.Fields["ip"] = m[4]
+		event.Fields["sp"] = m[5]
+		event.Fields["error_code"] = m[6]
+		if m[7] != "" {
+			event.Fields["object"] = m[7]
+		}
+
+	case moduleTaintPattern.MatchString(message):
+		// Ядро сообщает о загрузке модуля, только если он портит ядро:
+		// собран вне дерева, не подписан или с несвободной лицензией
+		m := moduleTaintPattern.FindStringSubmatch(message)
+		event.EventType = "module_loaded"
+		event.Severity = "WARNING"
+		event.Fields["module"] = m[1]
+		event.Fields["reason"] = m[2]
+
+	case strings.Contains(message, `apparmor="DENIED"`):
+		event.EventType = "apparmor_denied"
+		event.Severity = "WARNING"
+		addKmsgAuditFields(event.Fields, message, "operation", "profile", "name", "pid", "comm", "requested_mask", "denied_mask")
+
+	case selinuxAVCPattern.MatchString(message):
+		event.EventType = "selinux_denied"
+		event.Severity = "WARNING"
+		event.Fields["permission"] = selinuxAVCPattern.FindStringSubmatch(message)[1]
+		addKmsgAuditFields(event.Fields, message, "pid", "comm", "name", "path", "scontext", "tcontext", "tclass", "permissive")
+	}
+
+	if comm := event.Fields["comm"]; comm != "" {
+		event.Process = comm
+	}
+	return event
+}
+
+// addKmsgAuditFields добавляет выбранные поля key=value сообщения аудита
+func addKmsgAuditFields(fields map[string]string, message string, keys ...string) {
+	values, ok := parseKV(message)
+	if !ok {
+		return
+	}
+	for _, key := range keys {
+		if value, exists := values[key]; exists {
+			fields[key] = value
+		}
+	}
+}
+
+// parseKmsgRecord разбирает запись /dev/kmsg
+func parseKmsgRecord(data string) (kmsgRecord, bool) {
+	header, body, found := strings.Cut(data, ";")
+	if !found {
+		return kmsgRecord{}, false
+	}
+
+	parts := strings.Split(header, ",")
+	if len(parts) < 3 {
+		return kmsgRecord{}, false
+	}
+	priority, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return kmsgRecord{}, false
+	}
+	sequence, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return kmsgRecord{}, false
+	}
+	usec, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return kmsgRecord{}, false
+	}
+
+	record := kmsgRecord{
+		facility:  priority >> 3,
+		level:     priority & 7,
+		sequence:  sequence,
+		monotonic: time.Duration(usec) * time.Microsecond,
+	}
+
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	record.message = unescapeKmsg(lines[0])
+	for _, line := range lines[1:] {
+		// Строки продолжения: " SUBSYSTEM=pci", " DEVICE=+pci:0000:00:1f.0"
+		if key, value, ok := strings.Cut(strings.TrimPrefix(line, " "), "="); ok {
+			if record.metadata == nil {
+				record.metadata = make(map[string]string)
+			}
+			record.metadata[key] = unescapeKmsg(value)
+		}
+	}
+	return record, true
+}
+
+// unescapeKmsg заменяет экранированные ядром непечатные байты \xNN
+func unescapeKmsg(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if value, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
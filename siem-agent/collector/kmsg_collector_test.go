@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseKmsgRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		ok       bool
+		want     kmsgRecord
+		metadata map[string]string
+	}{
+		{
+			name: "plain",
+			data: "6,339,5140900,-;NET: Registered protocol family 10\n",
+			ok:   true,
+			want: kmsgRecord{facility: 0, level: 6, sequence: 339, monotonic: 5140900 * time.Microsecond, message: "NET: Registered protocol family 10"},
+		},
+		{
+			name: "continuation lines",
+			data: "30,1234,987654,c;e1000e: link up\n SUBSYSTEM=pci\n DEVICE=+pci:0000:00:1f.6\n",
+			ok:   true,
+			want: kmsgRecord{facility: 3, level: 6, sequence: 1234, monotonic: 987654 * time.Microsecond, message: "e1000e: link up"},
+			metadata: map[string]string{
+				"SUBSYSTEM": "pci",
+				"DEVICE":    "+pci:0000:00:1f.6",
+			},
+		},
+		{
+			name: "escaped bytes",
+			data: `4,7,1,-;tab\x09and\x5cbackslash and bad \xZZ`,
+			ok:   true,
+			want: kmsgRecord{level: 4, sequence: 7, monotonic: time.Microsecond, message: "tab\tand\\backslash and bad \\xZZ"},
+		},
+		{name: "no separator", data: "6,1,1,-"},
+		{name: "short header", data: "6,1;message"},
+		{name: "bad sequence", data: "6,x,1,-;message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := parseKmsgRecord(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			metadata := record.metadata
+			record.metadata = nil
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+			if len(metadata) != len(tt.metadata) {
+				t.Errorf("metadata = %v, want %v", metadata, tt.metadata)
+			}
+			for key, value := range tt.metadata {
+				if metadata[key] != value {
+					t.Errorf("metadata[%q] = %q, want %q", key, metadata[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestKmsgRecordEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		eventType string
+		severity  string
+		process   string
+		fields    map[string]string
+	}{
+		{
+			name:      "oom kill",
+			data:      "3,100,2000000,-;Out of memory: Killed process 4242 (java) total-vm:8000000kB",
+			eventType: "oom_kill",
+			severity:  "WARNING",
+			process:   "kernel",
+			fields:    map[string]string{"pid": "4242", "killed_process": "java", "sequence": "100", "monotonic": "2.000000"},
+		},
+		{
+			name:      "cgroup oom kill",
+			data:      "4,101,1,-;Memory cgroup out of memory: Killed process 77 (node) total-vm:1kB",
+			eventType: "oom_kill",
+			fields:    map[string]string{"pid": "77", "killed_process": "node"},
+		},
+		{
+			name:      "segfault",
+			data:      "6,102,1,-;app[1337]: segfault at 0 ip 000055d1 sp 00007ffc error 4 in app[55d1+1000]",
+			eventType: "segfault",
+			severity:  "WARNING",
+			fields:    map[string]string{"segfault_process": "app", "pid": "1337", "address": "0", "error_code": "4", "object": "app[55d1+1000]"},
+		},
+		{
+			name:      "out-of-tree module",
+			data:      "4,103,1,-;rootkit: loading out-of-tree module taints kernel.",
+			eventType: "module_loaded",
+			severity:  "WARNING",
+			fields:    map[string]string{"module": "rootkit", "reason": "loading out-of-tree module taints kernel"},
+		},
+		{
+			name:      "apparmor denied",
+			data:      `5,104,1,-;audit: type=1400 audit(1709294400.000:50): apparmor="DENIED" operation="open" profile="/usr/sbin/cupsd" name="/etc/shadow" pid=900 comm="cupsd" requested_mask="r" denied_mask="r"`,
+			eventType: "apparmor_denied",
+			severity:  "WARNING",
+			process:   "cupsd",
+			fields:    map[string]string{"operation": "open", "profile": "/usr/sbin/cupsd", "name": "/etc/shadow", "pid": "900", "denied_mask": "r"},
+		},
+		{
+			name:      "selinux denied",
+			data:      `5,105,1,-;audit: type=1400 audit(1709294400.000:51): avc:  denied  { read write } for  pid=901 comm="httpd" name="data" scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=0`,
+			eventType: "selinux_denied",
+			severity:  "WARNING",
+			process:   "httpd",
+			fields:    map[string]string{"permission": "read write", "tclass": "file", "permissive": "0"},
+		},
+		{
+			name:      "other kernel message",
+			data:      "2,106,1,-;EXT4-fs error (device sda1): bad block",
+			eventType: "kernel",
+			severity:  "CRITICAL",
+			process:   "kernel",
+			fields:    map[string]string{"level": "2", "facility": "0"},
+		},
+	}
+
+	kc := &KmsgCollector{bootTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := parseKmsgRecord(tt.data)
+			if !ok {
+				t.Fatal("record not parsed")
+			}
+			event := kc.recordEvent(record)
+			if event.EventType != tt.eventType {
+				t.Errorf("event_type = %q, want %q", event.EventType, tt.eventType)
+			}
+			if tt.severity != "" && event.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", event.Severity, tt.severity)
+			}
+			if tt.process != "" && event.Process != tt.process {
+				t.Errorf("process = %q, want %q", event.Process, tt.process)
+			}
+			for key, value := range tt.fields {
+				if event.Fields[key] != value {
+					t.Errorf("Fields[%q] = %q, want %q", key, event.Fields[key], value)
+				}
+			}
+		})
+	}
+
+	// Время записи отсчитывается от загрузки
+	record, _ := parseKmsgRecord("6,1,1500000,-;x")
+	if event := kc.recordEvent(record); event.Timestamp != "2024-03-01T12:00:01.5Z" {
+		t.Errorf("timestamp = %s, want boot time + 1.5s", event.Timestamp)
+	}
+}
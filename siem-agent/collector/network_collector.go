@@ -0,0 +1,302 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tcpStates состояния сокетов TCP из include/net/tcp_states.h
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED", "02": "SYN_SENT", "03": "SYN_RECV", "04": "FIN_WAIT1",
+	"05": "FIN_WAIT2", "06": "TIME_WAIT", "07": "CLOSE", "08": "CLOSE_WAIT",
+	"09": "LAST_ACK", "0A": "LISTEN", "0B": "CLOSING",
+}
+
+// netSocket запись /proc/net/{tcp,udp}[6]
+type netSocket struct {
+	protocol   string // tcp, tcp6, udp, udp6
+	localIP    string
+	localPort  string
+	remoteIP   string
+	remotePort string
+	state      string
+	uid        string
+	inode      string
+}
+
+// listening слушающий сокет: LISTEN для TCP, несвязанный сокет для UDP
+func (s netSocket) listening() bool {
+	if strings.HasPrefix(s.protocol, "tcp") {
+		return s.state == "LISTEN"
+	}
+	return s.remotePort == "0"
+}
+
+// key идентифицирует сокет между обходами
+func (s netSocket) key() string {
+	if s.listening() {
+		return s.protocol + " " + net.JoinHostPort(s.localIP, s.localPort)
+	}
+	return s.protocol + " " + net.JoinHostPort(s.localIP, s.localPort) + " " + net.JoinHostPort(s.remoteIP, s.remotePort)
+}
+
+// NetworkCollector разбирает /proc/net и сообщает о новых слушающих портах
+// и новых исходящих соединениях с указанием процесса
+type NetworkCollector struct {
+	procRoot string
+	known    map[string]bool
+	started  bool
+}
+
+// NewNetworkCollector создаёт сборщик для каталога procRoot (обычно /proc)
+func NewNetworkCollector(procRoot string) *NetworkCollector {
+	return &NetworkCollector{
+		procRoot: procRoot,
+		known:    make(map[string]bool),
+	}
+}
+
+func (nc *NetworkCollector) Collect() ([]Event, error) {
+	var sockets []netSocket
+	for _, protocol := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		parsed, err := readNetSockets(filepath.Join(nc.procRoot, "net", protocol), protocol)
+		if err != nil {
+			// IPv6 может быть отключён
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		sockets = append(sockets, parsed...)
+	}
+
+	// Порты, на которых что-то слушает: соединения на них входящие
+	listeningPorts := make(map[string]bool)
+	for _, socket := range sockets {
+		if socket.listening() {
+			listeningPorts[socket.protocol+":"+socket.localPort] = true
+		}
+	}
+
+	current := make(map[string]bool)
+	var fresh []netSocket
+	for _, socket := range sockets {
+		if !socket.listening() && (socket.state != "ESTABLISHED" || listeningPorts[socket.protocol+":"+socket.localPort]) {
+			continue
+		}
+		key := socket.key()
+		current[key] = true
+		if !nc.known[key] {
+			fresh = append(fresh, socket)
+		}
+	}
+
+	// Первый обход — опись сокетов, открытых до запуска агента: слушающий
+	// порт или обратная оболочка могли появиться раньше. Соединения
+	// с loopback в опись не входят
+	inventory := !nc.started
+	nc.started = true
+	nc.known = current
+	if inventory {
+		log.Printf("[Collector] Tracking %d listening sockets and outbound connections", len(current))
+		external := fresh[:0]
+		for _, socket := range fresh {
+			if socket.listening() || !net.ParseIP(socket.remoteIP).IsLoopback() {
+				external = append(external, socket)
+			}
+		}
+		fresh = external
+	}
+
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+
+	owners := socketOwners(nc.procRoot)
+	events := make([]Event, 0, len(fresh))
+	for _, socket := range fresh {
+		event := nc.socketEvent(socket, owners[socket.inode])
+		if inventory {
+			event.Fields["inventory"] = "true"
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (nc *NetworkCollector) GetSourceName() string {
+	return "network"
+}
+
+func (nc *NetworkCollector) GetSourceType() string {
+	return "network"
+}
+
+// socketEvent создаёт событие нового слушающего порта или исходящего соединения
+func (nc *NetworkCollector) socketEvent(socket netSocket, pid string) Event {
+	event := Event{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Source:    "network",
+		EventType: "connection_established",
+		Severity:  "INFO",
+		User:      socket.uid,
+		Fields: map[string]string{
+			"protocol":   socket.protocol,
+			"local_ip":   socket.localIP,
+			"local_port": socket.localPort,
+			"state":      socket.state,
+			"uid":        socket.uid,
+			"inode":      socket.inode,
+		},
+	}
+
+	if socket.listening() {
+		// Неожиданный слушающий порт (bind shell, бэкдор) — главное, что здесь ищем
+		event.EventType = "listening_port_opened"
+		event.Severity = "WARNING"
+		event.RawLog = fmt.Sprintf("%s listening on %s", socket.protocol, net.JoinHostPort(socket.localIP, socket.localPort))
+	} else {
+		event.Fields["remote_ip"] = socket.remoteIP
+		event.Fields["remote_port"] = socket.remotePort
+		event.RawLog = fmt.Sprintf("%s %s -> %s", socket.protocol,
+			net.JoinHostPort(socket.localIP, socket.localPort), net.JoinHostPort(socket.remoteIP, socket.remotePort))
+	}
+
+	if pid != "" {
+		event.Fields["pid"] = pid
+		dir := filepath.Join(nc.procRoot, pid)
+		if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil {
+			if info, ok := parseProcStat(string(stat)); ok {
+				event.Process = info.comm
+			}
+		}
+		if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+			event.Fields["exe"] = exe
+			for _, suspicious := range suspiciousExeDirs {
+				if strings.HasPrefix(exe, suspicious) {
+					event.Fields["suspicious_location"] = "true"
+					event.Severity = "WARNING"
+				}
+			}
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			event.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		}
+		event.RawLog += " pid=" + pid + " process=" + event.Process
+	}
+
+	return event
+}
+
+// readNetSockets разбирает таблицу сокетов /proc/net/<protocol>
+func readNetSockets(path, protocol string) ([]netSocket, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sockets []netSocket
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // заголовок
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		localIP, localPort, ok := parseProcNetAddress(fields[1])
+		if !ok {
+			continue
+		}
+		remoteIP, remotePort, ok := parseProcNetAddress(fields[2])
+		if !ok {
+			continue
+		}
+
+		state := tcpStates[fields[3]]
+		if state == "" {
+			state = fields[3]
+		}
+		sockets = append(sockets, netSocket{
+			protocol:   protocol,
+			localIP:    localIP,
+			localPort:  localPort,
+			remoteIP:   remoteIP,
+			remotePort: remotePort,
+			state:      state,
+			uid:        fields[7],
+			inode:      fields[9],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sockets, nil
+}
+
+// parseProcNetAddress разбирает "0100007F:0016". Адрес записан 32-битными
+// словами в порядке байт хоста (little-endian), порт — big-endian
+func parseProcNetAddress(value string) (ip, port string, ok bool) {
+	hexIP, hexPort, found := strings.Cut(value, ":")
+	if !found {
+		return "", "", false
+	}
+
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", "", false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	portNumber, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", "", false
+	}
+	return net.IP(raw).String(), strconv.FormatUint(portNumber, 10), true
+}
+
+// socketOwners сопоставляет inode сокетов с pid по ссылкам /proc/<pid>/fd/*
+func socketOwners(procRoot string) map[string]string {
+	owners := make(map[string]string)
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procRoot, pid, "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+				inode = strings.TrimSuffix(inode, "]")
+				if _, exists := owners[inode]; !exists {
+					owners[inode] = pid
+				}
+			}
+		}
+	}
+	return owners
+}
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseProcNetAddress(t *testing.T) {
+	tests := []struct {
+		value string
+		ip    string
+		port  string
+		ok    bool
+	}{
+		{"0100007F:0016", "127.0.0.1", "22", true},
+		{"00000000:0000", "0.0.0.0", "0", true},
+		{"077100CB:115C", "203.0.113.7", "4444", true},
+		{"00000000000000000000000000000000:01BB", "::", "443", true},
+		{"00000000000000000000000001000000:0277", "::1", "631", true},
+		{"B80D0120000000000000000001000000:01BB", "2001:db8::1", "443", true},
+		{"0000000000000000FFFF00000100007F:0016", "127.0.0.1", "22", true},
+		{"0100007F", "", "", false},
+		{"ZZ00007F:0016", "", "", false},
+		{"01007F:0016", "", "", false},
+		{"0100007F:1FFFF", "", "", false},
+	}
+	for _, tt := range tests {
+		ip, port, ok := parseProcNetAddress(tt.value)
+		if ok != tt.ok || ip != tt.ip || port != tt.port {
+			t.Errorf("parseProcNetAddress(%q) = %q, %q, %v; want %q, %q, %v", tt.value, ip, port, ok, tt.ip, tt.port, tt.ok)
+		}
+	}
+}
+
+const procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+// procNetLine строка таблицы /proc/net/{tcp,udp}[6]
+func procNetLine(local, remote, state, uid, inode string) string {
+	return "   0: " + local + " " + remote + " " + state + " 00000000:00000000 00:00000000 00000000  " + uid + "        0 " + inode + " 1 0000000000000000 100 0 0 10 0\n"
+}
+
+// setSockets записывает таблицы сокетов фиктивного /proc
+func (fp *fakeProc) setSockets(tables map[string][]string) {
+	fp.t.Helper()
+	for _, protocol := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		fp.write("net/"+protocol, procNetHeader+strings.Join(tables[protocol], ""))
+	}
+}
+
+// openSocket добавляет процессу дескриптор сокета с заданным inode
+func (fp *fakeProc) openSocket(pid, fd, inode string) {
+	fp.t.Helper()
+	dir := filepath.Join(fp.root, pid, "fd")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fp.t.Fatal(err)
+	}
+	if err := os.Symlink("socket:["+inode+"]", filepath.Join(dir, fd)); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+// socketSummary описание события сборщика сети для сравнения
+func socketSummary(events []Event) []string {
+	var summary []string
+	for _, event := range events {
+		line := event.EventType + " " + event.RawLog
+		if event.Fields["inventory"] == "true" {
+			line += " inventory"
+		}
+		summary = append(summary, line)
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestNetworkCollectorClassification(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.start(1234, "sh", "/tmp/.x/sh", []string{"sh", "-i"}, 1, 1000, 500)
+	fp.openSocket("1234", "3", "102")
+
+	listener22 := procNetLine("00000000:0016", "00000000:0000", "0A", "0", "100")
+	// Входящее соединение на слушающий порт 22 не отслеживается
+	inbound := procNetLine("0500000A:0016", "097100CB:C350", "01", "0", "101")
+	reverseShell := procNetLine("0500000A:C351", "077100CB:115C", "01", "1000", "102")
+	loopback := procNetLine("0100007F:C352", "0100007F:1538", "01", "1000", "103")
+	timeWait := procNetLine("0500000A:C353", "077100CB:01BB", "06", "0", "0")
+	listener443 := procNetLine("00000000000000000000000000000000:01BB", "00000000000000000000000000000000:0000", "0A", "0", "105")
+	dns := procNetLine("00000000:0035", "00000000:0000", "07", "0", "106")
+
+	fp.setSockets(map[string][]string{
+		"tcp":  {listener22, inbound, reverseShell, loopback, timeWait},
+		"tcp6": {listener443},
+		"udp":  {dns},
+	})
+
+	nc := NewNetworkCollector(fp.root)
+	events, err := nc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	// Первый обход: опись без соединений с loopback
+	want := []string{
+		"connection_established tcp 10.0.0.5:50001 -> 203.0.113.7:4444 pid=1234 process=sh inventory",
+		"listening_port_opened tcp listening on 0.0.0.0:22 inventory",
+		"listening_port_opened tcp6 listening on [::]:443 inventory",
+		"listening_port_opened udp listening on 0.0.0.0:53 inventory",
+	}
+	if got := socketSummary(events); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("first pass:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, event := range events {
+		if event.Fields["pid"] == "1234" && (event.Fields["suspicious_location"] != "true" || event.Severity != "WARNING" || event.Command != "sh -i") {
+			t.Errorf("reverse shell event = %+v", event)
+		}
+	}
+
+	if events, _ := nc.Collect(); len(events) != 0 {
+		t.Fatalf("unchanged sockets reported again: %v", socketSummary(events))
+	}
+
+	// Обратная оболочка закрыта, открыт новый порт и соединение с loopback
+	listener4445 := procNetLine("00000000:115D", "00000000:0000", "0A", "1000", "107")
+	loopback2 := procNetLine("0100007F:C354", "0100007F:1538", "01", "1000", "108")
+	fp.setSockets(map[string][]string{
+		"tcp":  {listener22, inbound, loopback, listener4445, loopback2},
+		"tcp6": {listener443},
+		"udp":  {dns},
+	})
+	events, _ = nc.Collect()
+	want = []string{
+		"connection_established tcp 127.0.0.1:50004 -> 127.0.0.1:5432",
+		"listening_port_opened tcp listening on 0.0.0.0:4445",
+	}
+	if got := socketSummary(events); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("after change:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Закрытое соединение, открытое снова, считается новым
+	fp.setSockets(map[string][]string{
+		"tcp":  {listener22, inbound, loopback, listener4445, loopback2, reverseShell},
+		"tcp6": {listener443},
+		"udp":  {dns},
+	})
+	events, _ = nc.Collect()
+	if got := socketSummary(events); len(got) != 1 || !strings.HasPrefix(got[0], "connection_established tcp 10.0.0.5:50001 -> 203.0.113.7:4444") {
+		t.Fatalf("reopened connection: %v", got)
+	}
+}
+
+func TestNetworkCollectorWithoutIPv6(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.write("net/tcp", procNetHeader+procNetLine("00000000:0016", "00000000:0000", "0A", "0", "100"))
+	fp.write("net/udp", procNetHeader)
+
+	events, err := NewNetworkCollector(fp.root).Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("events = %v, want the port 22 listener", socketSummary(events))
+	}
+}
@@ -0,0 +1,381 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordParser разбирает строку файла на поля. false означает, что строка
+// не соответствует формату
+type recordParser func(line string) (map[string]string, bool)
+
+var (
+	kvPattern           = regexp.MustCompile(`([A-Za-z_][\w.-]*)=("(?:[^"\\]|\\.)*"|'[^']*'|\S*)`)
+	cefExtensionPattern = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_.\[\]-]+)=`)
+	epochPattern        = regexp.MustCompile(`^(\d{10}|\d{13})(?:\.(\d+))?$`)
+)
+
+// defaultMappings поля события, которые заполняются из стандартных полей
+// формата, если mapping их не переопределяет
+var defaultMappings = map[string]map[string]string{
+	"regex": eventFieldNames(),
+	"json":  eventFieldNames(),
+	"kv":    eventFieldNames(),
+	"cef": {
+		"timestamp":  "rt",
+		"hostname":   "dvchost",
+		"event_type": "name",
+		"severity":   "severity",
+		"user":       "suser",
+		"process":    "sproc",
+	},
+	"leef": {
+		"timestamp":  "devTime",
+		"hostname":   "identHostName",
+		"event_type": "event_id",
+		"severity":   "sev",
+		"user":       "usrName",
+	},
+}
+
+// timestampLayouts форматы времени, которые пробуются, если формат не задан
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"02/Jan/2006:15:04:05 -0700", // nginx, apache
+	"Jan 02 2006 15:04:05",       // CEF rt
+	"Jan 02 2006 15:04:05 MST",   // LEEF devTime
+	time.RFC1123Z,
+}
+
+// eventFieldNames отображение полей события на одноимённые поля записи
+func eventFieldNames() map[string]string {
+	return map[string]string{
+		"timestamp":  "timestamp",
+		"hostname":   "hostname",
+		"event_type": "event_type",
+		"severity":   "severity",
+		"user":       "user",
+		"process":    "process",
+		"command":    "command",
+	}
+}
+
+// newRecordParser создаёт разборщик строк указанного формата
+func newRecordParser(format, pattern string) (recordParser, error) {
+	switch format {
+	case "", "plain":
+		return parsePlain, nil
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		return regexParser(re), nil
+	case "json":
+		return parseJSONLine, nil
+	case "kv":
+		return parseKV, nil
+	case "cef":
+		return parseCEF, nil
+	case "leef":
+		return parseLEEF, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// parsePlain строка целиком становится полем message
+func parsePlain(line string) (map[string]string, bool) {
+	return map[string]string{"message": line}, true
+}
+
+// regexParser поля — именованные группы выражения
+func regexParser(re *regexp.Regexp) recordParser {
+	return func(line string) (map[string]string, bool) {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			return nil, false
+		}
+
+		fields := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if name != "" && m[i] != "" {
+				fields[name] = m[i]
+			}
+		}
+		return fields, true
+	}
+}
+
+// parseJSONLine разбирает JSON-объект. Вложенные объекты разворачиваются
+// в поля через точку: {"http":{"status":200}} -> http.status=200
+func parseJSONLine(line string) (map[string]string, bool) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, false
+	}
+
+	fields := make(map[string]string)
+	flattenJSON("", object, fields)
+	return fields, true
+}
+
+// flattenJSON раскладывает значения объекта в плоский набор полей
+func flattenJSON(prefix string, object map[string]interface{}, fields map[string]string) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case string:
+			fields[key] = v
+		case json.Number:
+			fields[key] = v.String()
+		case bool:
+			fields[key] = strconv.FormatBool(v)
+		case map[string]interface{}:
+			flattenJSON(key, v, fields)
+		default:
+			// Массивы сохраняем как JSON
+			if encoded, err := json.Marshal(v); err == nil {
+				fields[key] = string(encoded)
+			}
+		}
+	}
+}
+
+// parseKV разбирает пары key=value, значения могут быть в кавычках
+func parseKV(line string) (map[string]string, bool) {
+	matches := kvPattern.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	fields := make(map[string]string)
+	for _, m := range matches {
+		key, value := m[1], m[2]
+		switch {
+		case strings.HasPrefix(value, `"`):
+			value = strings.ReplaceAll(strings.Trim(value, `"`), `\"`, `"`)
+		case strings.HasPrefix(value, "'"):
+			value = strings.Trim(value, "'")
+		}
+		fields[key] = value
+	}
+	return fields, true
+}
+
+// parseCEF разбирает CEF:Version|Vendor|Product|Version|SignatureID|Name|Severity|Extension.
+// Перед "CEF:" может стоять заголовок syslog
+func parseCEF(line string) (map[string]string, bool) {
+	start := strings.Index(line, "CEF:")
+	if start < 0 {
+		return nil, false
+	}
+
+	parts := splitEscaped(line[start+len("CEF:"):], '|', 8)
+	if len(parts) < 7 {
+		return nil, false
+	}
+
+	fields := map[string]string{
+		"cef_version":    parts[0],
+		"device_vendor":  parts[1],
+		"device_product": parts[2],
+		"device_version": parts[3],
+		"signature_id":   parts[4],
+		"name":           parts[5],
+		"severity":       parts[6],
+	}
+	if len(parts) == 8 {
+		for key, value := range parseCEFExtension(parts[7]) {
+			fields[key] = value
+		}
+	}
+	return fields, true
+}
+
+// parseCEFExtension разбирает расширение CEF: значение продолжается до следующего " key="
+func parseCEFExtension(extension string) map[string]string {
+	fields := make(map[string]string)
+
+	matches := cefExtensionPattern.FindAllStringSubmatchIndex(extension, -1)
+	for i, m := range matches {
+		key := extension[m[2]:m[3]]
+		end := len(extension)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		fields[key] = unescapeCEF(strings.TrimSpace(extension[m[1]:end]))
+	}
+	return fields
+}
+
+// parseLEEF разбирает LEEF:1.0|Vendor|Product|Version|EventID|атрибуты и
+// LEEF:2.0|Vendor|Product|Version|EventID|Разделитель|атрибуты.
+// Атрибуты key=value разделены табуляцией или указанным разделителем
+func parseLEEF(line string) (map[string]string, bool) {
+	start := strings.Index(line, "LEEF:")
+	if start < 0 {
+		return nil, false
+	}
+	rest := line[start+len("LEEF:"):]
+
+	headerParts := 6
+	if strings.HasPrefix(rest, "2") {
+		headerParts = 7
+	}
+	parts := strings.SplitN(rest, "|", headerParts)
+	if len(parts) < 5 {
+		return nil, false
+	}
+
+	fields := map[string]string{
+		"leef_version":   parts[0],
+		"device_vendor":  parts[1],
+		"device_product": parts[2],
+		"device_version": parts[3],
+		"event_id":       parts[4],
+	}
+	if len(parts) < headerParts {
+		return fields, true
+	}
+
+	delimiter := "\t"
+	if headerParts == 7 {
+		delimiter = leefDelimiter(parts[5])
+	}
+	for _, attribute := range strings.Split(parts[headerParts-1], delimiter) {
+		if key, value, ok := strings.Cut(attribute, "="); ok && key != "" {
+			fields[strings.TrimSpace(key)] = value
+		}
+	}
+	return fields, true
+}
+
+// leefDelimiter разделитель атрибутов LEEF 2.0: символ или его код (x09, 0x09)
+func leefDelimiter(value string) string {
+	if hex, ok := strings.CutPrefix(strings.TrimPrefix(value, "0"), "x"); ok && hex != "" {
+		if code, err := strconv.ParseUint(hex, 16, 8); err == nil {
+			return string(rune(code))
+		}
+	}
+	if value == "" {
+		return "\t"
+	}
+	return value
+}
+
+// splitEscaped делит строку по sep, пропуская экранированные "\sep",
+// не более чем на n частей
+func splitEscaped(value string, sep byte, n int) []string {
+	var parts []string
+	var current bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if len(parts) == n-1 {
+			current.WriteString(value[i:])
+			break
+		}
+		switch {
+		case c == '\\' && i+1 < len(value) && (value[i+1] == sep || value[i+1] == '\\'):
+			current.WriteByte(value[i+1])
+			i++
+		case c == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(parts, current.String())
+}
+
+// unescapeCEF раскрывает экранирование значений расширения CEF
+func unescapeCEF(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	return strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(value)
+}
+
+// parseTimestamp разбирает время записи. Без layout пробуются распространённые
+// форматы и epoch в секундах или миллисекундах
+func parseTimestamp(value, layout string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if layout != "" {
+		t, err := time.ParseInLocation(layout, value, currentLocation())
+		if err != nil {
+			return time.Time{}, false
+		}
+		// Формат без года (как в syslog): год определяем по текущей дате
+		if t.Year() == 0 {
+			t = inferYear(t, time.Now())
+		}
+		return t, true
+	}
+
+	if m := epochPattern.FindStringSubmatch(value); m != nil {
+		number, _ := strconv.ParseInt(m[1], 10, 64)
+		if len(m[1]) == 13 {
+			return time.UnixMilli(number), true
+		}
+		nanos := int64(0)
+		if m[2] != "" {
+			fraction := (m[2] + "000000000")[:9]
+			nanos, _ = strconv.ParseInt(fraction, 10, 64)
+		}
+		return time.Unix(number, nanos), true
+	}
+
+	for _, candidate := range timestampLayouts {
+		if t, err := time.ParseInLocation(candidate, value, currentLocation()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeSeverity приводит уровень из записи к INFO/WARNING/CRITICAL.
+// Числовые уровни — в шкале CEF/LEEF 0-10
+func normalizeSeverity(value string) string {
+	if level, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		switch {
+		case level >= 7:
+			return "CRITICAL"
+		case level >= 4:
+			return "WARNING"
+		default:
+			return "INFO"
+		}
+	}
+
+	upper := strings.ToUpper(value)
+	for _, marker := range []string{"CRIT", "FATAL", "EMERG", "ERR", "HIGH"} {
+		if strings.Contains(upper, marker) {
+			return "CRITICAL"
+		}
+	}
+	for _, marker := range []string{"WARN", "ALERT", "MEDIUM"} {
+		if strings.Contains(upper, marker) {
+			return "WARNING"
+		}
+	}
+	return "INFO"
+}
@@ -0,0 +1,252 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks значение USER_HZ, в котором /proc/<pid>/stat указывает время запуска
+const clockTicks = 100
+
+// suspiciousExeDirs каталоги, запуск программ из которых подозрителен
+var suspiciousExeDirs = []string{"/tmp/", "/var/tmp/", "/dev/shm/"}
+
+// procInfo сведения о процессе из /proc
+type procInfo struct {
+	pid       string
+	ppid      string
+	uid       string
+	comm      string
+	exe       string
+	cmdline   string
+	cwd       string
+	startTime time.Time
+	startKey  string // время запуска в тиках: отличает процессы с переиспользованным pid
+}
+
+// ProcessCollector периодически обходит /proc и сообщает о запущенных и
+// завершившихся процессах. Процессы, прожившие меньше интервала сбора, не видны
+type ProcessCollector struct {
+	procRoot  string
+	bootTime  time.Time
+	processes map[string]procInfo // pid -> процесс
+	started   bool
+}
+
+// NewProcessCollector создаёт сборщик для каталога procRoot (обычно /proc)
+func NewProcessCollector(procRoot string) *ProcessCollector {
+	return &ProcessCollector{
+		procRoot:  procRoot,
+		processes: make(map[string]procInfo),
+	}
+}
+
+func (pc *ProcessCollector) Collect() ([]Event, error) {
+	if pc.bootTime.IsZero() {
+		bootTime, err := readBootTime(pc.procRoot)
+		if err != nil {
+			return nil, err
+		}
+		pc.bootTime = bootTime
+	}
+
+	entries, err := os.ReadDir(pc.procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pc.procRoot, err)
+	}
+
+	current := make(map[string]procInfo)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		info, ok := pc.readProcess(entry.Name())
+		if !ok {
+			continue
+		}
+		current[info.pid] = info
+	}
+
+	// Первый обход — исходное состояние, о нём не сообщаем
+	if !pc.started {
+		pc.started = true
+		pc.processes = current
+		log.Printf("[Collector] Tracking %d processes", len(current))
+		return nil, nil
+	}
+
+	var events []Event
+	for pid, info := range current {
+		previous, known := pc.processes[pid]
+		if known && previous.startKey == info.startKey {
+			continue
+		}
+		if known {
+			// pid переиспользован: прежний процесс завершился
+			events = append(events, pc.processEvent("process_exited", previous))
+		}
+		events = append(events, pc.processEvent("process_started", info))
+	}
+	for pid, info := range pc.processes {
+		if _, alive := current[pid]; !alive {
+			events = append(events, pc.processEvent("process_exited", info))
+		}
+	}
+
+	pc.processes = current
+	return events, nil
+}
+
+func (pc *ProcessCollector) GetSourceName() string {
+	return "process"
+}
+
+func (pc *ProcessCollector) GetSourceType() string {
+	return "process"
+}
+
+// readProcess читает сведения о процессе. false — процесс уже завершился
+// или это поток ядра
+func (pc *ProcessCollector) readProcess(pid string) (procInfo, bool) {
+	dir := filepath.Join(pc.procRoot, pid)
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return procInfo{}, false
+	}
+	info, ok := parseProcStat(string(stat))
+	if !ok {
+		return procInfo{}, false
+	}
+	info.pid = pid
+
+	if ticks, err := strconv.ParseInt(info.startKey, 10, 64); err == nil {
+		info.startTime = pc.bootTime.Add(time.Duration(ticks) * time.Second / clockTicks)
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		info.cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	info.exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+	info.cwd, _ = os.Readlink(filepath.Join(dir, "cwd"))
+	info.uid = readProcUID(filepath.Join(dir, "status"))
+
+	// У потоков ядра нет ни исполняемого файла, ни командной строки
+	if info.exe == "" && info.cmdline == "" {
+		return procInfo{}, false
+	}
+	return info, true
+}
+
+// processEvent создаёт событие запуска или завершения процесса
+func (pc *ProcessCollector) processEvent(eventType string, info procInfo) Event {
+	event := Event{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Source:    "process",
+		EventType: eventType,
+		Severity:  "INFO",
+		User:      info.uid,
+		Process:   info.comm,
+		Command:   info.cmdline,
+		RawLog:    fmt.Sprintf("%s pid=%s ppid=%s uid=%s exe=%s cmdline=%s", eventType, info.pid, info.ppid, info.uid, info.exe, info.cmdline),
+		Fields: map[string]string{
+			"pid":  info.pid,
+			"ppid": info.ppid,
+			"uid":  info.uid,
+			"exe":  info.exe,
+			"cwd":  info.cwd,
+		},
+	}
+	if !info.startTime.IsZero() {
+		event.Fields["start_time"] = info.startTime.Format(time.RFC3339)
+		if eventType == "process_started" {
+			event.Timestamp = event.Fields["start_time"]
+		}
+	}
+
+	if strings.HasSuffix(info.exe, " (deleted)") {
+		event.Fields["exe_deleted"] = "true"
+		event.Severity = "WARNING"
+	}
+	for _, dir := range suspiciousExeDirs {
+		if strings.HasPrefix(info.exe, dir) {
+			event.Fields["suspicious_location"] = "true"
+			event.Severity = "WARNING"
+			break
+		}
+	}
+	if eventType == "process_started" && determineBashCommandSeverity(info.cmdline) == "WARNING" {
+		event.Severity = "WARNING"
+	}
+
+	return event
+}
+
+// parseProcStat разбирает /proc/<pid>/stat: "pid (comm) state ppid ... starttime ...".
+// comm может содержать пробелы и скобки, поэтому ищем последнюю ")"
+func parseProcStat(stat string) (procInfo, bool) {
+	left := strings.IndexByte(stat, '(')
+	right := strings.LastIndexByte(stat, ')')
+	if left < 0 || right < left {
+		return procInfo{}, false
+	}
+
+	// Поля после comm начинаются с третьего (state); starttime — 22-е
+	fields := strings.Fields(stat[right+1:])
+	if len(fields) < 20 {
+		return procInfo{}, false
+	}
+
+	return procInfo{
+		comm:     stat[left+1 : right],
+		ppid:     fields[1],
+		startKey: fields[19],
+	}, true
+}
+
+// readProcUID возвращает реальный UID из /proc/<pid>/status
+func readProcUID(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "Uid:"); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
+
+// readBootTime читает время загрузки системы (btime) из /proc/stat
+func readBootTime(procRoot string) (time.Time, error) {
+	file, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read boot time: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime %q", rest)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("btime not found in %s", filepath.Join(procRoot, "stat"))
+}
@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeProc каталог, повторяющий устройство /proc
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	t.Helper()
+	fp := &fakeProc{t: t, root: t.TempDir()}
+	fp.write("stat", "cpu  1 2 3 4\nbtime 1709280000\nprocesses 100\n")
+	return fp
+}
+
+func (fp *fakeProc) write(name, content string) {
+	fp.t.Helper()
+	path := filepath.Join(fp.root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fp.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+// start добавляет процесс. startTicks — время запуска после загрузки в тиках
+func (fp *fakeProc) start(pid int, comm, exe string, args []string, ppid, uid, startTicks int) {
+	fp.t.Helper()
+	dir := fmt.Sprint(pid)
+	fp.write(dir+"/stat", fmt.Sprintf("%d (%s) S %d %d %d 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 %d 1000 100", pid, comm, ppid, pid, pid, startTicks))
+	cmdline := ""
+	for _, arg := range args {
+		cmdline += arg + "\x00"
+	}
+	fp.write(dir+"/cmdline", cmdline)
+	fp.write(dir+"/status", fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", comm, uid, uid, uid, uid))
+	if exe != "" {
+		if err := os.Symlink(exe, filepath.Join(fp.root, dir, "exe")); err != nil {
+			fp.t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/home/alice", filepath.Join(fp.root, dir, "cwd")); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+func (fp *fakeProc) exit(pid int) {
+	fp.t.Helper()
+	if err := os.RemoveAll(filepath.Join(fp.root, fmt.Sprint(pid))); err != nil {
+		fp.t.Fatal(err)
+	}
+}
+
+func collectProcesses(t *testing.T, pc *ProcessCollector) map[string]Event {
+	t.Helper()
+	events, err := pc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	result := make(map[string]Event)
+	for _, event := range events {
+		result[event.EventType+" "+event.Fields["pid"]] = event
+	}
+	return result
+}
+
+func TestProcessCollectorStartAndExit(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.start(1, "systemd", "/usr/lib/systemd/systemd", []string{"/sbin/init"}, 0, 0, 10)
+	proc.start(2, "kthreadd", "", nil, 0, 0, 10) // поток ядра
+	proc.start(500, "sshd", "/usr/sbin/sshd", []string{"/usr/sbin/sshd", "-D"}, 1, 0, 1000)
+
+	pc := NewProcessCollector(proc.root)
+	if events := collectProcesses(t, pc); len(events) != 0 {
+		t.Fatalf("initial scan reported %v", events)
+	}
+
+	proc.start(1000, "bash", "/usr/bin/bash", []string{"bash", "-c", "echo hi"}, 500, 1000, 5000)
+	proc.exit(500)
+	events := collectProcesses(t, pc)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want start of 1000 and exit of 500: %v", len(events), events)
+	}
+
+	started, ok := events["process_started 1000"]
+	if !ok {
+		t.Fatal("no process_started for pid 1000")
+	}
+	if started.Process != "bash" || started.Command != "bash -c echo hi" || started.User != "1000" {
+		t.Errorf("started = %+v", started)
+	}
+	want := map[string]string{"ppid": "500", "uid": "1000", "exe": "/usr/bin/bash", "cwd": "/home/alice"}
+	for key, value := range want {
+		if started.Fields[key] != value {
+			t.Errorf("Fields[%q] = %q, want %q", key, started.Fields[key], value)
+		}
+	}
+	// btime + 5000 тиков (50 секунд)
+	if wantStart := time.Unix(1709280050, 0).Format(time.RFC3339); started.Timestamp != wantStart {
+		t.Errorf("timestamp = %s, want start time %s", started.Timestamp, wantStart)
+	}
+
+	if exited, ok := events["process_exited 500"]; !ok || exited.Process != "sshd" {
+		t.Errorf("exited = %+v, %v", exited, ok)
+	}
+
+	if events := collectProcesses(t, pc); len(events) != 0 {
+		t.Errorf("unchanged /proc reported %v", events)
+	}
+}
+
+func TestProcessCollectorReusedPid(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.start(700, "sleep", "/usr/bin/sleep", []string{"sleep", "1"}, 1, 1000, 100)
+	pc := NewProcessCollector(proc.root)
+	collectProcesses(t, pc)
+
+	// Между обходами процесс завершился, и его pid получил новый процесс
+	proc.exit(700)
+	proc.start(700, "curl", "/tmp/curl", []string{"/tmp/curl", "http://203.0.113.7/x"}, 1, 1000, 900)
+	events := collectProcesses(t, pc)
+	if _, ok := events["process_exited 700"]; !ok {
+		t.Error("no process_exited for the previous process")
+	}
+	started, ok := events["process_started 700"]
+	if !ok {
+		t.Fatal("no process_started for the new process")
+	}
+	if started.Fields["suspicious_location"] != "true" || started.Severity != "WARNING" {
+		t.Errorf("started from /tmp: severity %s, fields %v", started.Severity, started.Fields)
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name     string
+		stat     string
+		ok       bool
+		comm     string
+		ppid     string
+		startKey string
+	}{
+		{
+			name:     "plain",
+			stat:     "42 (bash) S 1 42 42 34816 42 4194304 1 0 0 0 0 0 0 0 20 0 1 0 12345 1000 100",
+			ok:       true,
+			comm:     "bash",
+			ppid:     "1",
+			startKey: "12345",
+		},
+		{
+			name:     "comm with spaces and parentheses",
+			stat:     "77 (tmux: server) (x)) R 5 77 77 0 -1 4194304 1 0 0 0 0 0 0 0 20 0 1 0 999 1000 100",
+			ok:       true,
+			comm:     "tmux: server) (x)",
+			ppid:     "5",
+			startKey: "999",
+		},
+		{name: "truncated", stat: "42 (bash) S 1 42", ok: false},
+		{name: "no comm", stat: "42 bash S 1", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := parseProcStat(tt.stat)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if info.comm != tt.comm || info.ppid != tt.ppid || info.startKey != tt.startKey {
+				t.Errorf("got comm %q ppid %q start %q", info.comm, info.ppid, info.startKey)
+			}
+		})
+	}
+}
+
+func TestProcessCmdline(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		exe  string
+		want string
+		seen bool
+	}{
+		{"arguments", []string{"python3", "-m", "http.server", "8080"}, "/usr/bin/python3", "python3 -m http.server 8080", true},
+		{"empty argument", []string{"sh", "", "x"}, "/bin/sh", "sh  x", true},
+		{"zombie without cmdline", nil, "/usr/bin/zombie", "", true},
+		{"kernel thread", nil, "", "", false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := newFakeProc(t)
+			pid := 100 + i
+			proc.start(pid, "test", tt.exe, tt.args, 1, 0, 10)
+			pc := NewProcessCollector(proc.root)
+			pc.bootTime = time.Unix(1709280000, 0)
+
+			info, ok := pc.readProcess(fmt.Sprint(pid))
+			if ok != tt.seen {
+				t.Fatalf("readProcess ok = %v, want %v", ok, tt.seen)
+			}
+			if info.cmdline != tt.want {
+				t.Errorf("cmdline = %q, want %q", info.cmdline, tt.want)
+			}
+		})
+	}
+}
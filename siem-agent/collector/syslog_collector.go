@@ -1,52 +1,53 @@
 package collector
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strings"
 	"time"
+
+	"agent/checkpoint"
 )
 
 type SyslogCollector struct {
 	filePath string
-	lastPos  int64
+	tailer   *fileTailer
 }
 
 func NewSyslogCollector(filePath string) *SyslogCollector {
 	return &SyslogCollector{
 		filePath: filePath,
-		lastPos:  0,
+		tailer:   newFileTailer(filePath),
 	}
 }
 
 func (sc *SyslogCollector) Collect() ([]Event, error) {
 	var events []Event
 
-	file, err := os.Open(sc.filePath)
+	err := sc.tailer.readLines(func(line string) {
+		if strings.TrimSpace(line) != "" {
+			events = append(events, sc.parseSyslogLine(line))
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open syslog file: %w", err)
+		return events, fmt.Errorf("failed to read syslog file: %w", err)
 	}
-	defer file.Close()
 
-	// Ищем последнюю прочитанную позицию
-	file.Seek(sc.lastPos, 0)
+	return events, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			event := sc.parseSyslogLine(line)
-			events = append(events, event)
-		}
-	}
+// Position возвращает текущую позицию чтения
+func (sc *SyslogCollector) Position() checkpoint.Position {
+	return sc.tailer.position()
+}
 
-	// Сохраняем новую позицию
-	if pos, err := file.Seek(0, 1); err == nil {
-		sc.lastPos = pos
-	}
+// Restore восстанавливает сохранённую позицию чтения
+func (sc *SyslogCollector) Restore(pos checkpoint.Position) {
+	sc.tailer.restore(pos)
+}
 
-	return events, scanner.Err()
+// WatchPaths возвращает файл, за которым нужно следить
+func (sc *SyslogCollector) WatchPaths() []string {
+	return []string{sc.tailer.path}
 }
 
 func (sc *SyslogCollector) GetSourceName() string {
@@ -66,6 +67,11 @@ func (sc *SyslogCollector) parseSyslogLine(rawLog string) Event {
 		Severity:  determineSeverity(rawLog),
 	}
 
+	// Время из префикса строки: при чтении накопившихся строк время сбора неверно
+	if t, ok := parseSyslogTimestamp(rawLog); ok {
+		event.Timestamp = t.Format(time.RFC3339Nano)
+	}
+
 	// Извлекаем сервис/процесс
 	process, eventType := extractSyslogService(rawLog)
 	event.Process = process
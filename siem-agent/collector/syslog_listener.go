@@ -0,0 +1,427 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogBacklog сколько принятых сообщений держим в памяти до следующего Collect.
+	// При заполнении приём приостанавливается
+	syslogBacklog = 10000
+
+	// syslogMaxMessage максимальная длина сообщения
+	syslogMaxMessage = 64 * 1024
+)
+
+var (
+	syslog5424Pattern = regexp.MustCompile(`^(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) (?s:(.*))$`)
+	syslog3164Pattern = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?|\d{4}-\d{2}-\d{2}T\S+) (?:(\S+) )?([^\s:\[]+)(?:\[(\d+)\])?: ?(?s:(.*))$`)
+	sdElementPattern  = regexp.MustCompile(`\[([^\s\]=]+)((?:\s+[^\s=\]]+="(?:[^"\\]|\\.)*")*)\s*\]`)
+	sdParamPattern    = regexp.MustCompile(`([^\s=\]]+)="((?:[^"\\]|\\.)*)"`)
+)
+
+// syslogFacilities имена facility по номеру (RFC 5424)
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// SyslogListener принимает сообщения syslog по сети (UDP и TCP) и разбирает
+// форматы RFC 3164 (BSD) и RFC 5424
+type SyslogListener struct {
+	addresses []string // "udp://0.0.0.0:514", "tcp://:1514"
+
+	mu      sync.Mutex
+	running bool
+	events  chan Event
+	done    chan struct{}
+	closers []io.Closer
+	conns   map[net.Conn]bool
+	wg      sync.WaitGroup
+}
+
+// NewSyslogListener создаёт приёмник syslog для адресов вида "udp://host:port" и "tcp://host:port"
+func NewSyslogListener(addresses []string) *SyslogListener {
+	return &SyslogListener{addresses: addresses}
+}
+
+func (sl *SyslogListener) Collect() ([]Event, error) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if !sl.running {
+		if err := sl.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []Event
+	for {
+		select {
+		case event := <-sl.events:
+			events = append(events, event)
+		default:
+			return events, nil
+		}
+	}
+}
+
+func (sl *SyslogListener) GetSourceName() string {
+	return "syslog_listener"
+}
+
+func (sl *SyslogListener) GetSourceType() string {
+	return "syslog"
+}
+
+// Close закрывает сокеты и ждёт завершения приёма
+func (sl *SyslogListener) Close() error {
+	sl.mu.Lock()
+	if !sl.running {
+		sl.mu.Unlock()
+		return nil
+	}
+	sl.running = false
+	close(sl.done)
+	for _, closer := range sl.closers {
+		closer.Close()
+	}
+	for conn := range sl.conns {
+		conn.Close()
+	}
+	sl.mu.Unlock()
+
+	sl.wg.Wait()
+	return nil
+}
+
+// start открывает все адреса. Если хотя бы один не открылся, закрывает остальные
+func (sl *SyslogListener) start() error {
+	sl.events = make(chan Event, syslogBacklog)
+	sl.done = make(chan struct{})
+	sl.closers = nil
+	sl.conns = make(map[net.Conn]bool)
+
+	for _, address := range sl.addresses {
+		network, hostPort, ok := strings.Cut(address, "://")
+		if !ok {
+			network, hostPort = "udp", address
+		}
+
+		switch network {
+		case "udp":
+			conn, err := net.ListenPacket("udp", hostPort)
+			if err != nil {
+				sl.closeAll()
+				return fmt.Errorf("failed to listen on %s: %w", address, err)
+			}
+			sl.closers = append(sl.closers, conn)
+			sl.wg.Add(1)
+			go sl.serveUDP(conn)
+		case "tcp":
+			listener, err := net.Listen("tcp", hostPort)
+			if err != nil {
+				sl.closeAll()
+				return fmt.Errorf("failed to listen on %s: %w", address, err)
+			}
+			sl.closers = append(sl.closers, listener)
+			sl.wg.Add(1)
+			go sl.serveTCP(listener)
+		default:
+			sl.closeAll()
+			return fmt.Errorf("unsupported network %q in %s", network, address)
+		}
+		log.Printf("[Collector] Syslog listener on %s", address)
+	}
+
+	sl.running = true
+	return nil
+}
+
+// closeAll закрывает уже открытые сокеты при ошибке запуска
+func (sl *SyslogListener) closeAll() {
+	close(sl.done)
+	for _, closer := range sl.closers {
+		closer.Close()
+	}
+	sl.closers = nil
+}
+
+// serveUDP принимает датаграммы: одна датаграмма — одно сообщение
+func (sl *SyslogListener) serveUDP(conn net.PacketConn) {
+	defer sl.wg.Done()
+
+	buf := make([]byte, syslogMaxMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-sl.done:
+			default:
+				log.Printf("[Collector] Syslog UDP receive error: %v", err)
+			}
+			return
+		}
+
+		message := strings.TrimRight(string(buf[:n]), "\r\n\x00")
+		if message == "" {
+			continue
+		}
+		if !sl.deliver(parseSyslogMessage(message, addr, "udp")) {
+			return
+		}
+	}
+}
+
+// serveTCP принимает соединения
+func (sl *SyslogListener) serveTCP(listener net.Listener) {
+	defer sl.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-sl.done:
+			default:
+				log.Printf("[Collector] Syslog TCP accept error: %v", err)
+			}
+			return
+		}
+
+		sl.mu.Lock()
+		select {
+		case <-sl.done:
+			// Соединение принято во время остановки
+			sl.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		sl.conns[conn] = true
+		sl.mu.Unlock()
+
+		sl.wg.Add(1)
+		go sl.serveConn(conn)
+	}
+}
+
+// serveConn читает сообщения из TCP-соединения. Поддерживаются оба способа
+// разделения (RFC 6587): с длиной перед сообщением ("120 <34>1 ...") и по переводу строки
+func (sl *SyslogListener) serveConn(conn net.Conn) {
+	defer sl.wg.Done()
+	defer func() {
+		sl.mu.Lock()
+		delete(sl.conns, conn)
+		sl.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReaderSize(conn, syslogMaxMessage)
+	for {
+		message, err := readSyslogFrame(reader)
+		if err != nil {
+			if err != io.EOF {
+				select {
+				case <-sl.done:
+				default:
+					log.Printf("[Collector] Syslog TCP read error from %s: %v", conn.RemoteAddr(), err)
+				}
+			}
+			return
+		}
+		if message == "" {
+			continue
+		}
+		if !sl.deliver(parseSyslogMessage(message, conn.RemoteAddr(), "tcp")) {
+			return
+		}
+	}
+}
+
+// deliver передаёт событие в очередь. false означает, что приёмник остановлен
+func (sl *SyslogListener) deliver(event Event) bool {
+	select {
+	case sl.events <- event:
+		return true
+	case <-sl.done:
+		return false
+	}
+}
+
+// readSyslogFrame читает одно сообщение из TCP-потока
+func readSyslogFrame(reader *bufio.Reader) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	// Octet counting: "<длина> <сообщение>"
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(prefix))
+		if err != nil || length > syslogMaxMessage {
+			return "", fmt.Errorf("invalid frame length %q", strings.TrimSpace(prefix))
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(frame), "\r\n"), nil
+	}
+
+	// Non-transparent framing: сообщение до перевода строки
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n\x00"), nil
+}
+
+// parseSyslogMessage разбирает сообщение RFC 5424 или RFC 3164
+func parseSyslogMessage(message string, sender net.Addr, transport string) Event {
+	event := Event{
+		RawLog:    message,
+		Source:    "syslog_listener",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Severity:  determineSeverity(message),
+		Fields:    map[string]string{"transport": transport},
+	}
+
+	senderHost := ""
+	if sender != nil {
+		event.Fields["sender"] = sender.String()
+		if host, _, err := net.SplitHostPort(sender.String()); err == nil {
+			senderHost = host
+			event.Fields["sender_ip"] = host
+		}
+	}
+
+	rest := message
+	if priority, remaining, ok := parseSyslogPriority(message); ok {
+		rest = remaining
+		facility := priority / 8
+		level := priority % 8
+		event.Severity = syslogSeverity(level)
+		event.Fields["facility"] = strconv.Itoa(facility)
+		if facility < len(syslogFacilities) {
+			event.Fields["facility_name"] = syslogFacilities[facility]
+		}
+		event.Fields["syslog_severity"] = strconv.Itoa(level)
+	}
+
+	if m := syslog5424Pattern.FindStringSubmatch(rest); m != nil && m[1] != "0" {
+		parseSyslog5424(&event, m)
+	} else if m := syslog3164Pattern.FindStringSubmatch(rest); m != nil {
+		if t, ok := parseSyslogTimestamp(m[1] + " "); ok {
+			event.Timestamp = t.Format(time.RFC3339Nano)
+		}
+		event.Hostname = m[2]
+		event.Process = m[3]
+		if m[4] != "" {
+			event.Fields["pid"] = m[4]
+		}
+		event.Fields["message"] = m[5]
+	} else {
+		event.Fields["message"] = rest
+	}
+
+	if event.Hostname == "" || event.Hostname == "-" {
+		event.Hostname = senderHost
+	}
+	event.EventType = syslogEventType(event.Process)
+	if user := extractUser(event.Fields["message"]); user != "" {
+		event.User = user
+	}
+
+	return event
+}
+
+// parseSyslogPriority разбирает "<PRI>" в начале сообщения
+func parseSyslogPriority(message string) (int, string, bool) {
+	if !strings.HasPrefix(message, "<") {
+		return 0, message, false
+	}
+	end := strings.IndexByte(message, '>')
+	if end < 2 || end > 4 {
+		return 0, message, false
+	}
+	priority, err := strconv.Atoi(message[1:end])
+	if err != nil || priority > 191 {
+		return 0, message, false
+	}
+	return priority, message[end+1:], true
+}
+
+// parseSyslog5424 заполняет событие из "VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG"
+func parseSyslog5424(event *Event, m []string) {
+	if t, err := time.Parse(time.RFC3339Nano, m[2]); err == nil {
+		event.Timestamp = t.Format(time.RFC3339Nano)
+	}
+	event.Hostname = m[3]
+
+	for key, value := range map[string]string{"app_name": m[4], "procid": m[5], "msgid": m[6]} {
+		if value != "-" {
+			event.Fields[key] = value
+		}
+	}
+	if m[4] != "-" {
+		event.Process = m[4]
+	}
+	if m[5] != "-" {
+		event.Fields["pid"] = m[5]
+	}
+
+	// Структурированные данные: "-" или последовательность [id key="value" ...]
+	rest := m[7]
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "-"), " ")
+	} else {
+		for strings.HasPrefix(rest, "[") {
+			loc := sdElementPattern.FindStringSubmatchIndex(rest)
+			if loc == nil || loc[0] != 0 {
+				break
+			}
+			id := rest[loc[2]:loc[3]]
+			for _, param := range sdParamPattern.FindAllStringSubmatch(rest[loc[4]:loc[5]], -1) {
+				event.Fields["sd."+id+"."+param[1]] = unescapeSDValue(param[2])
+			}
+			rest = rest[loc[1]:]
+		}
+		rest = strings.TrimPrefix(rest, " ")
+	}
+
+	event.Fields["message"] = strings.TrimPrefix(rest, "\ufeff")
+}
+
+// unescapeSDValue раскрывает экранирование \" \\ \] в значениях структурированных данных
+func unescapeSDValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\]`, `]`).Replace(value)
+}
+
+// syslogSeverity переводит уровень syslog (0-7) в уровень серьёзности события
+func syslogSeverity(level int) string {
+	switch {
+	case level <= 3: // emerg, alert, crit, err
+		return "CRITICAL"
+	case level == 4: // warning
+		return "WARNING"
+	default: // notice, info, debug
+		return "INFO"
+	}
+}
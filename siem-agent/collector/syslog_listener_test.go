@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadSyslogFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		frames []string
+		err    bool // последний кадр завершается ошибкой, кроме io.EOF
+	}{
+		{
+			name:   "non-transparent framing",
+			stream: "<13>Mar  1 12:00:00 host app: one\n<13>Mar  1 12:00:01 host app: two\r\n",
+			frames: []string{"<13>Mar  1 12:00:00 host app: one", "<13>Mar  1 12:00:01 host app: two"},
+		},
+		{
+			name:   "octet counting",
+			stream: "11 <13>1 - - x9 <13>hello",
+			frames: []string{"<13>1 - - x", "<13>hello"},
+		},
+		{
+			name:   "octet counted frame with newline inside",
+			stream: "16 <13>line1\nline2\n",
+			frames: []string{"<13>line1\nline2"},
+		},
+		{
+			name:   "nul terminated",
+			stream: "<13>msg\x00\n",
+			frames: []string{"<13>msg"},
+		},
+		{
+			name:   "last frame without newline",
+			stream: "<13>tail",
+			frames: []string{"<13>tail"},
+		},
+		{
+			name:   "invalid length",
+			stream: "99999999 <13>x",
+			err:    true,
+		},
+		{
+			name:   "truncated counted frame",
+			stream: "50 <13>short",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.stream))
+			var frames []string
+			var err error
+			for {
+				var frame string
+				frame, err = readSyslogFrame(reader)
+				if err != nil {
+					break
+				}
+				frames = append(frames, frame)
+			}
+			if gotErr := err != io.EOF; gotErr != tt.err {
+				t.Fatalf("final error = %v, want error %v", err, tt.err)
+			}
+			if strings.Join(frames, "|") != strings.Join(tt.frames, "|") {
+				t.Errorf("frames = %q, want %q", frames, tt.frames)
+			}
+		})
+	}
+}
+
+func TestParseSyslogMessage(t *testing.T) {
+	sender := &net.UDPAddr{IP: net.ParseIP("192.0.2.50"), Port: 40000}
+
+	tests := []struct {
+		name      string
+		message   string
+		hostname  string
+		process   string
+		severity  string
+		timestamp string
+		fields    map[string]string
+	}{
+		{
+			name:      "rfc 5424 with structured data",
+			message:   `<165>1 2024-03-01T12:00:00.123Z web1 nginx 4242 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][meta seq="1"] request failed`,
+			hostname:  "web1",
+			process:   "nginx",
+			severity:  "INFO",
+			timestamp: "2024-03-01T12:00:00.123Z",
+			fields: map[string]string{
+				"facility":                         "20",
+				"facility_name":                    "local4",
+				"syslog_severity":                  "5",
+				"pid":                              "4242",
+				"msgid":                            "ID47",
+				"sd.exampleSDID@32473.iut":         "3",
+				"sd.exampleSDID@32473.eventSource": `App"lication`,
+				"sd.meta.seq":                      "1",
+				"message":                          "request failed",
+			},
+		},
+		{
+			name:     "rfc 5424 nil values and bom",
+			message:  "<11>1 - - - - - - \ufeffdisk failure",
+			hostname: "192.0.2.50",
+			severity: "CRITICAL",
+			fields:   map[string]string{"message": "disk failure", "facility_name": "user"},
+		},
+		{
+			name:     "rfc 3164 with host and pid",
+			message:  "<38>Mar  1 12:00:00 web1 sshd[100]: Failed password for root from 203.0.113.7 port 22 ssh2",
+			hostname: "web1",
+			process:  "sshd",
+			severity: "INFO",
+			fields:   map[string]string{"pid": "100", "facility_name": "auth", "message": "Failed password for root from 203.0.113.7 port 22 ssh2"},
+		},
+		{
+			name:     "rfc 3164 without host",
+			message:  "<12>Mar 10 08:15:30 cron: job done",
+			hostname: "192.0.2.50",
+			process:  "cron",
+			severity: "WARNING",
+			fields:   map[string]string{"message": "job done"},
+		},
+		{
+			name:     "no priority",
+			message:  "free text from a device",
+			hostname: "192.0.2.50",
+			fields:   map[string]string{"message": "free text from a device", "sender_ip": "192.0.2.50", "transport": "udp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := parseSyslogMessage(tt.message, sender, "udp")
+			if event.Source != "syslog_listener" || event.RawLog != tt.message {
+				t.Errorf("source %q, raw_log %q", event.Source, event.RawLog)
+			}
+			if event.Hostname != tt.hostname {
+				t.Errorf("hostname = %q, want %q", event.Hostname, tt.hostname)
+			}
+			if event.Process != tt.process {
+				t.Errorf("process = %q, want %q", event.Process, tt.process)
+			}
+			if tt.severity != "" && event.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", event.Severity, tt.severity)
+			}
+			if tt.timestamp != "" && event.Timestamp != tt.timestamp {
+				t.Errorf("timestamp = %q, want %q", event.Timestamp, tt.timestamp)
+			}
+			for key, value := range tt.fields {
+				if event.Fields[key] != value {
+					t.Errorf("Fields[%q] = %q, want %q", key, event.Fields[key], value)
+				}
+			}
+		})
+	}
+}
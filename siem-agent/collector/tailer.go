@@ -0,0 +1,313 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"agent/checkpoint"
+)
+
+// fingerprintSize сколько байт начала файла входит в отпечаток
+const fingerprintSize = 1024
+
+// fileTailer дочитывает файл с последней позиции и следит за ротацией:
+// при смене inode дочитывает переименованный файл (syslog.1, syslog.1.gz),
+// при усечении начинает файл заново
+type fileTailer struct {
+	path   string
+	offset int64
+	inode  uint64
+	device uint64
+
+	fingerprint     string
+	fingerprintSize int64
+
+	// recordSize размер записи двоичного файла (wtmp); 0 — файл читается по строкам
+	recordSize int
+}
+
+func newFileTailer(path string) *fileTailer {
+	return &fileTailer{path: path}
+}
+
+// readLines читает новые полные строки файла. Незавершённая последняя строка
+// остаётся непрочитанной до следующего вызова
+func (ft *fileTailer) readLines(handle func(line string)) error {
+	file, err := os.Open(ft.path)
+	if err != nil {
+		// Файл переименован, а новый ещё не создан: дочитываем старый
+		if os.IsNotExist(err) && ft.inode != 0 {
+			if !ft.drainRotated(handle) {
+				log.Printf("[Collector] %s was removed, previous file not found, unread lines are lost", ft.path)
+			}
+			ft.reset()
+		}
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	inode, device := fileIdentity(info)
+
+	switch {
+	case ft.inode == 0 && ft.fingerprint == "":
+		// Первое чтение файла
+	case inode != ft.inode || device != ft.device || !ft.sameFingerprint(file):
+		// Файл ротирован (inode мог быть переиспользован) или скопирован и усечён:
+		// сначала дочитываем старый файл, затем новый с начала
+		if !ft.drainRotated(handle) {
+			if inode == ft.inode && device == ft.device {
+				log.Printf("[Collector] %s was truncated, reading from the beginning", ft.path)
+			} else {
+				log.Printf("[Collector] %s was rotated, previous file not found, unread lines are lost", ft.path)
+			}
+		}
+		ft.reset()
+	case info.Size() < ft.offset:
+		// Файл усечён
+		log.Printf("[Collector] %s was truncated, reading from the beginning", ft.path)
+		ft.reset()
+	}
+	ft.inode, ft.device = inode, device
+
+	// Ищем последнюю прочитанную позицию
+	if _, err := file.Seek(ft.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := ft.readFrom(file, handle, false); err != nil {
+		return err
+	}
+
+	ft.updateFingerprint(file)
+	return nil
+}
+
+// readFrom читает строки из reader, сдвигая позицию. Если final, то последняя
+// строка без перевода строки тоже отдаётся: файл больше не будет дописан
+func (ft *fileTailer) readFrom(r io.Reader, handle func(line string), final bool) error {
+	if ft.recordSize > 0 {
+		return ft.readRecords(r, handle)
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			if final && line != "" {
+				handle(strings.TrimRight(line, "\r\n"))
+				ft.offset += int64(len(line))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Во время handle offset указывает на начало строки
+		handle(strings.TrimRight(line, "\r\n"))
+		ft.offset += int64(len(line))
+	}
+}
+
+// readRecords читает записи фиксированного размера. Неполная последняя запись
+// остаётся непрочитанной
+func (ft *fileTailer) readRecords(r io.Reader, handle func(record string)) error {
+	reader := bufio.NewReader(r)
+	record := make([]byte, ft.recordSize)
+	for {
+		if _, err := io.ReadFull(reader, record); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+
+		handle(string(record))
+		ft.offset += int64(ft.recordSize)
+	}
+}
+
+// drainRotated ищет переименованный после ротации файл и дочитывает его
+// с сохранённой позиции. Возвращает false, если такой файл не найден
+func (ft *fileTailer) drainRotated(handle func(line string)) bool {
+	rotated, compressed := ft.findRotated()
+	if rotated == "" {
+		return false
+	}
+
+	file, err := os.Open(rotated)
+	if err != nil {
+		log.Printf("[Collector] Failed to open rotated file %s: %v", rotated, err)
+		return true
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			log.Printf("[Collector] Failed to decompress rotated file %s: %v", rotated, err)
+			return true
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	// gzip не поддерживает Seek, поэтому пропускаем прочитанное
+	if _, err := io.CopyN(io.Discard, reader, ft.offset); err != nil {
+		log.Printf("[Collector] Rotated file %s is shorter than saved position: %v", rotated, err)
+		return true
+	}
+
+	log.Printf("[Collector] Draining rotated file %s from offset %d", rotated, ft.offset)
+	if err := ft.readFrom(reader, handle, true); err != nil {
+		log.Printf("[Collector] Error reading rotated file %s: %v", rotated, err)
+	}
+	return true
+}
+
+// findRotated находит среди кандидатов (path.1, path.1.gz, path-YYYYMMDD...)
+// файл, который раньше был path: по inode, а для сжатых по отпечатку начала
+func (ft *fileTailer) findRotated() (path string, compressed bool) {
+	candidates := []string{ft.path + ".1", ft.path + ".0", ft.path + ".1.gz", ft.path + ".0.gz"}
+	if dated, err := filepath.Glob(ft.path + "-*"); err == nil {
+		// Самые свежие файлы с датой в имени идут последними
+		sort.Sort(sort.Reverse(sort.StringSlice(dated)))
+		candidates = append(candidates, dated...)
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		isGzip := strings.HasSuffix(candidate, ".gz")
+
+		if !isGzip {
+			inode, device := fileIdentity(info)
+			if inode == ft.inode && device == ft.device {
+				return candidate, false
+			}
+		}
+		if ft.fingerprint != "" && fingerprintOfFile(candidate, isGzip, ft.fingerprintSize) == ft.fingerprint {
+			return candidate, isGzip
+		}
+	}
+
+	return "", false
+}
+
+// sameFingerprint проверяет, что начало файла не изменилось
+func (ft *fileTailer) sameFingerprint(file *os.File) bool {
+	if ft.fingerprint == "" {
+		return true
+	}
+	current, err := fingerprintOf(io.NewSectionReader(file, 0, ft.fingerprintSize), ft.fingerprintSize)
+	return err == nil && current == ft.fingerprint
+}
+
+// updateFingerprint пересчитывает отпечаток, пока файл короче fingerprintSize
+func (ft *fileTailer) updateFingerprint(file *os.File) {
+	if ft.fingerprintSize >= fingerprintSize {
+		return
+	}
+
+	size := ft.offset
+	if size > fingerprintSize {
+		size = fingerprintSize
+	}
+	if size == 0 || size == ft.fingerprintSize {
+		return
+	}
+
+	if fp, err := fingerprintOf(io.NewSectionReader(file, 0, size), size); err == nil {
+		ft.fingerprint = fp
+		ft.fingerprintSize = size
+	}
+}
+
+// reset переключает tailer на чтение нового файла с начала
+func (ft *fileTailer) reset() {
+	ft.offset = 0
+	ft.inode = 0
+	ft.device = 0
+	ft.fingerprint = ""
+	ft.fingerprintSize = 0
+}
+
+// position возвращает текущую позицию чтения
+func (ft *fileTailer) position() checkpoint.Position {
+	return checkpoint.Position{
+		Path:            ft.path,
+		Offset:          ft.offset,
+		Inode:           ft.inode,
+		Device:          ft.device,
+		Fingerprint:     ft.fingerprint,
+		FingerprintSize: ft.fingerprintSize,
+	}
+}
+
+// restore восстанавливает сохранённую позицию. Если файл за время простоя
+// ротировали или усекли, это обнаружит следующий readLines
+func (ft *fileTailer) restore(pos checkpoint.Position) {
+	ft.offset = pos.Offset
+	ft.inode = pos.Inode
+	ft.device = pos.Device
+	ft.fingerprint = pos.Fingerprint
+	ft.fingerprintSize = pos.FingerprintSize
+}
+
+// fingerprintOfFile считает отпечаток начала файла, при необходимости распаковывая gzip
+func fingerprintOfFile(path string, compressed bool, size int64) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return ""
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	fp, err := fingerprintOf(reader, size)
+	if err != nil {
+		return ""
+	}
+	return fp
+}
+
+// fingerprintOf считает SHA-256 первых size байт
+func fingerprintOf(r io.Reader, size int64) (string, error) {
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, r, size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileIdentity возвращает inode и устройство файла
+func fileIdentity(info os.FileInfo) (inode, device uint64) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino), uint64(stat.Dev)
+	}
+	return 0, 0
+}
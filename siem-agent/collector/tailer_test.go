@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tailLines читает новые строки и проверяет, что получены именно want
+func tailLines(t *testing.T, ft *fileTailer, want ...string) {
+	t.Helper()
+	var got []string
+	if err := ft.readLines(func(line string) { got = append(got, line) }); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("readLines: %v", err)
+	}
+	if len(got) != 0 || len(want) != 0 {
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("lines = %q, want %q", got, want)
+		}
+	}
+}
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTailerPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\ntw")
+	ft := newFileTailer(path)
+
+	tailLines(t, ft, "one")
+	appendFile(t, path, "o\r\nthree\n")
+	tailLines(t, ft, "two", "three")
+	tailLines(t, ft)
+}
+
+func TestTailerRotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		rotate func(t *testing.T, path string)
+	}{
+		{"rename to .1", func(t *testing.T, path string) {
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"rename with date", func(t *testing.T, path string) {
+			if err := os.Rename(path, path+"-20240301"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"compressed .1.gz", func(t *testing.T, path string) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeGzip(t, path+".1.gz", string(data))
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"copytruncate", func(t *testing.T, path string) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path+".1", data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Truncate(path, 0); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "syslog")
+			appendFile(t, path, "first\nsecond\n")
+			ft := newFileTailer(path)
+			tailLines(t, ft, "first", "second")
+
+			// Строка, дописанная перед ротацией, дочитывается из старого файла
+			appendFile(t, path, "third\n")
+			tt.rotate(t, path)
+			appendFile(t, path, "fourth\n")
+			tailLines(t, ft, "third", "fourth")
+
+			appendFile(t, path, "fifth\n")
+			tailLines(t, ft, "fifth")
+		})
+	}
+}
+
+func TestTailerRotatedBeforeNewFileCreated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog")
+	appendFile(t, path, "first\n")
+	ft := newFileTailer(path)
+	tailLines(t, ft, "first")
+
+	// Последняя строка без перевода строки: файл больше не будет дописан
+	appendFile(t, path, "second\nunterminated")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailLines(t, ft, "second", "unterminated")
+
+	appendFile(t, path, "third\n")
+	tailLines(t, ft, "third")
+}
+
+func TestTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a long line that will be truncated\n")
+	ft := newFileTailer(path)
+	tailLines(t, ft, "a long line that will be truncated")
+
+	// Файл короче прочитанного
+	if err := os.WriteFile(path, []byte("short\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tailLines(t, ft, "short")
+
+	// Файл усечён и дописан длиннее прежнего: размер не выдаёт усечения,
+	// выдаёт отпечаток начала
+	if err := os.WriteFile(path, []byte("replaced\nand longer than before\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tailLines(t, ft, "replaced", "and longer than before")
+	tailLines(t, ft)
+}
+
+func TestTailerRestoreAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog")
+	appendFile(t, path, "first\n")
+	ft := newFileTailer(path)
+	tailLines(t, ft, "first")
+	pos := ft.position()
+
+	// Агент остановлен: файл дописан и ротирован
+	appendFile(t, path, "second\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "third\n")
+
+	restarted := newFileTailer(path)
+	restarted.restore(pos)
+	tailLines(t, restarted, "second", "third")
+}
+
+func TestTailerRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wtmp")
+	appendFile(t, path, "abcdefghij")
+	ft := newFileTailer(path)
+	ft.recordSize = 4
+
+	// Неполная запись остаётся непрочитанной
+	tailLines(t, ft, "abcd", "efgh")
+	if ft.offset != 8 {
+		t.Fatalf("offset = %d, want 8", ft.offset)
+	}
+	appendFile(t, path, "klmn")
+	tailLines(t, ft, "ijkl")
+	if ft.offset != 12 {
+		t.Fatalf("offset = %d, want 12", ft.offset)
+	}
+
+	// После ротации дочитываются только полные записи старого файла
+	appendFile(t, path, "opqrs")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "uvwx")
+	tailLines(t, ft, "mnop", "uvwx")
+	if ft.offset != 4 {
+		t.Errorf("offset = %d, want 4", ft.offset)
+	}
+}
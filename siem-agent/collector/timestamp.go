@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// yearRolloverWindow насколько время записи может опережать часы агента.
+// Записи дальше в будущем относятся к прошлому году (декабрьские строки,
+// прочитанные в январе)
+const yearRolloverWindow = 7 * 24 * time.Hour
+
+var (
+	bsdTimestampPattern = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})(\.\d{1,9})?\s`)
+	isoTimestampPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d{1,9})?)(Z|[+-]\d{2}:?\d{2})?\s`)
+)
+
+var (
+	locationMu sync.RWMutex
+	location   = time.Local
+)
+
+// SetLocation задаёт часовой пояс для времени без указания зоны
+func SetLocation(loc *time.Location) {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	location = loc
+}
+
+// currentLocation возвращает часовой пояс для времени без указания зоны
+func currentLocation() *time.Location {
+	locationMu.RLock()
+	defer locationMu.RUnlock()
+	return location
+}
+
+// parseSyslogTimestamp разбирает время в начале строки syslog:
+// "Jan  5 02:47:49" (BSD), "2024-01-05T02:47:49.123456+03:00" (RFC 3339,
+// высокая точность rsyslog) и ISO-8601 без зоны
+func parseSyslogTimestamp(line string) (time.Time, bool) {
+	if m := bsdTimestampPattern.FindStringSubmatch(line); m != nil {
+		t, err := time.ParseInLocation("Jan _2 15:04:05", m[1], currentLocation())
+		if err != nil {
+			return time.Time{}, false
+		}
+		if m[2] != "" {
+			if fraction, err := time.ParseDuration("0" + m[2] + "s"); err == nil {
+				t = t.Add(fraction)
+			}
+		}
+		return inferYear(t, time.Now()), true
+	}
+
+	if m := isoTimestampPattern.FindStringSubmatch(line); m != nil {
+		value := m[1]
+		if len(value) > 10 && value[10] == ' ' {
+			value = value[:10] + "T" + value[11:]
+		}
+		zone := m[2]
+		if len(zone) == 5 {
+			// +0300 -> +03:00
+			zone = zone[:3] + ":" + zone[3:]
+		}
+
+		if zone == "" {
+			t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", value, currentLocation())
+			return t, err == nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value+zone)
+		return t, err == nil
+	}
+
+	return time.Time{}, false
+}
+
+// inferYear подставляет год во время без года. Берётся год, при котором время
+// ближе всего к now, но не опережает его больше чем на yearRolloverWindow
+func inferYear(t, now time.Time) time.Time {
+	now = now.In(t.Location())
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+
+	switch {
+	case t.Sub(now) > yearRolloverWindow:
+		// "Dec 31" в январе — прошлый год
+		return t.AddDate(-1, 0, 0)
+	case t.AddDate(1, 0, 0).Sub(now) <= yearRolloverWindow:
+		// "Jan  1" в конце декабря (часы источника спешат) — следующий год
+		return t.AddDate(1, 0, 0)
+	}
+	return t
+}
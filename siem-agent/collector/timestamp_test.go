@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferYear(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time // год в записи не указан
+		now  time.Time
+		want time.Time
+	}{
+		{"same year", date(0, time.June, 14, 8, 0, 0), date(2024, time.June, 15, 12, 0, 0), date(2024, time.June, 14, 8, 0, 0)},
+		{"december read in january", date(0, time.December, 31, 23, 59, 59), date(2024, time.January, 2, 10, 0, 0), date(2023, time.December, 31, 23, 59, 59)},
+		{"january written in late december", date(0, time.January, 1, 0, 0, 5), date(2023, time.December, 30, 22, 0, 0), date(2024, time.January, 1, 0, 0, 5)},
+		{"exactly window ahead", date(0, time.March, 8, 12, 0, 0), date(2024, time.March, 1, 12, 0, 0), date(2024, time.March, 8, 12, 0, 0)},
+		{"just past window", date(0, time.March, 8, 12, 0, 1), date(2024, time.March, 1, 12, 0, 0), date(2023, time.March, 8, 12, 0, 1)},
+		{"next year at window edge", date(0, time.January, 1, 0, 0, 0), date(2023, time.December, 25, 0, 0, 0), date(2024, time.January, 1, 0, 0, 0)},
+		{"next year past window", date(0, time.January, 1, 0, 0, 1), date(2023, time.December, 25, 0, 0, 0), date(2023, time.January, 1, 0, 0, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferYear(tt.t, tt.now); !got.Equal(tt.want) {
+				t.Errorf("inferYear = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyslogTimestamp(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	SetLocation(msk)
+	defer SetLocation(time.Local)
+
+	instant := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want time.Time
+	}{
+		{"rfc3339 with offset", "2024-01-05T02:47:49.123456+03:00 host sshd[1]: x", instant("2024-01-04T23:47:49.123456Z")},
+		{"offset without colon", "2024-01-05T02:47:49+0300 host sshd[1]: x", instant("2024-01-04T23:47:49Z")},
+		{"utc ignores configured zone", "2024-01-05T02:47:49Z host sshd[1]: x", instant("2024-01-05T02:47:49Z")},
+		{"rfc5424 timestamp", "2003-10-11T22:14:15.003Z ", instant("2003-10-11T22:14:15.003Z")},
+		{"iso without zone uses configured zone", "2024-01-05T02:47:49 host sshd[1]: x", instant("2024-01-04T23:47:49Z")},
+		{"iso with space separator", "2024-01-05 02:47:49.5 host sshd[1]: x", instant("2024-01-04T23:47:49.5Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSyslogTimestamp(tt.line)
+			if !ok {
+				t.Fatalf("parseSyslogTimestamp(%q) failed", tt.line)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSyslogTimestamp = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyslogTimestampBSD(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	SetLocation(msk)
+	defer SetLocation(time.Local)
+
+	// Год подставляет inferYear, поэтому сравниваем без него
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"Jan  5 02:47:49 host sshd[1]: x", "01-05 02:47:49.000 +0300"},
+		{"Dec 31 23:59:59 host cron[2]: y", "12-31 23:59:59.000 +0300"},
+		{"Mar 15 10:00:00.123456 host kernel: z", "03-15 10:00:00.123 +0300"},
+	}
+	for _, tt := range tests {
+		got, ok := parseSyslogTimestamp(tt.line)
+		if !ok {
+			t.Errorf("parseSyslogTimestamp(%q) failed", tt.line)
+			continue
+		}
+		if formatted := got.Format("01-02 15:04:05.000 -0700"); formatted != tt.want {
+			t.Errorf("parseSyslogTimestamp(%q) = %s, want %s", tt.line, formatted, tt.want)
+		}
+		if got.Sub(time.Now()) > yearRolloverWindow {
+			t.Errorf("parseSyslogTimestamp(%q) = %s is in the future", tt.line, got)
+		}
+	}
+}
+
+func TestParseSyslogTimestampMalformed(t *testing.T) {
+	lines := []string{
+		"",
+		"kernel: no timestamp here",
+		"Foo  5 02:47:49 host sshd[1]: x",
+		"Jan  5 02:47 host sshd[1]: x",
+		"Feb 30 10:00:00 host sshd[1]: x",
+		"2024-13-45T02:47:49Z host sshd[1]: x",
+		"2024-01-05T02:47:49+03 host sshd[1]: x",
+		"2024-01-05T02:47:49Z",
+	}
+	for _, line := range lines {
+		if got, ok := parseSyslogTimestamp(line); ok {
+			t.Errorf("parseSyslogTimestamp(%q) = %s, want failure", line, got)
+		}
+	}
+
+	// Строка без разбираемого времени получает время сбора
+	before := time.Now().Add(-time.Second)
+	event := NewSyslogCollector("/nonexistent").parseSyslogLine("Feb 30 10:00:00 host sshd[1]: x")
+	got, err := time.Parse(time.RFC3339, event.Timestamp)
+	if err != nil {
+		t.Fatalf("timestamp %q: %v", event.Timestamp, err)
+	}
+	if got.Before(before.Truncate(time.Second)) || got.After(time.Now().Add(time.Second)) {
+		t.Errorf("fallback timestamp = %s, want collection time", event.Timestamp)
+	}
+}
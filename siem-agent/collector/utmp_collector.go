@@ -0,0 +1,277 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// Типы записей utmp из <utmp.h>
+const (
+	utmpRunLevel     = 1
+	utmpBootTime     = 2
+	utmpLoginProcess = 6
+	utmpUserProcess  = 7
+	utmpDeadProcess  = 8
+)
+
+// utmpRecordSize размер struct utmp в Linux (glibc, x86_64 и большинство 64-битных платформ)
+const utmpRecordSize = 384
+
+// utmpPath текущие сеансы: из него восстанавливаются сеансы, открытые до
+// сохранённой позиции wtmp
+const utmpPath = "/var/run/utmp"
+
+// utmpRecord запись utmp/wtmp/btmp
+type utmpRecord struct {
+	recordType int16
+	pid        int32
+	line       string // терминал: pts/0, tty1, ssh:notty
+	user       string
+	host       string
+	addr       string
+	time       time.Time
+}
+
+// loginSession открытый сеанс, ожидающий записи о выходе
+type loginSession struct {
+	user  string
+	host  string
+	addr  string
+	start time.Time
+}
+
+// LoginRecordCollector читает двоичные журналы входов: wtmp (входы, выходы,
+// перезагрузки) или btmp (неудачные входы). Не зависит от auth.log, поэтому
+// сеансы видны, даже если текстовый журнал отсутствует или подчищен
+type LoginRecordCollector struct {
+	filePath string
+	failed   bool // btmp: каждая запись — неудачный вход
+	tailer   *fileTailer
+	sessions map[string]loginSession // терминал -> сеанс
+	seeded   bool
+}
+
+// NewWtmpCollector создаёт сборщик входов, выходов и перезагрузок из wtmp
+func NewWtmpCollector(filePath string) *LoginRecordCollector {
+	return newLoginRecordCollector(filePath, false)
+}
+
+// NewBtmpCollector создаёт сборщик неудачных входов из btmp
+func NewBtmpCollector(filePath string) *LoginRecordCollector {
+	return newLoginRecordCollector(filePath, true)
+}
+
+func newLoginRecordCollector(filePath string, failed bool) *LoginRecordCollector {
+	tailer := newFileTailer(filePath)
+	tailer.recordSize = utmpRecordSize
+	return &LoginRecordCollector{
+		filePath: filePath,
+		failed:   failed,
+		tailer:   tailer,
+		sessions: make(map[string]loginSession),
+	}
+}
+
+func (lc *LoginRecordCollector) Collect() ([]Event, error) {
+	if !lc.seeded {
+		lc.seeded = true
+		// Продолжаем с сохранённой позиции: входы до неё уже прочитаны,
+		// открытые сеансы берём из utmp
+		if !lc.failed && lc.tailer.offset > 0 {
+			lc.seedSessions()
+		}
+	}
+
+	var events []Event
+	err := lc.tailer.readLines(func(data string) {
+		record, ok := parseUtmpRecord([]byte(data))
+		if !ok {
+			return
+		}
+		if event, ok := lc.recordEvent(record); ok {
+			events = append(events, event)
+		}
+	})
+	if err != nil {
+		return events, fmt.Errorf("failed to read %s: %w", lc.filePath, err)
+	}
+
+	return events, nil
+}
+
+// Position возвращает текущую позицию чтения
+func (lc *LoginRecordCollector) Position() checkpoint.Position {
+	return lc.tailer.position()
+}
+
+// Restore восстанавливает позицию чтения
+func (lc *LoginRecordCollector) Restore(pos checkpoint.Position) {
+	lc.tailer.restore(pos)
+}
+
+// WatchPaths возвращает файл журнала
+func (lc *LoginRecordCollector) WatchPaths() []string {
+	return []string{lc.filePath}
+}
+
+func (lc *LoginRecordCollector) GetSourceName() string {
+	if lc.failed {
+		return "btmp"
+	}
+	return "wtmp"
+}
+
+func (lc *LoginRecordCollector) GetSourceType() string {
+	return "login_records"
+}
+
+// seedSessions восстанавливает открытые сеансы из utmp
+func (lc *LoginRecordCollector) seedSessions() {
+	data, err := os.ReadFile(utmpPath)
+	if err != nil {
+		return
+	}
+	for offset := 0; offset+utmpRecordSize <= len(data); offset += utmpRecordSize {
+		record, ok := parseUtmpRecord(data[offset : offset+utmpRecordSize])
+		if ok && record.recordType == utmpUserProcess {
+			lc.sessions[record.line] = loginSession{user: record.user, host: record.host, addr: record.addr, start: record.time}
+		}
+	}
+}
+
+// recordEvent создаёт событие по записи. false — запись служебная
+// (уровень выполнения, процессы init и getty)
+func (lc *LoginRecordCollector) recordEvent(record utmpRecord) (Event, bool) {
+	if lc.failed {
+		if record.recordType != utmpLoginProcess && record.recordType != utmpUserProcess {
+			return Event{}, false
+		}
+		event := lc.newEvent("failed_login", "WARNING", record)
+		addLoginRemote(event.Fields, record.host, record.addr)
+		event.RawLog = fmt.Sprintf("failed_login user=%s tty=%s host=%s time=%s", record.user, record.line, record.host, event.Timestamp)
+		return event, true
+	}
+
+	switch {
+	case record.recordType == utmpBootTime,
+		record.recordType == utmpRunLevel && record.user == "reboot":
+		// Сеансы, открытые до перезагрузки, уже не закроются
+		lc.sessions = make(map[string]loginSession)
+		event := lc.newEvent("reboot", "INFO", record)
+		event.User = ""
+		event.Fields["kernel"] = record.host
+		event.RawLog = fmt.Sprintf("reboot kernel=%s time=%s", record.host, event.Timestamp)
+		return event, true
+
+	case record.recordType == utmpUserProcess:
+		lc.sessions[record.line] = loginSession{user: record.user, host: record.host, addr: record.addr, start: record.time}
+		event := lc.newEvent("login", "INFO", record)
+		if record.user == "root" {
+			event.Severity = "WARNING"
+		}
+		addLoginRemote(event.Fields, record.host, record.addr)
+		event.Fields["login_time"] = event.Timestamp
+		event.RawLog = fmt.Sprintf("login user=%s tty=%s host=%s time=%s", record.user, record.line, record.host, event.Timestamp)
+		return event, true
+
+	case record.recordType == utmpDeadProcess && record.line != "":
+		session, open := lc.sessions[record.line]
+		if !open {
+			// Завершился getty или сеанс, начало которого не видели
+			if record.user == "" {
+				return Event{}, false
+			}
+			session = loginSession{user: record.user}
+		}
+		delete(lc.sessions, record.line)
+
+		event := lc.newEvent("logout", "INFO", record)
+		event.User = session.user
+		addLoginRemote(event.Fields, session.host, session.addr)
+		event.Fields["logout_time"] = event.Timestamp
+		if !session.start.IsZero() {
+			event.Fields["login_time"] = session.start.Format(time.RFC3339)
+			event.Fields["duration"] = strconv.FormatInt(int64(record.time.Sub(session.start).Seconds()), 10)
+		}
+		event.RawLog = fmt.Sprintf("logout user=%s tty=%s host=%s time=%s", session.user, record.line, session.host, event.Timestamp)
+		return event, true
+	}
+
+	return Event{}, false
+}
+
+// newEvent создаёт событие с общими полями записи
+func (lc *LoginRecordCollector) newEvent(eventType, severity string, record utmpRecord) Event {
+	return Event{
+		Timestamp: record.time.Format(time.RFC3339),
+		Hostname:  getHostname(),
+		Source:    lc.GetSourceName(),
+		EventType: eventType,
+		Severity:  severity,
+		User:      record.user,
+		Process:   "login",
+		Fields: map[string]string{
+			"tty": record.line,
+			"pid": strconv.Itoa(int(record.pid)),
+		},
+	}
+}
+
+// addLoginRemote добавляет удалённый хост и адрес входа
+func addLoginRemote(fields map[string]string, host, addr string) {
+	if host != "" {
+		fields["remote_host"] = host
+	}
+	if addr != "" {
+		fields["src_ip"] = addr
+	}
+}
+
+// parseUtmpRecord разбирает struct utmp:
+// ut_type(2+2) ut_pid(4) ut_line[32] ut_id[4] ut_user[32] ut_host[256]
+// ut_exit(4) ut_session(4) ut_tv(4+4) ut_addr_v6[16] unused[20]
+func parseUtmpRecord(data []byte) (utmpRecord, bool) {
+	if len(data) < utmpRecordSize {
+		return utmpRecord{}, false
+	}
+
+	le := binary.LittleEndian
+	record := utmpRecord{
+		recordType: int16(le.Uint16(data[0:2])),
+		pid:        int32(le.Uint32(data[4:8])),
+		line:       cString(data[8:40]),
+		user:       cString(data[44:76]),
+		host:       cString(data[76:332]),
+		time:       time.Unix(int64(int32(le.Uint32(data[340:344]))), int64(int32(le.Uint32(data[344:348])))*int64(time.Microsecond)),
+	}
+	if record.recordType <= 0 || record.recordType > 9 {
+		return utmpRecord{}, false
+	}
+
+	// IPv4 хранится в первом слове, остальные нулевые
+	addr := data[348:364]
+	switch {
+	case bytes.Equal(addr, make([]byte, net.IPv6len)):
+	case bytes.Equal(addr[4:], make([]byte, net.IPv6len-4)):
+		record.addr = net.IP(addr[:4]).String()
+	default:
+		record.addr = net.IP(addr).String()
+	}
+	return record, true
+}
+
+// cString возвращает строку до первого нулевого байта
+func cString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return strings.TrimSpace(string(data))
+}
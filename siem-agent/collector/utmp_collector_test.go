@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// utmpBytes кодирует запись в формате struct utmp
+func utmpBytes(recordType int16, pid int32, line, user, host string, when time.Time, addr net.IP) []byte {
+	data := make([]byte, utmpRecordSize)
+	le := binary.LittleEndian
+	le.PutUint16(data[0:2], uint16(recordType))
+	le.PutUint32(data[4:8], uint32(pid))
+	copy(data[8:40], line)
+	copy(data[44:76], user)
+	copy(data[76:332], host)
+	le.PutUint32(data[340:344], uint32(when.Unix()))
+	le.PutUint32(data[344:348], uint32(when.Nanosecond()/1000))
+	if ip4 := addr.To4(); ip4 != nil {
+		copy(data[348:352], ip4)
+	} else if addr != nil {
+		copy(data[348:364], addr.To16())
+	}
+	return data
+}
+
+func TestParseUtmpRecord(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 0, 0, 500000000, time.UTC)
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+		want utmpRecord
+	}{
+		{
+			name: "ipv4 login",
+			data: utmpBytes(utmpUserProcess, 1234, "pts/0", "alice", "203.0.113.7", when, net.ParseIP("203.0.113.7")),
+			ok:   true,
+			want: utmpRecord{recordType: utmpUserProcess, pid: 1234, line: "pts/0", user: "alice", host: "203.0.113.7", addr: "203.0.113.7", time: when},
+		},
+		{
+			name: "ipv6 login",
+			data: utmpBytes(utmpUserProcess, 1, "pts/1", "bob", "host.example", when, net.ParseIP("2001:db8::1")),
+			ok:   true,
+			want: utmpRecord{recordType: utmpUserProcess, pid: 1, line: "pts/1", user: "bob", host: "host.example", addr: "2001:db8::1", time: when},
+		},
+		{
+			name: "local login without address",
+			data: utmpBytes(utmpUserProcess, 2, "tty1", "root", "", when, nil),
+			ok:   true,
+			want: utmpRecord{recordType: utmpUserProcess, pid: 2, line: "tty1", user: "root", time: when},
+		},
+		{name: "empty record", data: make([]byte, utmpRecordSize)},
+		{name: "unknown type", data: utmpBytes(42, 1, "x", "x", "", when, nil)},
+		{name: "short", data: make([]byte, utmpRecordSize-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := parseUtmpRecord(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if !record.time.Equal(tt.want.time) {
+				t.Errorf("time = %v, want %v", record.time, tt.want.time)
+			}
+			record.time, tt.want.time = time.Time{}, time.Time{}
+			if record != tt.want {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+		})
+	}
+}
+
+func TestWtmpCollectorSessions(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "wtmp")
+
+	var data []byte
+	for _, record := range [][]byte{
+		utmpBytes(utmpBootTime, 0, "~", "reboot", "6.1.0-18-amd64", start, nil),
+		utmpBytes(utmpLoginProcess, 500, "tty1", "LOGIN", "", start.Add(time.Second), nil),
+		utmpBytes(utmpUserProcess, 600, "pts/0", "alice", "203.0.113.7", start.Add(time.Minute), net.ParseIP("203.0.113.7")),
+		utmpBytes(utmpDeadProcess, 500, "tty1", "", "", start.Add(2*time.Minute), nil), // getty
+		utmpBytes(utmpDeadProcess, 600, "pts/0", "", "", start.Add(11*time.Minute), nil),
+	} {
+		data = append(data, record...)
+	}
+	// Половина следующей записи: дописывается прямо сейчас
+	late := utmpBytes(utmpUserProcess, 700, "pts/1", "root", "", start.Add(time.Hour), nil)
+	data = append(data, late[:100]...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lc := NewWtmpCollector(path)
+	events, err := lc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var types []string
+	for _, event := range events {
+		types = append(types, event.EventType)
+	}
+	if len(events) != 3 || types[0] != "reboot" || types[1] != "login" || types[2] != "logout" {
+		t.Fatalf("events = %v, want [reboot login logout]", types)
+	}
+	if events[0].Fields["kernel"] != "6.1.0-18-amd64" {
+		t.Errorf("reboot kernel = %q", events[0].Fields["kernel"])
+	}
+	if login := events[1]; login.User != "alice" || login.Fields["src_ip"] != "203.0.113.7" || login.Fields["tty"] != "pts/0" {
+		t.Errorf("login = %+v", login)
+	}
+	logout := events[2]
+	if logout.User != "alice" || logout.Fields["duration"] != "600" || logout.Fields["remote_host"] != "203.0.113.7" {
+		t.Errorf("logout = %+v", logout)
+	}
+	if pos := lc.Position(); pos.Offset != 5*utmpRecordSize {
+		t.Errorf("position = %d, want end of last whole record", pos.Offset)
+	}
+
+	appendFile(t, path, string(late[100:]))
+	events, err = lc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != "login" || events[0].Severity != "WARNING" {
+		t.Fatalf("completed record = %+v, want root login", events)
+	}
+}
+
+func TestBtmpCollectorFailedLogins(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "btmp")
+	data := append(
+		utmpBytes(utmpLoginProcess, 800, "ssh:notty", "admin", "198.51.100.9", when, net.ParseIP("198.51.100.9")),
+		utmpBytes(utmpRunLevel, 0, "~", "runlevel", "", when, nil)...,
+	)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lc := NewBtmpCollector(path)
+	events, err := lc.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Source != "btmp" || event.EventType != "failed_login" || event.User != "admin" || event.Fields["src_ip"] != "198.51.100.9" {
+		t.Errorf("event = %+v", event)
+	}
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/checkpoint"
+)
+
+// zshExtendedPattern запись расширенной истории (setopt EXTENDED_HISTORY):
+// ": <начало>:<длительность>;команда"
+var zshExtendedPattern = regexp.MustCompile(`^: (\d+):(\d+);(.*)$`)
+
+// zshMeta байт, которым zsh экранирует служебные байты в файле истории
+const zshMeta = 0x83
+
+// zshCommand команда, продолжение которой ещё не дописано
+type zshCommand struct {
+	lines []string
+	start int64 // смещение первой строки команды
+	inode uint64
+}
+
+// ZshCollector читает историю zsh. Многострочные команды zsh записывает
+// с обратной косой чертой в конце каждой строки, кроме последней
+type ZshCollector struct {
+	user    string
+	tailer  *fileTailer
+	pending *zshCommand
+	seen    historySeen
+}
+
+// NewZshCollector создаёт сборщик истории zsh пользователя
+func NewZshCollector(user, historyPath string) *ZshCollector {
+	return &ZshCollector{
+		user:   user,
+		tailer: newFileTailer(historyPath),
+	}
+}
+
+func (zc *ZshCollector) Collect() ([]Event, error) {
+	var events []Event
+
+	err := readHistory(zc.tailer, func(line string) {
+		if zc.pending == nil {
+			if strings.TrimSpace(line) == "" {
+				return
+			}
+			zc.pending = &zshCommand{start: zc.tailer.offset, inode: zc.tailer.inode}
+		}
+		zc.pending.lines = append(zc.pending.lines, line)
+
+		if strings.HasSuffix(line, `\`) {
+			return
+		}
+		if event, ok := zc.parseCommand(zc.pending.lines); ok {
+			events = append(events, event)
+		}
+		zc.pending = nil
+	})
+	if err != nil {
+		return events, fmt.Errorf("failed to read zsh history file: %w", err)
+	}
+
+	return events, nil
+}
+
+// Position возвращает текущую позицию чтения и недавно выданные команды.
+// Недописанная многострочная команда после перезапуска будет прочитана заново
+func (zc *ZshCollector) Position() checkpoint.Position {
+	pos := zc.tailer.position()
+	if zc.pending != nil && zc.pending.inode == pos.Inode {
+		pos.Offset = zc.pending.start
+	}
+	pos.Seen = zc.seen.encode()
+	return pos
+}
+
+// Restore восстанавливает сохранённую позицию чтения и уже выданные команды
+func (zc *ZshCollector) Restore(pos checkpoint.Position) {
+	zc.tailer.restore(pos)
+	zc.seen.decode(pos.Seen)
+}
+
+// WatchPaths возвращает файл, за которым нужно следить
+func (zc *ZshCollector) WatchPaths() []string {
+	return []string{zc.tailer.path}
+}
+
+func (zc *ZshCollector) GetSourceName() string {
+	return "zsh_history_" + zc.user
+}
+
+func (zc *ZshCollector) GetSourceType() string {
+	return "zsh_history"
+}
+
+// parseCommand собирает команду из строк истории. Возвращает false для уже
+// выданной команды
+func (zc *ZshCollector) parseCommand(lines []string) (Event, bool) {
+	raw := unmetafyZsh(strings.Join(lines, "\n"))
+	command := raw
+
+	var when time.Time
+	var duration string
+	if m := zshExtendedPattern.FindStringSubmatch(strings.SplitN(raw, "\n", 2)[0]); m != nil {
+		seconds, _ := strconv.ParseInt(m[1], 10, 64)
+		when = time.Unix(seconds, 0)
+		duration = m[2]
+		// Команда — всё после ";", включая следующие строки
+		command = raw[len(m[0])-len(m[3]):]
+	}
+	// Убираем экранирование переводов строк
+	command = strings.ReplaceAll(command, "\\\n", "\n")
+
+	if !when.IsZero() && !zc.seen.isNew(when, command) {
+		return Event{}, false
+	}
+
+	event := shellCommandEvent("zsh_history", "zsh", zc.user, command, when)
+	event.RawLog = raw
+	if duration != "" {
+		event.Fields = map[string]string{"duration": duration}
+	}
+	return event, true
+}
+
+// unmetafyZsh восстанавливает байты, экранированные zsh: за Meta (0x83)
+// следует исходный байт, инвертированный по 0x20
+func unmetafyZsh(value string) string {
+	if strings.IndexByte(value, zshMeta) < 0 {
+		return value
+	}
+
+	decoded := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == zshMeta && i+1 < len(value) {
+			i++
+			decoded = append(decoded, value[i]^0x20)
+			continue
+		}
+		decoded = append(decoded, value[i])
+	}
+	return string(decoded)
+}
@@ -0,0 +1,490 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Значения по умолчанию
+const (
+	DefaultServerHost         = "127.0.0.1"
+	DefaultServerPort         = 8080
+	DefaultCollectionInterval = 5000
+	DefaultSendInterval       = 10000
+	DefaultBatchSize          = 100
+	DefaultBufferMaxSize      = 10000
+	DefaultStateDir           = "/var/lib/siem-agent"
+)
+
+// sourceKind описание известного типа источника
+type sourceKind struct {
+	defaultPath  string
+	requiresPath bool
+	perUser      bool     // поддерживает all_users
+	listens      bool     // принимает события по сети (listen)
+	defaultPaths []string // маски paths по умолчанию; nil — paths не поддерживается
+}
+
+// sourceKinds известные источники и их пути по умолчанию
+var sourceKinds = map[string]sourceKind{
+	"syslog":       {defaultPath: "/var/log/syslog", requiresPath: true},
+	"auditd":       {defaultPath: "/var/log/audit/audit.log", requiresPath: true},
+	"bash_history": {defaultPath: "~/.bash_history", requiresPath: true, perUser: true},
+	"zsh_history":  {defaultPath: "~/.zsh_history", requiresPath: true, perUser: true},
+	"fish_history": {defaultPath: "~/.local/share/fish/fish_history", requiresPath: true, perUser: true},
+	// path для journald — каталог журнала, по умолчанию системный журнал
+	"journald": {},
+	// без path используется /var/log/auth.log или /var/log/secure
+	"authlog": {},
+	// приём syslog по сети, адреса задаются в listen
+	"syslog_listener": {listens: true},
+	// контроль целостности файлов, маски задаются в paths
+	"fim": {defaultPaths: DefaultFIMPaths},
+	// процессы из /proc, path — корень procfs
+	"process": {defaultPath: "/proc", requiresPath: true},
+	// слушающие порты и исходящие соединения из /proc/net
+	"network": {defaultPath: "/proc", requiresPath: true},
+	// двоичные журналы входов и выходов (wtmp) и неудачных входов (btmp)
+	"wtmp": {defaultPath: "/var/log/wtmp", requiresPath: true},
+	"btmp": {defaultPath: "/var/log/btmp", requiresPath: true},
+	// журналы контейнеров драйвера json-file, path — каталог контейнеров
+	"docker": {defaultPath: "/var/lib/docker/containers", requiresPath: true},
+	// сообщения ядра напрямую из кольцевого буфера
+	"kmsg": {defaultPath: "/dev/kmsg", requiresPath: true},
+}
+
+// DefaultFIMPaths файлы, за целостностью которых следим по умолчанию
+var DefaultFIMPaths = []string{
+	"/etc/passwd",
+	"/etc/shadow",
+	"/etc/group",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d/*",
+	"/etc/ssh/sshd_config",
+	"/etc/ssh/sshd_config.d/*",
+	"/etc/crontab",
+	"/etc/cron.d/*",
+	"/var/spool/cron/crontabs/*",
+	"/etc/systemd/system/*",
+	"/etc/systemd/system/*/*",
+}
+
+// DefaultSyslogListen адрес приёма syslog по умолчанию
+const DefaultSyslogListen = "udp://0.0.0.0:514"
+
+// Config конфигурация агента из YAML файла
+type Config struct {
+	Agent     AgentConfig     `yaml:"agent"`
+	Server    ServerConfig    `yaml:"server"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Processor ProcessorConfig `yaml:"processor"`
+}
+
+// AgentConfig параметры агента
+type AgentConfig struct {
+	ID       string `yaml:"id"`
+	Debug    bool   `yaml:"debug"`
+	StateDir string `yaml:"state_dir"` // каталог для позиций чтения источников
+
+	// Timezone часовой пояс (IANA, например Europe/Moscow) для времени в записях
+	// без указания зоны. Пустое значение — локальный пояс системы
+	Timezone string `yaml:"timezone"`
+}
+
+// Location возвращает часовой пояс для времени без указания зоны
+func (ac AgentConfig) Location() (*time.Location, error) {
+	if ac.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(ac.Timezone)
+}
+
+// ProcessorConfig параметры обработки событий
+type ProcessorConfig struct {
+	// RulesDir каталог с файлами правил и фильтров (*.yaml). Пустое значение —
+	// встроенные правила
+	RulesDir string `yaml:"rules_dir"`
+}
+
+// ServerConfig адрес SIEM сервера
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+// LoggingConfig параметры сбора и отправки логов
+type LoggingConfig struct {
+	CollectionInterval int      `yaml:"collection_interval"` // миллисекунды
+	SendInterval       int      `yaml:"send_interval"`       // миллисекунды
+	BatchSize          int      `yaml:"batch_size"`
+	BufferMaxSize      int      `yaml:"buffer_max_size"`
+	Sources            []Source `yaml:"sources"`
+}
+
+// Режимы сбора источника
+const (
+	ModePoll  = "poll"  // опрос с интервалом collection_interval
+	ModeWatch = "watch" // чтение по уведомлениям fsnotify
+)
+
+// SourceTypeFile тип источника: произвольные файлы по маскам с настраиваемым разбором
+const SourceTypeFile = "file"
+
+// Форматы записей для источников типа file
+const (
+	FormatPlain = "plain" // строка целиком
+	FormatRegex = "regex" // регулярное выражение с именованными группами
+	FormatJSON  = "json"  // JSON-объект в каждой строке
+	FormatKV    = "kv"    // пары key=value
+	FormatCEF   = "cef"   // ArcSight Common Event Format
+	FormatLEEF  = "leef"  // IBM Log Event Extended Format
+)
+
+// eventFields поля события, на которые можно отобразить разобранные поля
+var eventFields = map[string]bool{
+	"timestamp": true, "hostname": true, "event_type": true, "severity": true,
+	"user": true, "process": true, "command": true,
+}
+
+// Source описание источника логов
+type Source struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	Mode string `yaml:"mode"`
+
+	// AllUsers собирать историю всех пользователей из /etc/passwd, path при этом не используется
+	AllUsers bool `yaml:"all_users"`
+
+	// Type пустой для встроенных источников (тип определяется по name) или "file"
+	Type string `yaml:"type"`
+	// Paths маски файлов источника типа file, например /var/log/nginx/*.log
+	Paths []string `yaml:"paths"`
+	// Parser формат записей источника типа file
+	Parser ParserConfig `yaml:"parser"`
+	// Mapping поле события -> разобранное поле записи (user: remote_user)
+	Mapping map[string]string `yaml:"mapping"`
+
+	// Listen адреса приёма для сетевых источников: "udp://0.0.0.0:514", "tcp://:1514"
+	Listen []string `yaml:"listen"`
+}
+
+// ParserConfig разбор записей источника типа file
+type ParserConfig struct {
+	Format string `yaml:"format"`
+	// Pattern регулярное выражение с именованными группами для формата regex
+	Pattern string `yaml:"pattern"`
+	// TimestampLayout формат времени в нотации Go; без него пробуются RFC3339 и epoch
+	TimestampLayout string `yaml:"timestamp_layout"`
+}
+
+// FieldError ошибка в конкретном поле конфигурации
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// ValidationError набор ошибок валидации
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	messages := make([]string, len(ve.Errors))
+	for i, fe := range ve.Errors {
+		messages[i] = fe.Error()
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+func (ve *ValidationError) add(field, format string, args ...interface{}) {
+	ve.Errors = append(ve.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Load читает конфигурацию из файла, применяет значения по умолчанию и проверяет её
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config, err := Parse(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse разбирает YAML, применяет значения по умолчанию и проверяет результат
+func Parse(data []byte) (Config, error) {
+	var config Config
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	config.ApplyDefaults()
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// ApplyDefaults заполняет незаданные поля значениями по умолчанию
+func (c *Config) ApplyDefaults() {
+	if c.Agent.ID == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			c.Agent.ID = "agent-" + hostname
+		}
+	}
+
+	if c.Agent.StateDir == "" {
+		c.Agent.StateDir = DefaultStateDir
+	}
+	c.Agent.StateDir = expandHome(c.Agent.StateDir)
+	c.Processor.RulesDir = expandHome(c.Processor.RulesDir)
+
+	if c.Server.Host == "" {
+		c.Server.Host = DefaultServerHost
+	}
+	if c.Server.Port == 0 {
+		c.Server.Port = DefaultServerPort
+	}
+
+	if c.Logging.CollectionInterval == 0 {
+		c.Logging.CollectionInterval = DefaultCollectionInterval
+	}
+	if c.Logging.SendInterval == 0 {
+		c.Logging.SendInterval = DefaultSendInterval
+	}
+	if c.Logging.BatchSize == 0 {
+		c.Logging.BatchSize = DefaultBatchSize
+	}
+	if c.Logging.BufferMaxSize == 0 {
+		c.Logging.BufferMaxSize = DefaultBufferMaxSize
+	}
+
+	for i := range c.Logging.Sources {
+		source := &c.Logging.Sources[i]
+		if len(source.Paths) == 0 && source.Type == "" && sourceKinds[source.Name].defaultPaths != nil {
+			source.Paths = append([]string(nil), sourceKinds[source.Name].defaultPaths...)
+		}
+		for j, pattern := range source.Paths {
+			source.Paths[j] = expandHome(pattern)
+		}
+		if source.Type == SourceTypeFile && source.Parser.Format == "" {
+			source.Parser.Format = FormatPlain
+		}
+		if len(source.Listen) == 0 && sourceKinds[source.Name].listens && source.Type == "" {
+			source.Listen = []string{DefaultSyslogListen}
+		}
+		if source.Path == "" && source.Type == "" {
+			source.Path = sourceKinds[source.Name].defaultPath
+		}
+		source.Path = expandHome(source.Path)
+		if source.Mode == "" {
+			source.Mode = ModePoll
+		}
+	}
+}
+
+// Validate проверяет корректность значений
+func (c *Config) Validate() error {
+	ve := &ValidationError{}
+
+	if c.Agent.ID == "" {
+		ve.add("agent.id", "must not be empty")
+	}
+	if _, err := c.Agent.Location(); err != nil {
+		ve.add("agent.timezone", "unknown timezone %q", c.Agent.Timezone)
+	}
+
+	if c.Server.Host == "" {
+		ve.add("server.host", "must not be empty")
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		ve.add("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+
+	if c.Logging.CollectionInterval <= 0 {
+		ve.add("logging.collection_interval", "must be positive, got %d", c.Logging.CollectionInterval)
+	}
+	if c.Logging.SendInterval <= 0 {
+		ve.add("logging.send_interval", "must be positive, got %d", c.Logging.SendInterval)
+	}
+	if c.Logging.BatchSize <= 0 {
+		ve.add("logging.batch_size", "must be positive, got %d", c.Logging.BatchSize)
+	}
+	if c.Logging.BufferMaxSize <= 0 {
+		ve.add("logging.buffer_max_size", "must be positive, got %d", c.Logging.BufferMaxSize)
+	} else if c.Logging.BatchSize > c.Logging.BufferMaxSize {
+		ve.add("logging.batch_size", "must not exceed buffer_max_size (%d), got %d", c.Logging.BufferMaxSize, c.Logging.BatchSize)
+	}
+
+	seen := make(map[string]bool)
+	for i, source := range c.Logging.Sources {
+		field := fmt.Sprintf("logging.sources[%d]", i)
+
+		if source.Name == "" {
+			ve.add(field+".name", "must not be empty")
+			continue
+		}
+		if seen[source.Name] {
+			ve.add(field+".name", "duplicate source %q", source.Name)
+		}
+		seen[source.Name] = true
+
+		if source.Mode != ModePoll && source.Mode != ModeWatch {
+			ve.add(field+".mode", "must be %q or %q, got %q", ModePoll, ModeWatch, source.Mode)
+		}
+
+		switch source.Type {
+		case "":
+		case SourceTypeFile:
+			validateFileSource(ve, field, source)
+			continue
+		default:
+			ve.add(field+".type", "unknown source type %q", source.Type)
+			continue
+		}
+
+		kind, known := sourceKinds[source.Name]
+		if !known {
+			ve.add(field+".name", "unknown source %q", source.Name)
+		}
+
+		if known && kind.requiresPath && source.Path == "" {
+			ve.add(field+".path", "must not be empty")
+		}
+		if known && source.AllUsers && !kind.perUser {
+			ve.add(field+".all_users", "not supported by source %q", source.Name)
+		}
+		if known && kind.defaultPaths == nil && len(source.Paths) > 0 {
+			ve.add(field+".paths", "not supported by source %q, use path", source.Name)
+		}
+		validateGlobs(ve, field, source.Paths)
+		if known && !kind.listens && len(source.Listen) > 0 {
+			ve.add(field+".listen", "not supported by source %q", source.Name)
+		}
+		for j, address := range source.Listen {
+			if err := validateListenAddress(address); err != nil {
+				ve.add(fmt.Sprintf("%s.listen[%d]", field, j), "%v", err)
+			}
+		}
+	}
+
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// validateFileSource проверяет источник типа file
+func validateFileSource(ve *ValidationError, field string, source Source) {
+	if len(source.Paths) == 0 {
+		ve.add(field+".paths", "must not be empty")
+	}
+	validateGlobs(ve, field, source.Paths)
+	if source.AllUsers {
+		ve.add(field+".all_users", "not supported by file sources")
+	}
+
+	switch source.Parser.Format {
+	case FormatPlain, FormatJSON, FormatKV, FormatCEF, FormatLEEF:
+		if source.Parser.Pattern != "" {
+			ve.add(field+".parser.pattern", "only used with format %q", FormatRegex)
+		}
+	case FormatRegex:
+		re, err := regexp.Compile(source.Parser.Pattern)
+		switch {
+		case source.Parser.Pattern == "":
+			ve.add(field+".parser.pattern", "must not be empty")
+		case err != nil:
+			ve.add(field+".parser.pattern", "invalid regexp: %v", err)
+		case !hasNamedGroup(re):
+			ve.add(field+".parser.pattern", "must contain named groups (?P<name>...)")
+		}
+	default:
+		ve.add(field+".parser.format", "unknown format %q", source.Parser.Format)
+	}
+
+	for target := range source.Mapping {
+		if !eventFields[target] {
+			ve.add(field+".mapping."+target, "unknown event field %q", target)
+		}
+	}
+}
+
+// validateGlobs проверяет синтаксис масок paths
+func validateGlobs(ve *ValidationError, field string, patterns []string) {
+	for j, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			ve.add(fmt.Sprintf("%s.paths[%d]", field, j), "invalid glob %q: %v", pattern, err)
+		}
+	}
+}
+
+// validateListenAddress проверяет адрес вида "udp://host:port" или "tcp://host:port"
+func validateListenAddress(address string) error {
+	network, hostPort, ok := strings.Cut(address, "://")
+	if !ok {
+		return fmt.Errorf("address %q must be udp://host:port or tcp://host:port", address)
+	}
+	if network != "udp" && network != "tcp" {
+		return fmt.Errorf("unsupported network %q in %q", network, address)
+	}
+	_, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port in %q", address)
+	}
+	return nil
+}
+
+// hasNamedGroup проверяет, что в выражении есть хотя бы одна именованная группа
+func hasNamedGroup(re *regexp.Regexp) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome раскрывает ~ в начале пути в домашний каталог пользователя
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			homeDir = dir
+		}
+	}
+	if homeDir == "" {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAppliesDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	config, err := Parse([]byte(`
+agent:
+  id: web-01
+logging:
+  sources:
+    - name: syslog
+    - name: bash_history
+      mode: watch
+    - name: fim
+    - name: syslog_listener
+    - name: nginx
+      type: file
+      paths: [/var/log/nginx/*.log]
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if config.Agent.ID != "web-01" || config.Agent.StateDir != DefaultStateDir {
+		t.Errorf("agent = %+v", config.Agent)
+	}
+	if config.Server != (ServerConfig{Host: DefaultServerHost, Port: DefaultServerPort}) {
+		t.Errorf("server = %+v", config.Server)
+	}
+	logging := config.Logging
+	if logging.CollectionInterval != DefaultCollectionInterval || logging.SendInterval != DefaultSendInterval ||
+		logging.BatchSize != DefaultBatchSize || logging.BufferMaxSize != DefaultBufferMaxSize {
+		t.Errorf("logging = %+v", logging)
+	}
+
+	sources := logging.Sources
+	if sources[0].Path != "/var/log/syslog" || sources[0].Mode != ModePoll {
+		t.Errorf("syslog = %+v", sources[0])
+	}
+	if want := filepath.Join(home, ".bash_history"); sources[1].Path != want || sources[1].Mode != ModeWatch {
+		t.Errorf("bash_history = %+v, want path %s", sources[1], want)
+	}
+	if !reflect.DeepEqual(sources[2].Paths, DefaultFIMPaths) {
+		t.Errorf("fim paths = %v", sources[2].Paths)
+	}
+	if !reflect.DeepEqual(sources[3].Listen, []string{DefaultSyslogListen}) {
+		t.Errorf("syslog_listener listen = %v", sources[3].Listen)
+	}
+	if sources[4].Path != "" || sources[4].Parser.Format != FormatPlain {
+		t.Errorf("file source = %+v", sources[4])
+	}
+}
+
+func TestParseDefaultAgentID(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	config, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if config.Agent.ID != "agent-"+hostname {
+		t.Errorf("agent.id = %q, want agent-%s", config.Agent.ID, hostname)
+	}
+}
+
+func TestParseValidationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		field   string
+		message string
+	}{
+		{"port out of range", "server: {port: 70000}", "server.port", "between 1 and 65535"},
+		{"negative interval", "logging: {collection_interval: -1}", "logging.collection_interval", "must be positive"},
+		{"negative send interval", "logging: {send_interval: -5}", "logging.send_interval", "must be positive"},
+		{"negative batch", "logging: {batch_size: -1}", "logging.batch_size", "must be positive"},
+		{"negative buffer", "logging: {buffer_max_size: -1}", "logging.buffer_max_size", "must be positive"},
+		{"batch exceeds buffer", "logging: {batch_size: 200, buffer_max_size: 100}", "logging.batch_size", "must not exceed buffer_max_size"},
+		{"unknown timezone", "agent: {timezone: Mars/Olympus}", "agent.timezone", "unknown timezone"},
+		{"empty source name", "logging: {sources: [{path: /var/log/x}]}", "logging.sources[0].name", "must not be empty"},
+		{"duplicate source", "logging: {sources: [{name: syslog}, {name: syslog, path: /var/log/messages}]}", "logging.sources[1].name", `duplicate source "syslog"`},
+		{"unknown source", "logging: {sources: [{name: nginx}]}", "logging.sources[0].name", `unknown source "nginx"`},
+		{"unknown mode", "logging: {sources: [{name: syslog, mode: push}]}", "logging.sources[0].mode", `got "push"`},
+		{"unknown type", "logging: {sources: [{name: x, type: socket}]}", "logging.sources[0].type", `unknown source type "socket"`},
+		{"file without paths", "logging: {sources: [{name: app, type: file}]}", "logging.sources[0].paths", "must not be empty"},
+		{"invalid glob", "logging: {sources: [{name: app, type: file, paths: ['/var/log/[']}]}", "logging.sources[0].paths[0]", "invalid glob"},
+		{"unknown format", "logging: {sources: [{name: app, type: file, paths: [/a], parser: {format: xml}}]}", "logging.sources[0].parser.format", `unknown format "xml"`},
+		{"regex without groups", "logging: {sources: [{name: app, type: file, paths: [/a], parser: {format: regex, pattern: '.*'}}]}", "logging.sources[0].parser.pattern", "named groups"},
+		{"pattern without regex", "logging: {sources: [{name: app, type: file, paths: [/a], parser: {pattern: '(?P<a>.*)'}}]}", "logging.sources[0].parser.pattern", "only used with format"},
+		{"unknown mapping", "logging: {sources: [{name: app, type: file, paths: [/a], mapping: {src: ip}}]}", "logging.sources[0].mapping.src", "unknown event field"},
+		{"all_users unsupported", "logging: {sources: [{name: syslog, all_users: true}]}", "logging.sources[0].all_users", "not supported"},
+		{"paths unsupported", "logging: {sources: [{name: syslog, paths: [/a]}]}", "logging.sources[0].paths", "use path"},
+		{"listen unsupported", "logging: {sources: [{name: syslog, listen: ['udp://:514']}]}", "logging.sources[0].listen", "not supported"},
+		{"bad listen network", "logging: {sources: [{name: syslog_listener, listen: ['sctp://:514']}]}", "logging.sources[0].listen[0]", "unsupported network"},
+		{"bad listen port", "logging: {sources: [{name: syslog_listener, listen: ['udp://:0']}]}", "logging.sources[0].listen[0]", "invalid port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("error = %v, want ValidationError", err)
+			}
+			for _, fe := range ve.Errors {
+				if fe.Field == tt.field && strings.Contains(fe.Message, tt.message) {
+					return
+				}
+			}
+			t.Errorf("errors = %v, want %s: ...%s...", ve.Errors, tt.field, tt.message)
+		})
+	}
+}
+
+func TestParseReportsAllErrors(t *testing.T) {
+	_, err := Parse([]byte("server: {port: -1}\nlogging: {batch_size: -1, sources: [{name: nginx}]}"))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Errors) != 3 {
+		t.Fatalf("error = %v, want 3 field errors", err)
+	}
+}
+
+func TestParseRejectsUnknownKeys(t *testing.T) {
+	if _, err := Parse([]byte("logging: {colection_interval: 100}")); err == nil || !strings.Contains(err.Error(), "colection_interval") {
+		t.Fatalf("error = %v, want unknown field", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	// Файл из репозитория должен проходить проверку
+	if _, err := Load(filepath.Join("..", "config.yaml")); err != nil {
+		t.Errorf("Load(config.yaml): %v", err)
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error = %v, want not exist", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("server: {port: 0x}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("error = %v, want mention of %s", err, path)
+	}
+}
@@ -0,0 +1,179 @@
+package identity
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Пути к базам пользователей и групп по умолчанию
+const (
+	DefaultPasswdPath = "/etc/passwd"
+	DefaultGroupPath  = "/etc/group"
+)
+
+// refreshInterval как часто проверяем, не изменились ли /etc/passwd и /etc/group
+const refreshInterval = 30 * time.Second
+
+// User запись /etc/passwd
+type User struct {
+	Name  string
+	UID   string
+	GID   string
+	Home  string
+	Shell string
+}
+
+// Resolver переводит числовые UID и GID в имена. Файлы перечитываются,
+// если изменилось время их модификации
+type Resolver struct {
+	passwdPath string
+	groupPath  string
+
+	mu          sync.RWMutex
+	users       map[string]User   // uid -> пользователь
+	groups      map[string]string // gid -> имя группы
+	passwdMtime time.Time
+	groupMtime  time.Time
+	checkedAt   time.Time
+}
+
+// NewResolver создаёт резолвер для указанных файлов passwd и group
+func NewResolver(passwdPath, groupPath string) *Resolver {
+	r := &Resolver{
+		passwdPath: passwdPath,
+		groupPath:  groupPath,
+		users:      make(map[string]User),
+		groups:     make(map[string]string),
+	}
+	r.refresh(true)
+	return r
+}
+
+// NewSystemResolver создаёт резолвер для /etc/passwd и /etc/group
+func NewSystemResolver() *Resolver {
+	return NewResolver(DefaultPasswdPath, DefaultGroupPath)
+}
+
+// UserName возвращает имя пользователя по UID
+func (r *Resolver) UserName(uid string) (string, bool) {
+	r.refresh(false)
+
+	r.mu.RLock()
+	user, ok := r.users[uid]
+	r.mu.RUnlock()
+	return user.Name, ok
+}
+
+// GroupName возвращает имя группы по GID
+func (r *Resolver) GroupName(gid string) (string, bool) {
+	r.refresh(false)
+
+	r.mu.RLock()
+	name, ok := r.groups[gid]
+	r.mu.RUnlock()
+	return name, ok
+}
+
+// Users возвращает всех пользователей из passwd
+func (r *Resolver) Users() []User {
+	r.refresh(false)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	return users
+}
+
+// refresh перечитывает файлы, если они изменились. Без force проверка
+// выполняется не чаще refreshInterval
+func (r *Resolver) refresh(force bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !force && time.Since(r.checkedAt) < refreshInterval {
+		return
+	}
+	r.checkedAt = time.Now()
+
+	if mtime, ok := modTime(r.passwdPath); ok && (force || !mtime.Equal(r.passwdMtime)) {
+		if users, err := readPasswd(r.passwdPath); err == nil {
+			r.users = users
+			r.passwdMtime = mtime
+		}
+	}
+	if mtime, ok := modTime(r.groupPath); ok && (force || !mtime.Equal(r.groupMtime)) {
+		if groups, err := readGroup(r.groupPath); err == nil {
+			r.groups = groups
+			r.groupMtime = mtime
+		}
+	}
+}
+
+// readPasswd читает /etc/passwd: name:password:uid:gid:gecos:home:shell
+func readPasswd(path string) (map[string]User, error) {
+	users := make(map[string]User)
+	err := readColonFile(path, 7, func(fields []string) {
+		// При совпадении UID берём первую запись, как getpwuid
+		if _, exists := users[fields[2]]; exists {
+			return
+		}
+		users[fields[2]] = User{
+			Name:  fields[0],
+			UID:   fields[2],
+			GID:   fields[3],
+			Home:  fields[5],
+			Shell: fields[6],
+		}
+	})
+	return users, err
+}
+
+// readGroup читает /etc/group: name:password:gid:members
+func readGroup(path string) (map[string]string, error) {
+	groups := make(map[string]string)
+	err := readColonFile(path, 3, func(fields []string) {
+		if _, exists := groups[fields[2]]; !exists {
+			groups[fields[2]] = fields[0]
+		}
+	})
+	return groups, err
+}
+
+// readColonFile построчно читает файл с полями через двоеточие
+func readColonFile(path string, minFields int, handle func(fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < minFields {
+			continue
+		}
+		handle(fields)
+	}
+	return scanner.Err()
+}
+
+// modTime возвращает время модификации файла
+func modTime(path string) (time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
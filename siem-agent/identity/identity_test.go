@@ -0,0 +1,124 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeFiles создаёт passwd и group во временном каталоге
+func writeFiles(t *testing.T, passwd, group string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	passwdPath := filepath.Join(dir, "passwd")
+	groupPath := filepath.Join(dir, "group")
+	if err := os.WriteFile(passwdPath, []byte(passwd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(groupPath, []byte(group), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return passwdPath, groupPath
+}
+
+func TestResolverNames(t *testing.T) {
+	passwdPath, groupPath := writeFiles(t, `# comment
+root:x:0:0:root:/root:/bin/bash
+
+alice:x:1000:1000:Alice:/home/alice:/bin/zsh
+toor:x:0:0:duplicate root:/root:/bin/sh
+broken:x:1001
+`, `root:x:0:
+wheel:x:10:alice
+alice:x:1000:
+staff:x:10:
+`)
+	r := NewResolver(passwdPath, groupPath)
+
+	tests := []struct {
+		uid  string
+		want string
+		ok   bool
+	}{
+		{"0", "root", true}, // первая запись с UID 0, как getpwuid
+		{"1000", "alice", true},
+		{"1001", "", false}, // строка с недостающими полями пропущена
+		{"4242", "", false},
+	}
+	for _, tt := range tests {
+		if name, ok := r.UserName(tt.uid); name != tt.want || ok != tt.ok {
+			t.Errorf("UserName(%s) = %q, %v; want %q, %v", tt.uid, name, ok, tt.want, tt.ok)
+		}
+	}
+
+	if name, ok := r.GroupName("10"); name != "wheel" || !ok {
+		t.Errorf("GroupName(10) = %q, %v; want wheel", name, ok)
+	}
+	if _, ok := r.GroupName("4242"); ok {
+		t.Error("GroupName(4242) resolved")
+	}
+
+	users := r.Users()
+	sort.Slice(users, func(i, j int) bool { return users[i].UID < users[j].UID })
+	want := []User{
+		{Name: "root", UID: "0", GID: "0", Home: "/root", Shell: "/bin/bash"},
+		{Name: "alice", UID: "1000", GID: "1000", Home: "/home/alice", Shell: "/bin/zsh"},
+	}
+	if len(users) != len(want) || users[0] != want[0] || users[1] != want[1] {
+		t.Errorf("Users = %+v, want %+v", users, want)
+	}
+}
+
+func TestResolverRefresh(t *testing.T) {
+	passwdPath, groupPath := writeFiles(t, "alice:x:1000:1000::/home/alice:/bin/sh\n", "alice:x:1000:\n")
+	r := NewResolver(passwdPath, groupPath)
+
+	// Файл изменился, но интервал проверки не истёк
+	if err := os.WriteFile(passwdPath, []byte("bob:x:1000:1000::/home/bob:/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(passwdPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := r.UserName("1000"); name != "alice" {
+		t.Fatalf("UserName(1000) = %q before refresh interval, want alice", name)
+	}
+
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+	if name, _ := r.UserName("1000"); name != "bob" {
+		t.Errorf("UserName(1000) = %q after passwd change, want bob", name)
+	}
+	if name, _ := r.GroupName("1000"); name != "alice" {
+		t.Errorf("GroupName(1000) = %q, want unchanged alice", name)
+	}
+
+	// Пропавший файл не сбрасывает уже прочитанные имена
+	if err := os.Remove(passwdPath); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+	if name, _ := r.UserName("1000"); name != "bob" {
+		t.Errorf("UserName(1000) = %q after passwd removal, want bob", name)
+	}
+}
+
+func TestResolverMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	r := NewResolver(filepath.Join(dir, "passwd"), filepath.Join(dir, "group"))
+	if _, ok := r.UserName("0"); ok {
+		t.Error("UserName resolved without passwd")
+	}
+	if _, ok := r.GroupName("0"); ok {
+		t.Error("GroupName resolved without group")
+	}
+	if users := r.Users(); len(users) != 0 {
+		t.Errorf("Users = %v, want none", users)
+	}
+}
@@ -2,43 +2,33 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"syscall"
 	"time"
 
 	"agent/agent"
 	"agent/buffer"
+	"agent/checkpoint"
 	"agent/collector"
+	"agent/config"
+	"agent/identity"
 	"agent/processor"
 	"agent/sender"
 )
 
-type Config struct {
-	Agent struct {
-		ID string `yaml:"id"`
-	} `yaml:"agent"`
-	Server struct {
-		Host string `yaml:"host"`
-		Port int    `yaml:"port"`
-	} `yaml:"server"`
-	Logging struct {
-		Sources            []string `yaml:"sources"`
-		CollectionInterval int      `yaml:"collection_interval"`
-		SendInterval       int      `yaml:"send_interval"`
-		BatchSize          int      `yaml:"batch_size"`
-		BufferMaxSize      int      `yaml:"buffer_max_size"`
-	} `yaml:"logging"`
-}
-
 func main() {
 	// Парсим флаги командной строки
 	configPath := flag.String("config", "config.yaml", "Path to config file")
+	watchConfig := flag.Bool("watch-config", false, "Reload config when the file changes")
 	flag.Parse()
 
 	// Загружаем конфигурацию
-	config, err := loadConfig(*configPath)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
@@ -46,59 +36,42 @@ func main() {
 	log.Println("========================================")
 	log.Println("SIEM Agent v1.0")
 	log.Println("========================================")
-	log.Printf("Agent ID: %s\n", config.Agent. ID)
-	log.Printf("Server: %s:%d\n", config.Server.Host, config.Server.Port)
-	log.Printf("Collection Interval: %d ms\n", config. Logging.CollectionInterval)
-	log.Printf("Send Interval: %d ms\n", config. Logging.SendInterval)
+	log.Printf("Agent ID: %s\n", cfg.Agent. ID)
+	log.Printf("Server: %s:%d\n", cfg.Server.Host, cfg.Server.Port)
+	log.Printf("Collection Interval: %d ms\n", cfg. Logging.CollectionInterval)
+	log.Printf("Send Interval: %d ms\n", cfg. Logging.SendInterval)
 	log.Println("========================================")
 
+	// Часовой пояс для времени в записях без зоны
+	applyTimezone(cfg)
+
 	// Создаем компоненты
-	rbuffer := buffer.NewRingBuffer(config.Logging.BufferMaxSize)
+	rbuffer := buffer.NewRingBuffer(cfg.Logging.BufferMaxSize)
 	processorInstance := processor.NewLogProcessor()
-	senderInstance := sender.NewTCPSender(config.Server. Host, config.Server.Port)
-
-	// Создаём конфигурацию агента
-	agentConfig := agent.Config{
-		AgentID:            config.Agent.ID,
-		ServerHost:         config.Server. Host,
-		ServerPort:         config.Server.Port,
-		CollectionInterval: config.Logging.CollectionInterval,
-		SenderInterval:     config.Logging.SendInterval,
-		BatchSize:          config.Logging.BatchSize,
-		BufferMaxSize:      config.Logging.BufferMaxSize,
+	if err := processorInstance.LoadRules(cfg.Processor.RulesDir); err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
 	}
+	senderInstance := sender.NewTCPSender(cfg.Server. Host, cfg.Server.Port)
 
 	// Создаём агент
-	siem := agent. NewAgent(agentConfig, rbuffer, processorInstance, senderInstance)
+	siem := agent. NewAgent(agentConfig(cfg), rbuffer, processorInstance, senderInstance)
+
+	// Подключаем хранилище позиций чтения до регистрации сборщиков
+	if store, err := checkpoint.Open(cfg.Agent.StateDir); err != nil {
+		log.Printf("[Main] Checkpoints disabled, sources will be read from the beginning: %v", err)
+	} else {
+		siem.SetCheckpointStore(store)
+	}
 
 	// Регистрируем сборщики логов
 	log.Println("\n[Main] Registering log collectors...")
 
-	// Syslog collector
-	if len(config.Logging.Sources) > 0 {
-		for _, source := range config.Logging.Sources {
-			switch source {
-			case "syslog":
-				siem.RegisterCollector(collector.NewSyslogCollector("/var/log/syslog"))
-				log. Println("[Main] Syslog collector registered")
-			case "auditd":
-				siem.RegisterCollector(collector.NewAuditCollector("/var/log/audit/audit.log"))
-				log.Println("[Main] Audit collector registered")
-			case "bash_history":
-				// Регистрируем bash_history для текущего пользователя
-				currentUser := os.Getenv("USER")
-				homeDir := os.Getenv("HOME")
-				if currentUser != "" && homeDir != "" {
-					siem.RegisterCollector(collector.NewBashCollector(currentUser, homeDir))
-					log.Println("[Main] Bash history collector registered")
-				}
-			}
-		}
-	}
+	configReloader := newReloader(*configPath, cfg, siem, processorInstance)
+	configReloader.applySources(cfg.Logging.Sources)
 
-	// Обработчик сигналов для graceful shutdown
+	// Обработчик сигналов: SIGHUP перечитывает конфигурацию, остальные завершают работу
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Запускаем агент
 	if err := siem.Start(); err != nil {
@@ -108,8 +81,23 @@ func main() {
 	// Горутина для мониторинга статуса
 	go monitorAgent(siem)
 
+	// Следим за файлом конфигурации
+	watchDone := make(chan struct{})
+	if *watchConfig {
+		if err := configReloader.watch(watchDone); err != nil {
+			log.Printf("[Main] Config watch disabled: %v", err)
+		}
+	}
+
 	// Ждём сигнала завершения
-	sig := <-sigChan
+	var sig os.Signal
+	for sig = range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		configReloader.reload()
+	}
+	close(watchDone)
 	log.Printf("\n[Main] Received signal: %v", sig)
 
 	// Останавливаем агент
@@ -132,30 +120,138 @@ func monitorAgent(siem *agent.Agent) {
 	}
 }
 
-// loadConfig загружает конфигурацию из YAML файла
-func loadConfig(path string) (Config, error) {
-	// Для простоты используем встроенную конфиг
-	// В реальности здесь была бы загрузка из YAML файла
-	return Config{
-		Agent: struct {
-			ID string `yaml:"id"`
-		}{ID: "agent-ubuntu-01"},
-		Server: struct {
-			Host string `yaml:"host"`
-			Port int    `yaml:"port"`
-		}{Host: "127.0.0.1", Port: 8080},
-		Logging: struct {
-			Sources            []string `yaml:"sources"`
-			CollectionInterval int      `yaml:"collection_interval"`
-			SendInterval       int      `yaml:"send_interval"`
-			BatchSize          int      `yaml:"batch_size"`
-			BufferMaxSize      int      `yaml:"buffer_max_size"`
-		}{
-			Sources:            []string{"syslog", "auditd", "bash_history"},
-			CollectionInterval: 5000,
-			SendInterval:       10000,
-			BatchSize:          100,
-			BufferMaxSize:       10000,
-		},
-	}, nil
-}
\ No newline at end of file
+// applyTimezone задаёт сборщикам часовой пояс из конфигурации
+func applyTimezone(cfg config.Config) {
+	loc, err := cfg.Agent.Location()
+	if err != nil {
+		log.Printf("[Main] Invalid timezone %q, using local: %v", cfg.Agent.Timezone, err)
+		loc = time.Local
+	}
+	collector.SetLocation(loc)
+}
+
+// newCollectors создаёт сборщики логов по описанию источника из конфигурации.
+// Для источников с all_users создаётся сборщик на каждого пользователя
+func newCollectors(source config.Source, stateDir string) ([]collector.Collector, error) {
+	if source.Type == config.SourceTypeFile {
+		col, err := collector.NewFileCollector(collector.FileCollectorConfig{
+			Name:            source.Name,
+			Paths:           source.Paths,
+			Format:          source.Parser.Format,
+			Pattern:         source.Parser.Pattern,
+			TimestampLayout: source.Parser.TimestampLayout,
+			Mapping:         source.Mapping,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []collector.Collector{col}, nil
+	}
+
+	if source.AllUsers {
+		return newUserCollectors(source)
+	}
+
+	col, err := newCollector(source, stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return []collector.Collector{col}, nil
+}
+
+// userHistoryFiles расположение файлов истории относительно домашнего каталога
+var userHistoryFiles = map[string]string{
+	"bash_history": ".bash_history",
+	"zsh_history":  ".zsh_history",
+	"fish_history": ".local/share/fish/fish_history",
+}
+
+// newUserCollectors создаёт сборщики истории для всех пользователей из /etc/passwd,
+// которым разрешён вход. Файла истории может ещё не быть: сборщик начнёт
+// читать его, когда пользователь выполнит первую команду
+func newUserCollectors(source config.Source) ([]collector.Collector, error) {
+	historyFile, ok := userHistoryFiles[source.Name]
+	if !ok {
+		return nil, fmt.Errorf("all_users is not supported by source %q", source.Name)
+	}
+
+	users := identity.NewSystemResolver().Users()
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	var collectors []collector.Collector
+	seenHomes := make(map[string]bool)
+	for _, user := range users {
+		if user.Home == "" || user.Home == "/" || seenHomes[user.Home] || !loginShell(user.Shell) {
+			continue
+		}
+
+		historyPath := filepath.Join(user.Home, historyFile)
+		collectors = append(collectors, newHistoryCollector(source.Name, user.Name, historyPath))
+		seenHomes[user.Home] = true
+	}
+
+	if len(collectors) == 0 {
+		return nil, fmt.Errorf("no login users found in passwd")
+	}
+	return collectors, nil
+}
+
+// loginShell проверяет, что оболочка позволяет пользователю войти в систему
+func loginShell(shell string) bool {
+	switch filepath.Base(shell) {
+	case "nologin", "false":
+		return false
+	}
+	return true
+}
+
+// newCollector создаёт сборщик логов по описанию источника из конфигурации
+func newCollector(source config.Source, stateDir string) (collector.Collector, error) {
+	switch source.Name {
+	case "syslog":
+		return collector.NewSyslogCollector(source.Path), nil
+	case "auditd":
+		return collector.NewAuditCollector(source.Path), nil
+	case "authlog":
+		return collector.NewAuthLogCollector(source.Path), nil
+	case "journald":
+		return collector.NewJournalCollector(source.Path), nil
+	case "process":
+		return collector.NewProcessCollector(source.Path), nil
+	case "network":
+		return collector.NewNetworkCollector(source.Path), nil
+	case "wtmp":
+		return collector.NewWtmpCollector(source.Path), nil
+	case "btmp":
+		return collector.NewBtmpCollector(source.Path), nil
+	case "kmsg":
+		return collector.NewKmsgCollector(source.Path), nil
+	case "docker":
+		return collector.NewDockerCollector(source.Path), nil
+	case "fim":
+		return collector.NewFIMCollector(source.Paths, stateDir), nil
+	case "syslog_listener":
+		return collector.NewSyslogListener(source.Listen), nil
+	case "bash_history", "zsh_history", "fish_history":
+		// Историю оболочки собираем для текущего пользователя
+		currentUser := os.Getenv("USER")
+		if currentUser == "" {
+			return nil, fmt.Errorf("USER is not set")
+		}
+		return newHistoryCollector(source.Name, currentUser, source.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", source.Name)
+	}
+}
+
+// newHistoryCollector создаёт сборщик истории оболочки пользователя
+func newHistoryCollector(name, user, historyPath string) collector.Collector {
+	switch name {
+	case "zsh_history":
+		return collector.NewZshCollector(user, historyPath)
+	case "fish_history":
+		return collector.NewFishCollector(user, historyPath)
+	default:
+		return collector.NewBashCollector(user, historyPath)
+	}
+}
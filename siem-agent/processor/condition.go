@@ -0,0 +1,322 @@
+package processor
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConditionDefinition условие правила: проверка поля одним оператором
+// (regex, keywords, equals, in, cidr или числовое сравнение) либо
+// комбинация условий all, any, not
+type ConditionDefinition struct {
+	// Field поле события: raw_log (по умолчанию), user, process, command, ...
+	// или fields.<ключ>
+	Field string `yaml:"field"`
+	// Regex регулярное выражение (RE2)
+	Regex string `yaml:"regex"`
+	// Keywords подстроки без учёта регистра, достаточно одной
+	Keywords []string `yaml:"keywords"`
+	// Equals точное совпадение значения
+	Equals *string `yaml:"equals"`
+	// In значение совпадает с одним из списка
+	In []string `yaml:"in"`
+	// CIDR адрес входит в одну из сетей: 10.0.0.0/8, fd00::/8 или отдельный IP
+	CIDR []string `yaml:"cidr"`
+	// GT, GTE, LT, LTE числовое сравнение; границы можно сочетать
+	GT  *float64 `yaml:"gt"`
+	GTE *float64 `yaml:"gte"`
+	LT  *float64 `yaml:"lt"`
+	LTE *float64 `yaml:"lte"`
+
+	// All выполнены все условия
+	All []ConditionDefinition `yaml:"all"`
+	// Any выполнено хотя бы одно условие
+	Any []ConditionDefinition `yaml:"any"`
+	// Not условие не выполнено
+	Not *ConditionDefinition `yaml:"not"`
+}
+
+// Condition скомпилированное условие правила
+type Condition interface {
+	Match(event Event) bool
+}
+
+// compileCondition проверяет описание условия и компилирует его
+func compileCondition(def ConditionDefinition) (Condition, error) {
+	combinators := 0
+	for _, set := range []bool{def.All != nil, def.Any != nil, def.Not != nil} {
+		if set {
+			combinators++
+		}
+	}
+	operators := def.operators()
+
+	switch {
+	case combinators > 1:
+		return nil, fmt.Errorf("all, any and not are mutually exclusive")
+	case combinators == 1 && (len(operators) > 0 || def.Field != ""):
+		return nil, fmt.Errorf("all, any and not cannot be combined with field operators")
+	case def.All != nil:
+		conditions, err := compileConditions("all", def.All)
+		return allCondition(conditions), err
+	case def.Any != nil:
+		conditions, err := compileConditions("any", def.Any)
+		return anyCondition(conditions), err
+	case def.Not != nil:
+		condition, err := compileCondition(*def.Not)
+		if err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+		return notCondition{condition}, nil
+	}
+
+	if err := validateFieldName(def.Field); err != nil {
+		return nil, err
+	}
+	field := def.Field
+	if field == "" {
+		field = FieldRawLog
+	}
+
+	switch {
+	case len(operators) == 0:
+		return nil, fmt.Errorf("regex, keywords, equals, in, cidr, gt/gte/lt/lte, all, any or not is required")
+	case len(operators) > 1:
+		return nil, fmt.Errorf("%s are mutually exclusive, use all to combine them", strings.Join(operators, ", "))
+	}
+
+	switch {
+	case def.Regex != "":
+		pattern, err := regexp.Compile(def.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return regexCondition{field, pattern}, nil
+
+	case def.Keywords != nil:
+		if len(def.Keywords) == 0 {
+			return nil, fmt.Errorf("keywords must not be empty")
+		}
+		lowered := make([]string, 0, len(def.Keywords))
+		for _, keyword := range def.Keywords {
+			if keyword == "" {
+				return nil, fmt.Errorf("keywords must not be empty")
+			}
+			lowered = append(lowered, strings.ToLower(keyword))
+		}
+		return keywordsCondition{field, lowered}, nil
+
+	case def.Equals != nil:
+		return equalsCondition{field, *def.Equals}, nil
+
+	case def.In != nil:
+		if len(def.In) == 0 {
+			return nil, fmt.Errorf("in must not be empty")
+		}
+		values := make(map[string]bool, len(def.In))
+		for _, value := range def.In {
+			values[value] = true
+		}
+		return inCondition{field, values}, nil
+
+	case def.CIDR != nil:
+		if len(def.CIDR) == 0 {
+			return nil, fmt.Errorf("cidr must not be empty")
+		}
+		prefixes := make([]netip.Prefix, 0, len(def.CIDR))
+		for _, network := range def.CIDR {
+			prefix, err := parsePrefix(network)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		return cidrCondition{field, prefixes}, nil
+
+	default:
+		return rangeCondition{field: field, gt: def.GT, gte: def.GTE, lt: def.LT, lte: def.LTE}, nil
+	}
+}
+
+// operators возвращает заданные операторы проверки поля. Границы числового
+// сравнения считаются одним оператором
+func (def ConditionDefinition) operators() []string {
+	var operators []string
+	if def.Regex != "" {
+		operators = append(operators, "regex")
+	}
+	if def.Keywords != nil {
+		operators = append(operators, "keywords")
+	}
+	if def.Equals != nil {
+		operators = append(operators, "equals")
+	}
+	if def.In != nil {
+		operators = append(operators, "in")
+	}
+	if def.CIDR != nil {
+		operators = append(operators, "cidr")
+	}
+	if def.GT != nil || def.GTE != nil || def.LT != nil || def.LTE != nil {
+		operators = append(operators, "gt/gte/lt/lte")
+	}
+	return operators
+}
+
+// compileConditions компилирует вложенные условия all или any
+func compileConditions(name string, defs []ConditionDefinition) ([]Condition, error) {
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("%s must not be empty", name)
+	}
+	conditions := make([]Condition, 0, len(defs))
+	for i, def := range defs {
+		condition, err := compileCondition(def)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// parsePrefix разбирает сеть CIDR или отдельный адрес
+func parsePrefix(network string) (netip.Prefix, error) {
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid cidr %q", network)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr %q", network)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// mustCompileCondition компилирует условие встроенного правила
+func mustCompileCondition(def ConditionDefinition) Condition {
+	condition, err := compileCondition(def)
+	if err != nil {
+		panic(fmt.Sprintf("condition: %v", err))
+	}
+	return condition
+}
+
+// stringPtr адрес строки для Equals во встроенных правилах
+func stringPtr(s string) *string {
+	return &s
+}
+
+type regexCondition struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+func (c regexCondition) Match(event Event) bool {
+	return c.pattern.MatchString(eventField(event, c.field))
+}
+
+type keywordsCondition struct {
+	field    string
+	keywords []string // в нижнем регистре
+}
+
+func (c keywordsCondition) Match(event Event) bool {
+	value := strings.ToLower(eventField(event, c.field))
+	for _, keyword := range c.keywords {
+		if strings.Contains(value, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+type equalsCondition struct {
+	field string
+	value string
+}
+
+func (c equalsCondition) Match(event Event) bool {
+	return eventField(event, c.field) == c.value
+}
+
+type inCondition struct {
+	field  string
+	values map[string]bool
+}
+
+func (c inCondition) Match(event Event) bool {
+	return c.values[eventField(event, c.field)]
+}
+
+type cidrCondition struct {
+	field    string
+	prefixes []netip.Prefix
+}
+
+// Match проверяет адрес. Значение, не являющееся IP, не подходит
+func (c cidrCondition) Match(event Event) bool {
+	addr, err := netip.ParseAddr(eventField(event, c.field))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range c.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type rangeCondition struct {
+	field            string
+	gt, gte, lt, lte *float64
+}
+
+// Match сравнивает число. Нечисловое значение не подходит
+func (c rangeCondition) Match(event Event) bool {
+	value, err := strconv.ParseFloat(strings.TrimSpace(eventField(event, c.field)), 64)
+	if err != nil {
+		return false
+	}
+	return (c.gt == nil || value > *c.gt) &&
+		(c.gte == nil || value >= *c.gte) &&
+		(c.lt == nil || value < *c.lt) &&
+		(c.lte == nil || value <= *c.lte)
+}
+
+type allCondition []Condition
+
+func (c allCondition) Match(event Event) bool {
+	for _, condition := range c {
+		if !condition.Match(event) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyCondition []Condition
+
+func (c anyCondition) Match(event Event) bool {
+	for _, condition := range c {
+		if condition.Match(event) {
+			return true
+		}
+	}
+	return false
+}
+
+type notCondition struct {
+	condition Condition
+}
+
+func (c notCondition) Match(event Event) bool {
+	return !c.condition.Match(event)
+}
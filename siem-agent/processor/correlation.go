@@ -0,0 +1,352 @@
+package processor
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCorrelationMaxKeys сколько групп (пользователей, IP) правило
+// корреляции отслеживает одновременно
+const DefaultCorrelationMaxKeys = 10000
+
+// CorrelationSource источник синтетических событий корреляции
+const CorrelationSource = "correlation"
+
+// CorrelationDefinition описание правила корреляции: threshold событий match
+// за window секунд или цепочка шагов sequence в пределах window для одной группы
+type CorrelationDefinition struct {
+	Name      string                `yaml:"name"`
+	Match     *ConditionDefinition  `yaml:"match"`
+	Sequence  []ConditionDefinition `yaml:"sequence"`
+	GroupBy   []string              `yaml:"group_by"`
+	Threshold int                   `yaml:"threshold"`
+	Window    int                   `yaml:"window"`   // секунды
+	MaxKeys   int                   `yaml:"max_keys"` // по умолчанию DefaultCorrelationMaxKeys
+	Severity  string                `yaml:"severity"`
+	EventType string                `yaml:"event_type"`
+}
+
+// correlationWindow состояние одной группы
+type correlationWindow struct {
+	times    []time.Time // threshold: время подходящих событий в окне
+	step     int         // sequence: номер ожидаемого шага
+	started  time.Time   // sequence: время первого шага
+	lastSeen time.Time
+}
+
+// CorrelationRule правило корреляции с состоянием по группам
+type CorrelationRule struct {
+	Name      string
+	Severity  string
+	EventType string
+
+	definition CorrelationDefinition
+	steps      []Condition // для threshold — один шаг
+	sequence   bool
+	groupBy    []string
+	threshold  int
+	window     time.Duration
+	maxKeys    int
+
+	mu        sync.Mutex
+	groups    map[string]*correlationWindow
+	lastSweep time.Time
+}
+
+// compileCorrelation проверяет описание правила корреляции
+func compileCorrelation(def CorrelationDefinition) (*CorrelationRule, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !isValidSeverity(strings.ToUpper(def.Severity)) {
+		return nil, fmt.Errorf("severity must be INFO, WARNING or CRITICAL, got %q", def.Severity)
+	}
+	if def.EventType == "" {
+		return nil, fmt.Errorf("event_type is required")
+	}
+	if def.Window <= 0 {
+		return nil, fmt.Errorf("window must be positive, got %d", def.Window)
+	}
+	if def.MaxKeys < 0 {
+		return nil, fmt.Errorf("max_keys must not be negative, got %d", def.MaxKeys)
+	}
+	for _, field := range def.GroupBy {
+		if err := validateFieldName(field); err != nil || field == "" {
+			return nil, fmt.Errorf("group_by: unknown field %q", field)
+		}
+	}
+
+	rule := &CorrelationRule{
+		Name:       def.Name,
+		Severity:   strings.ToUpper(def.Severity),
+		EventType:  strings.ToLower(def.EventType),
+		definition: def,
+		groupBy:    def.GroupBy,
+		threshold:  def.Threshold,
+		window:     time.Duration(def.Window) * time.Second,
+		maxKeys:    def.MaxKeys,
+		groups:     make(map[string]*correlationWindow),
+	}
+	if rule.maxKeys == 0 {
+		rule.maxKeys = DefaultCorrelationMaxKeys
+	}
+
+	var steps []ConditionDefinition
+	switch {
+	case def.Match != nil && len(def.Sequence) > 0:
+		return nil, fmt.Errorf("match and sequence are mutually exclusive")
+	case def.Match != nil:
+		if def.Threshold < 1 {
+			return nil, fmt.Errorf("threshold must be positive, got %d", def.Threshold)
+		}
+		steps = []ConditionDefinition{*def.Match}
+	case len(def.Sequence) > 0:
+		if def.Threshold != 0 {
+			return nil, fmt.Errorf("threshold is not supported with sequence")
+		}
+		rule.sequence = true
+		steps = def.Sequence
+	default:
+		return nil, fmt.Errorf("match or sequence is required")
+	}
+
+	for i, step := range steps {
+		condition, err := compileCondition(step)
+		if err != nil {
+			if rule.sequence {
+				return nil, fmt.Errorf("sequence[%d]: %w", i, err)
+			}
+			return nil, fmt.Errorf("match: %w", err)
+		}
+		rule.steps = append(rule.steps, condition)
+	}
+	return rule, nil
+}
+
+// sameDefinition проверяет, что правило описано так же: при перезагрузке
+// такие правила сохраняют накопленное состояние
+func (r *CorrelationRule) sameDefinition(other *CorrelationRule) bool {
+	return r.Name == other.Name && reflect.DeepEqual(r.definition, other.definition)
+}
+
+// Observe учитывает событие и возвращает синтетическое событие, если
+// правило сработало
+func (r *CorrelationRule) Observe(event Event) (Event, bool) {
+	// Быстрая проверка без блокировки: большинство событий не подходит ни под один шаг
+	relevant := false
+	for _, step := range r.steps {
+		if step.Match(event) {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return Event{}, false
+	}
+
+	key, ok := r.groupKey(event)
+	if !ok {
+		return Event{}, false
+	}
+	now := correlationTime(event)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+	group, exists := r.groups[key]
+	if !exists {
+		if len(r.groups) >= r.maxKeys {
+			r.evictOldest()
+		}
+		group = &correlationWindow{}
+		r.groups[key] = group
+	}
+	group.lastSeen = now
+
+	if r.sequence {
+		return r.observeSequence(event, key, group, now)
+	}
+	return r.observeThreshold(event, key, group, now)
+}
+
+// observeThreshold считает события в скользящем окне. Хранится не больше
+// threshold отметок времени на группу
+func (r *CorrelationRule) observeThreshold(event Event, key string, group *correlationWindow, now time.Time) (Event, bool) {
+	cutoff := now.Add(-r.window)
+	kept := group.times[:0]
+	for _, t := range group.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	group.times = append(kept, now)
+	if len(group.times) > r.threshold {
+		group.times = group.times[len(group.times)-r.threshold:]
+	}
+
+	if len(group.times) < r.threshold {
+		return Event{}, false
+	}
+
+	first := group.times[0]
+	delete(r.groups, key)
+	alert := r.alert(event, first, now)
+	alert.Fields["count"] = strconv.Itoa(r.threshold)
+	alert.RawLog = fmt.Sprintf("%s: %d events within %ds for %s", r.Name, r.threshold, int(r.window.Seconds()), r.describeGroup(event))
+	return alert, true
+}
+
+// observeSequence продвигает цепочку шагов. Если окно истекло, цепочка
+// начинается заново
+func (r *CorrelationRule) observeSequence(event Event, key string, group *correlationWindow, now time.Time) (Event, bool) {
+	if group.step > 0 && now.Sub(group.started) > r.window {
+		group.step = 0
+	}
+
+	switch {
+	case group.step > 0 && r.steps[group.step].Match(event):
+		group.step++
+	case r.steps[0].Match(event):
+		// Повтор первого шага перезапускает окно
+		group.step = 1
+		group.started = now
+	default:
+		return Event{}, false
+	}
+
+	if group.step < len(r.steps) {
+		return Event{}, false
+	}
+
+	started := group.started
+	delete(r.groups, key)
+	alert := r.alert(event, started, now)
+	alert.Fields["steps"] = strconv.Itoa(len(r.steps))
+	alert.RawLog = fmt.Sprintf("%s: sequence of %d steps within %ds for %s", r.Name, len(r.steps), int(r.window.Seconds()), r.describeGroup(event))
+	return alert, true
+}
+
+// alert создаёт синтетическое событие по событию, завершившему условие
+func (r *CorrelationRule) alert(trigger Event, first, last time.Time) Event {
+	alert := Event{
+		Timestamp: trigger.Timestamp,
+		Hostname:  trigger.Hostname,
+		Source:    CorrelationSource,
+		EventType: r.EventType,
+		Severity:  r.Severity,
+		User:      trigger.User,
+		Fields: map[string]string{
+			"rule":           r.Name,
+			"window":         strconv.Itoa(int(r.window.Seconds())),
+			"first_seen":     first.Format(time.RFC3339),
+			"last_seen":      last.Format(time.RFC3339),
+			"trigger_source": trigger.Source,
+		},
+	}
+	if alert.Timestamp == "" {
+		alert.Timestamp = last.Format(time.RFC3339)
+	}
+	for _, field := range r.groupBy {
+		alert.Fields[strings.TrimPrefix(field, fieldsPrefix)] = eventField(trigger, field)
+	}
+	return alert
+}
+
+// groupKey ключ группы события. false — у события нет значения поля группировки
+func (r *CorrelationRule) groupKey(event Event) (string, bool) {
+	values := make([]string, len(r.groupBy))
+	for i, field := range r.groupBy {
+		values[i] = eventField(event, field)
+		if values[i] == "" {
+			return "", false
+		}
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// describeGroup описание группы для текста события: user=bob src_ip=1.2.3.4
+func (r *CorrelationRule) describeGroup(event Event) string {
+	if len(r.groupBy) == 0 {
+		return "all events"
+	}
+	parts := make([]string, len(r.groupBy))
+	for i, field := range r.groupBy {
+		parts[i] = strings.TrimPrefix(field, fieldsPrefix) + "=" + eventField(event, field)
+	}
+	return strings.Join(parts, " ")
+}
+
+// sweep удаляет группы без событий дольше окна. Выполняется не чаще раза за окно
+func (r *CorrelationRule) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.window {
+		return
+	}
+	r.lastSweep = now
+	cutoff := now.Add(-r.window)
+	for key, group := range r.groups {
+		if group.lastSeen.Before(cutoff) {
+			delete(r.groups, key)
+		}
+	}
+}
+
+// evictOldest освобождает место, удаляя группу с самым старым событием
+func (r *CorrelationRule) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, group := range r.groups {
+		if oldestKey == "" || group.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, group.lastSeen
+		}
+	}
+	delete(r.groups, oldestKey)
+}
+
+// correlationTime время события для окна. Если время записи не разобрать,
+// используется время обработки
+func correlationTime(event Event) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, event.Timestamp); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// mergeCorrelations переносит состояние неизменённых правил из прежнего набора
+func mergeCorrelations(previous, loaded []*CorrelationRule) []*CorrelationRule {
+	merged := make([]*CorrelationRule, len(loaded))
+	for i, rule := range loaded {
+		merged[i] = rule
+		for _, old := range previous {
+			if old.sameDefinition(rule) {
+				merged[i] = old
+				break
+			}
+		}
+	}
+	return merged
+}
+
+// mustCompileCorrelation компилирует встроенное правило корреляции
+func mustCompileCorrelation(def CorrelationDefinition) *CorrelationRule {
+	rule, err := compileCorrelation(def)
+	if err != nil {
+		panic(fmt.Sprintf("correlation rule %s: %v", def.Name, err))
+	}
+	return rule
+}
+
+// correlate передаёт событие правилам корреляции и возвращает их срабатывания
+func correlate(event Event, rules []*CorrelationRule) []Event {
+	var alerts []Event
+	for _, rule := range rules {
+		if alert, ok := rule.Observe(event); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,307 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+var correlationStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// at событие со временем correlationStart + offset
+func at(offset time.Duration, event Event) Event {
+	event.Timestamp = correlationStart.Add(offset).Format(time.RFC3339Nano)
+	return event
+}
+
+func sshFailure(ip string) Event {
+	return Event{Source: "authlog", EventType: "ssh_login_failed", RawLog: "Failed password", Fields: map[string]string{"src_ip": ip}}
+}
+
+func sudoEvent(eventType, user string) Event {
+	return Event{Source: "authlog", EventType: eventType, User: user, RawLog: "sudo"}
+}
+
+func thresholdRule(t *testing.T, threshold, window int) *CorrelationRule {
+	t.Helper()
+	rule, err := compileCorrelation(CorrelationDefinition{
+		Name:      "SSH_Brute_Force",
+		Match:     &ConditionDefinition{Field: "event_type", Equals: stringPtr("ssh_login_failed")},
+		GroupBy:   []string{"fields.src_ip"},
+		Threshold: threshold,
+		Window:    window,
+		Severity:  "critical",
+		EventType: "brute_force_attack",
+	})
+	if err != nil {
+		t.Fatalf("compileCorrelation: %v", err)
+	}
+	return rule
+}
+
+func sequenceRule(t *testing.T, window int) *CorrelationRule {
+	t.Helper()
+	rule, err := compileCorrelation(CorrelationDefinition{
+		Name: "Sudo_Failure_Then_Success",
+		Sequence: []ConditionDefinition{
+			{Field: "event_type", Equals: stringPtr("sudo_auth_failure")},
+			{Field: "event_type", Equals: stringPtr("sudo_execution")},
+		},
+		GroupBy:   []string{"user"},
+		Window:    window,
+		Severity:  "WARNING",
+		EventType: "sudo_success_after_failure",
+	})
+	if err != nil {
+		t.Fatalf("compileCorrelation: %v", err)
+	}
+	return rule
+}
+
+// observeAll передаёт события правилу и возвращает номера событий, на которых оно сработало
+func observeAll(rule *CorrelationRule, events []Event) ([]int, []Event) {
+	var fired []int
+	var alerts []Event
+	for i, event := range events {
+		if alert, ok := rule.Observe(event); ok {
+			fired = append(fired, i)
+			alerts = append(alerts, alert)
+		}
+	}
+	return fired, alerts
+}
+
+func TestCorrelationThreshold(t *testing.T) {
+	rule := thresholdRule(t, 10, 60)
+
+	var events []Event
+	for i := 0; i < 12; i++ {
+		events = append(events, at(time.Duration(i)*time.Second, sshFailure("203.0.113.7")))
+		// Попытки с другого адреса в группу не входят
+		events = append(events, at(time.Duration(i)*time.Second, sshFailure("198.51.100.1")))
+	}
+	events = append(events, at(5*time.Second, Event{Source: "authlog", EventType: "ssh_login_success", RawLog: "Accepted", Fields: map[string]string{"src_ip": "203.0.113.7"}}))
+
+	fired, alerts := observeAll(rule, events)
+	if len(fired) != 2 || fired[0] != 18 || fired[1] != 19 {
+		t.Fatalf("fired on events %v, want [18 19] (10th attempt from each address)", fired)
+	}
+
+	alert := alerts[0]
+	if alert.Source != CorrelationSource || alert.EventType != "brute_force_attack" || alert.Severity != "CRITICAL" {
+		t.Errorf("alert = %s/%s/%s", alert.Source, alert.EventType, alert.Severity)
+	}
+	want := map[string]string{
+		"rule":       "SSH_Brute_Force",
+		"count":      "10",
+		"window":     "60",
+		"src_ip":     "203.0.113.7",
+		"first_seen": "2024-03-01T12:00:00Z",
+		"last_seen":  "2024-03-01T12:00:09Z",
+	}
+	for key, value := range want {
+		if alert.Fields[key] != value {
+			t.Errorf("Fields[%q] = %q, want %q", key, alert.Fields[key], value)
+		}
+	}
+}
+
+func TestCorrelationThresholdResetsAfterFiring(t *testing.T) {
+	rule := thresholdRule(t, 3, 60)
+
+	var events []Event
+	for i := 0; i < 7; i++ {
+		events = append(events, at(time.Duration(i)*time.Second, sshFailure("203.0.113.7")))
+	}
+	if fired, _ := observeAll(rule, events); len(fired) != 2 || fired[0] != 2 || fired[1] != 5 {
+		t.Fatalf("fired on events %v, want [2 5]", fired)
+	}
+}
+
+func TestCorrelationThresholdWindowExpiry(t *testing.T) {
+	rule := thresholdRule(t, 3, 60)
+
+	events := []Event{
+		at(0, sshFailure("203.0.113.7")),
+		at(30*time.Second, sshFailure("203.0.113.7")),
+		// Первая попытка уже вне окна: в окне только две
+		at(61*time.Second, sshFailure("203.0.113.7")),
+		// Три попытки за 60 секунд: 30, 61, 89
+		at(89*time.Second, sshFailure("203.0.113.7")),
+	}
+	if fired, _ := observeAll(rule, events); len(fired) != 1 || fired[0] != 3 {
+		t.Fatalf("fired on events %v, want [3]", fired)
+	}
+}
+
+func TestCorrelationExpiredGroupsAreRemoved(t *testing.T) {
+	rule := thresholdRule(t, 10, 60)
+	rule.Observe(at(0, sshFailure("203.0.113.7")))
+	rule.Observe(at(time.Second, sshFailure("198.51.100.1")))
+
+	rule.Observe(at(10*time.Minute, sshFailure("192.0.2.1")))
+	if len(rule.groups) != 1 {
+		t.Fatalf("groups after expiry = %d, want 1", len(rule.groups))
+	}
+}
+
+func TestCorrelationMaxKeys(t *testing.T) {
+	rule := thresholdRule(t, 2, 60)
+	rule.maxKeys = 2
+
+	rule.Observe(at(0, sshFailure("192.0.2.1")))
+	rule.Observe(at(time.Second, sshFailure("192.0.2.2")))
+	// Третья группа вытесняет самую старую
+	rule.Observe(at(2*time.Second, sshFailure("192.0.2.3")))
+	if len(rule.groups) != 2 {
+		t.Fatalf("groups = %d, want 2", len(rule.groups))
+	}
+	if _, ok := rule.Observe(at(3*time.Second, sshFailure("192.0.2.1"))); ok {
+		t.Error("evicted group kept its count")
+	}
+	if _, ok := rule.Observe(at(4*time.Second, sshFailure("192.0.2.3"))); !ok {
+		t.Error("second attempt from 192.0.2.3 did not fire")
+	}
+}
+
+func TestCorrelationSkipsEventsWithoutGroupValue(t *testing.T) {
+	rule := thresholdRule(t, 1, 60)
+	if _, ok := rule.Observe(at(0, Event{EventType: "ssh_login_failed", RawLog: "Failed password"})); ok {
+		t.Error("event without src_ip fired")
+	}
+}
+
+func TestCorrelationSequence(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		fired  []int
+	}{
+		{
+			name: "failure then success",
+			events: []Event{
+				at(0, sudoEvent("sudo_auth_failure", "bob")),
+				at(10*time.Second, sudoEvent("sudo_execution", "bob")),
+			},
+			fired: []int{1},
+		},
+		{
+			name: "success before failure",
+			events: []Event{
+				at(0, sudoEvent("sudo_execution", "bob")),
+				at(10*time.Second, sudoEvent("sudo_auth_failure", "bob")),
+			},
+		},
+		{
+			name: "different users",
+			events: []Event{
+				at(0, sudoEvent("sudo_auth_failure", "bob")),
+				at(10*time.Second, sudoEvent("sudo_execution", "alice")),
+			},
+		},
+		{
+			name: "window expired",
+			events: []Event{
+				at(0, sudoEvent("sudo_auth_failure", "bob")),
+				at(301*time.Second, sudoEvent("sudo_execution", "bob")),
+			},
+		},
+		{
+			name: "repeated failure restarts window",
+			events: []Event{
+				at(0, sudoEvent("sudo_auth_failure", "bob")),
+				at(200*time.Second, sudoEvent("sudo_auth_failure", "bob")),
+				at(400*time.Second, sudoEvent("sudo_execution", "bob")),
+			},
+			fired: []int{2},
+		},
+		{
+			name: "fires once per sequence",
+			events: []Event{
+				at(0, sudoEvent("sudo_auth_failure", "bob")),
+				at(time.Second, sudoEvent("sudo_execution", "bob")),
+				at(2*time.Second, sudoEvent("sudo_execution", "bob")),
+			},
+			fired: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired, alerts := observeAll(sequenceRule(t, 300), tt.events)
+			if len(fired) != len(tt.fired) {
+				t.Fatalf("fired on events %v, want %v", fired, tt.fired)
+			}
+			for i := range fired {
+				if fired[i] != tt.fired[i] {
+					t.Fatalf("fired on events %v, want %v", fired, tt.fired)
+				}
+			}
+			for _, alert := range alerts {
+				if alert.Fields["user"] != "bob" || alert.Fields["steps"] != "2" || alert.User != "bob" {
+					t.Errorf("alert fields = %v, user %q", alert.Fields, alert.User)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileCorrelationErrors(t *testing.T) {
+	match := &ConditionDefinition{Field: "event_type", Equals: stringPtr("x")}
+	tests := []struct {
+		name string
+		def  CorrelationDefinition
+	}{
+		{"no condition", CorrelationDefinition{Name: "a", Window: 1, Severity: "INFO", EventType: "x"}},
+		{"no threshold", CorrelationDefinition{Name: "a", Match: match, Window: 1, Severity: "INFO", EventType: "x"}},
+		{"no window", CorrelationDefinition{Name: "a", Match: match, Threshold: 1, Severity: "INFO", EventType: "x"}},
+		{"bad group_by", CorrelationDefinition{Name: "a", Match: match, Threshold: 1, Window: 1, GroupBy: []string{"src_ip"}, Severity: "INFO", EventType: "x"}},
+		{"match and sequence", CorrelationDefinition{Name: "a", Match: match, Sequence: []ConditionDefinition{*match}, Window: 1, Severity: "INFO", EventType: "x"}},
+		{"bad severity", CorrelationDefinition{Name: "a", Match: match, Threshold: 1, Window: 1, Severity: "HIGH", EventType: "x"}},
+	}
+	for _, tt := range tests {
+		if _, err := compileCorrelation(tt.def); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestProcessBatchCorrelation(t *testing.T) {
+	lp := NewLogProcessor()
+
+	var batch []Event
+	for i := 0; i < 10; i++ {
+		batch = append(batch, at(time.Duration(i)*time.Second, sshFailure("203.0.113.7")))
+	}
+	result := lp.ProcessBatch(batch)
+	if len(result) != 11 {
+		t.Fatalf("ProcessBatch returned %d events, want 10 + 1 alert", len(result))
+	}
+	alert := result[10]
+	if alert.Source != CorrelationSource || alert.EventType != "brute_force_attack" {
+		t.Fatalf("last event = %s/%s, want correlation alert", alert.Source, alert.EventType)
+	}
+
+	// Синтетическое событие не учитывается правилами корреляции повторно
+	if again := lp.ProcessBatch([]Event{alert}); len(again) != 1 {
+		t.Fatalf("reprocessing alert returned %d events, want 1", len(again))
+	}
+
+	// Process возвращает только само событие
+	if _, ok := lp.Process(at(0, sshFailure("192.0.2.1"))); !ok {
+		t.Error("Process dropped the event")
+	}
+}
+
+func TestLoadRulesKeepsCorrelationState(t *testing.T) {
+	lp := NewLogProcessor()
+	for i := 0; i < 9; i++ {
+		lp.ProcessBatch([]Event{at(time.Duration(i)*time.Second, sshFailure("203.0.113.7"))})
+	}
+	if err := lp.LoadRules(""); err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	result := lp.ProcessBatch([]Event{at(9*time.Second, sshFailure("203.0.113.7"))})
+	if len(result) != 2 {
+		t.Fatalf("10th attempt after reload returned %d events, want event + alert", len(result))
+	}
+}
@@ -1,8 +1,13 @@
 package processor
 
 import (
+	"fmt"
+	"log"
 	"regexp"
 	"strings"
+	"sync"
+
+	"agent/identity"
 )
 
 // Event структура события (копируем для независимости пакета)
@@ -16,6 +21,23 @@ type Event struct {
 	Process   string `json:"process,omitempty"`
 	Command   string `json:"command,omitempty"`
 	RawLog    string `json:"raw_log"`
+
+	// Fields дополнительные поля, извлечённые из записи (pid, src_ip, tty и т.д.)
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// RuleHits сработавшие правила обнаружения
+	RuleHits []RuleHit `json:"rule_hits,omitempty"`
+
+	// Seq номер события, собранного сборщиком, для подтверждения позиций
+	// чтения. У событий, созданных обработчиком, 0
+	Seq uint64 `json:"-"`
+}
+
+// RuleHit сработавшее на событии правило
+type RuleHit struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	EventType string `json:"event_type"`
 }
 
 // Processor интерфейс для обработчика событий
@@ -29,23 +51,40 @@ type Processor interface {
 
 // LogProcessor реализация обработчика логов
 type LogProcessor struct {
+	mu           sync.RWMutex
 	anomalyRules []AnomalyRule
 	filters      []Filter
+	sigmaRules   []*SigmaRule
+	correlations []*CorrelationRule
+	resolver     *identity.Resolver
 }
 
 // AnomalyRule правило обнаружения аномалии
 type AnomalyRule struct {
-	Name     string
-	Pattern  *regexp.Regexp
-	Severity string
+	Name      string
+	Condition Condition
+	Severity  string
 	EventType string
+	Action    string // "alert" (по умолчанию), "drop", "pass"
 }
 
 // Filter правило фильтрации
 type Filter struct {
-	Name    string
-	Pattern *regexp.Regexp
-	Action  string // "drop", "alert", "pass"
+	Name      string
+	Condition Condition
+	Severity  string // для "alert"
+	EventType string // для "alert"
+	Action    string // "drop", "alert", "pass"
+}
+
+// matches проверяет, подходит ли событие под правило
+func (r AnomalyRule) matches(event Event) bool {
+	return r.Condition.Match(event)
+}
+
+// matches проверяет, подходит ли событие под фильтр
+func (f Filter) matches(event Event) bool {
+	return f.Condition.Match(event)
 }
 
 // NewLogProcessor создаёт новый обработчик логов
@@ -53,14 +92,56 @@ func NewLogProcessor() *LogProcessor {
 	return &LogProcessor{
 		anomalyRules: initializeAnomalyRules(),
 		filters:      initializeFilters(),
+		correlations: initializeCorrelationRules(),
+		resolver:     identity.NewSystemResolver(),
 	}
 }
 
-// Process обрабатывает одно событие
+// LoadRules заменяет правила и фильтры загруженными из каталога dir.
+// Пустой dir возвращает встроенные правила. При ошибке действующие правила
+// не меняются. Неизменённые правила корреляции сохраняют накопленные окна
+func (lp *LogProcessor) LoadRules(dir string) error {
+	set := RuleSet{Rules: initializeAnomalyRules(), Filters: initializeFilters(), Correlations: initializeCorrelationRules()}
+	if dir != "" {
+		var err error
+		set, err = LoadRuleDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to load rules from %s: %w", dir, err)
+		}
+		log.Printf("[Processor] Loaded %d rules, %d filters, %d Sigma rules and %d correlations from %s",
+			len(set.Rules), len(set.Filters), len(set.Sigma), len(set.Correlations), dir)
+	}
+
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.anomalyRules = set.Rules
+	lp.filters = set.Filters
+	lp.sigmaRules = set.Sigma
+	lp.correlations = mergeCorrelations(lp.correlations, set.Correlations)
+	return nil
+}
+
+// rules возвращает действующие правила и фильтры
+func (lp *LogProcessor) rules() ([]AnomalyRule, []Filter, []*SigmaRule, []*CorrelationRule) {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	return lp.anomalyRules, lp.filters, lp.sigmaRules, lp.correlations
+}
+
+// Process обрабатывает одно событие. Синтетические события правил
+// корреляции возвращает только ProcessBatch
 func (lp *LogProcessor) Process(event Event) (Event, bool) {
+	event, ok, _ := lp.process(event)
+	return event, ok
+}
+
+// process обрабатывает событие и возвращает события сработавших правил корреляции
+func (lp *LogProcessor) process(event Event) (Event, bool, []Event) {
+	anomalyRules, filters, sigmaRules, correlations := lp.rules()
+
 	// Проверяем фильтры
-	if ! lp.shouldProcess(event) {
-		return event, false
+	if ! lp.shouldProcess(&event, filters) {
+		return event, false, nil
 	}
 
 	// Нормализуем событие
@@ -69,35 +150,57 @@ func (lp *LogProcessor) Process(event Event) (Event, bool) {
 	// Обогащаем событие
 	event = lp.enrich(event)
 
+	// Правила Sigma и корреляции видят событие после нормализации и
+	// обогащения; метки Sigma ставим после правил аномалий
+	sigmaHits := matchSigma(event, sigmaRules)
+
+	// События самих правил корреляции повторно не коррелируем
+	var alerts []Event
+	if event.Source != CorrelationSource {
+		alerts = correlate(event, correlations)
+	}
+
 	// Обнаруживаем аномалии
-	lp.detectAnomaly(&event)
+	if !lp.detectAnomaly(&event, anomalyRules) {
+		return event, false, alerts
+	}
+	tagSigma(&event, sigmaHits)
 
-	return event, true
+	return event, true, alerts
 }
 
-// ProcessBatch обрабатывает пакет событий
+// ProcessBatch обрабатывает пакет событий. События правил корреляции
+// добавляются после события, на котором правило сработало
 func (lp *LogProcessor) ProcessBatch(events []Event) []Event {
 	var result []Event
 
 	for _, event := range events {
-		processedEvent, ok := lp. Process(event)
+		processedEvent, ok, alerts := lp.process(event)
 		if ok {
 			result = append(result, processedEvent)
 		}
+		result = append(result, alerts...)
 	}
 
 	return result
 }
 
-// shouldProcess проверяет, нужно ли обрабатывать событие
-func (lp *LogProcessor) shouldProcess(event Event) bool {
+// shouldProcess проверяет, нужно ли обрабатывать событие. Фильтры проверяются
+// по порядку до pass или drop: drop отбрасывает событие, alert помечает его,
+// как правило обнаружения
+func (lp *LogProcessor) shouldProcess(event *Event, filters []Filter) bool {
 	// Проверяем фильтры
-	for _, filter := range lp.filters {
-		if filter.Pattern.MatchString(event.RawLog) {
-			if filter.Action == "drop" {
-				return false
-			}
+	for _, filter := range filters {
+		if !filter.matches(*event) {
+			continue
+		}
+		switch filter.Action {
+		case ActionDrop:
+			return false
+		case ActionPass:
+			return true
 		}
+		addRuleHit(event, RuleHit{Rule: filter.Name, Severity: filter.Severity, EventType: filter.EventType})
 	}
 
 	// Не обрабатываем пустые сообщения
@@ -141,75 +244,208 @@ func (lp *LogProcessor) enrich(event Event) Event {
 		// Можно добавить port в metadata если понадобится
 	}
 
+	// Переводим числовые UID/GID в имена
+	event = lp.resolveIdentities(event)
+
+	return event
+}
+
+// uidFields поля с UID, для которых добавляется имя пользователя (<поле>_name)
+var uidFields = []string{"uid", "auid", "euid", "suid", "fsuid", "ouid", "target_uid"}
+
+// gidFields поля с GID, для которых добавляется имя группы (<поле>_name)
+var gidFields = []string{"gid", "egid", "sgid", "fsgid", "ogid"}
+
+// resolveIdentities заменяет числовой User на имя пользователя, сохраняя
+// исходный UID в Fields["user_id"], и добавляет имена для UID/GID полей
+func (lp *LogProcessor) resolveIdentities(event Event) Event {
+	if lp.resolver == nil {
+		return event
+	}
+
+	if isNumericID(event.User) {
+		if name, ok := lp.resolver.UserName(event.User); ok {
+			if event.Fields == nil {
+				event.Fields = make(map[string]string)
+			}
+			event.Fields["user_id"] = event.User
+			event.User = name
+		}
+	}
+
+	if event.Fields == nil {
+		return event
+	}
+
+	for _, key := range uidFields {
+		if id := event.Fields[key]; isNumericID(id) && event.Fields[key+"_name"] == "" {
+			if name, ok := lp.resolver.UserName(id); ok {
+				event.Fields[key+"_name"] = name
+			}
+		}
+	}
+	for _, key := range gidFields {
+		if id := event.Fields[key]; isNumericID(id) && event.Fields[key+"_name"] == "" {
+			if name, ok := lp.resolver.GroupName(id); ok {
+				event.Fields[key+"_name"] = name
+			}
+		}
+	}
+
 	return event
 }
 
-// detectAnomaly обнаруживает подозрительную активность
-func (lp *LogProcessor) detectAnomaly(event *Event) bool {
-	for _, rule := range lp.anomalyRules {
-		if rule.Pattern.MatchString(event.RawLog) {
-			event.Severity = rule.Severity
-			event.EventType = rule.EventType
+// isNumericID проверяет, что значение — числовой идентификатор
+func isNumericID(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// detectAnomaly проверяет все правила по порядку и записывает сработавшие
+// в RuleHits. Тип события не меняется, severity повышается до наибольшей
+// среди срабатываний. pass прекращает проверку, drop — возвращает false,
+// событие отбрасывается. Правило, уже записанное в RuleHits, повторно не
+// добавляется, поэтому повторная обработка события ничего не меняет
+func (lp *LogProcessor) detectAnomaly(event *Event, anomalyRules []AnomalyRule) bool {
+	for _, rule := range anomalyRules {
+		if !rule.matches(*event) {
+			continue
+		}
+		switch rule.Action {
+		case ActionDrop:
+			return false
+		case ActionPass:
+			return true
+		}
+
+		addRuleHit(event, RuleHit{Rule: rule.Name, Severity: rule.Severity, EventType: rule.EventType})
+	}
+	return true
+}
+
+// addRuleHit записывает срабатывание правила или фильтра. Тип события не
+// меняется, серьёзность повышается до уровня правила, но не понижается.
+// Повторная обработка события срабатывание не дублирует
+func addRuleHit(event *Event, hit RuleHit) {
+	if hasRuleHit(*event, hit.Rule) {
+		return
+	}
+	event.RuleHits = append(event.RuleHits, hit)
+	raiseSeverity(event, hit.Severity)
+}
+
+// hasRuleHit проверяет, записано ли срабатывание правила name
+func hasRuleHit(event Event, name string) bool {
+	for _, hit := range event.RuleHits {
+		if hit.Rule == name {
 			return true
 		}
 	}
 	return false
 }
 
-// initializeAnomalyRules инициализирует правила обнаружения аномалий
+// initializeAnomalyRules инициализирует правила обнаружения аномалий.
+// Команды проверяются по полю command, чтобы не срабатывать на обычный
+// текст в syslog
 func initializeAnomalyRules() []AnomalyRule {
+	sqlInjection := `(?i)(\bunion\b(\s+all)?\s+select\b|'\s*or\s+'?\d+'?\s*=\s*'?\d+|;\s*(drop|truncate)\s+table\b|\bselect\b.+\bfrom\b.+(--|/\*))`
+
 	return []AnomalyRule{
 		{
-			Name:      "SQL_Injection_Attempt",
-			Pattern:   regexp. MustCompile(`(?i)(union|select|insert|drop|delete|update).*(from|into|table)`),
-			Severity: "CRITICAL",
+			Name: "SQL_Injection_Attempt",
+			Condition: mustCompileCondition(ConditionDefinition{Any: []ConditionDefinition{
+				{Field: "command", Regex: sqlInjection},
+				{All: []ConditionDefinition{
+					{Field: "source", Equals: stringPtr("docker")},
+					{Regex: sqlInjection},
+				}},
+			}}),
+			Severity:  "CRITICAL",
 			EventType: "sql_injection",
+			Action:    ActionAlert,
 		},
 		{
-			Name:      "Brute_Force_SSH",
-			Pattern:   regexp. MustCompile(`(?i)failed password.*ssh`),
-			Severity: "CRITICAL",
-			EventType: "brute_force_attack",
-		},
-		{
-			Name:      "Privilege_Escalation",
-			Pattern:   regexp.MustCompile(`(?i)(sudo|su\s).*(root|wheel)`),
-			Severity: "WARNING",
+			Name: "Privilege_Escalation",
+			Condition: mustCompileCondition(ConditionDefinition{Any: []ConditionDefinition{
+				{All: []ConditionDefinition{
+					{Field: "event_type", In: []string{"sudo_execution", "su_session_opened", "su_success"}},
+					{Field: "fields.target_user", Equals: stringPtr("root")},
+				}},
+				{Field: "command", Regex: `(?i)\b(sudo|su)\s.*\b(root|wheel)\b`},
+			}}),
+			Severity:  "WARNING",
 			EventType: "privilege_escalation",
+			Action:    ActionAlert,
 		},
 		{
 			Name:      "Unauthorized_Access",
-			Pattern:   regexp.MustCompile(`(?i)(unauthorized|denied|permission|forbidden)`),
-			Severity: "WARNING",
+			Condition: mustCompileCondition(ConditionDefinition{Regex: `(?i)\b(unauthorized|(permission|access) denied|forbidden)\b`}),
+			Severity:  "WARNING",
 			EventType: "unauthorized_access",
+			Action:    ActionAlert,
 		},
 		{
 			Name:      "File_Integrity_Violation",
-			Pattern:    regexp.MustCompile(`(?i)(chmod|chown).*(777|755)`),
-			Severity: "WARNING",
+			Condition: mustCompileCondition(ConditionDefinition{Field: "command", Regex: `(?i)\b(chmod|chown)\s.*\b(777|755)\b`}),
+			Severity:  "WARNING",
 			EventType: "file_integrity",
+			Action:    ActionAlert,
 		},
 		{
 			Name:      "Dangerous_Command",
-			Pattern:   regexp.MustCompile(`(?i)(rm\s+-rf|dd\s+if=|mkfs|fdisk|parted)`),
-			Severity: "CRITICAL",
+			Condition: mustCompileCondition(ConditionDefinition{Field: "command", Regex: `(?i)(\brm\s+-rf\b|\bdd\s+if=|\bmkfs\b|\bfdisk\b|\bparted\b)`}),
+			Severity:  "CRITICAL",
 			EventType: "dangerous_command",
+			Action:    ActionAlert,
 		},
 	}
 }
 
+// initializeCorrelationRules инициализирует правила корреляции
+func initializeCorrelationRules() []*CorrelationRule {
+	return []*CorrelationRule{
+		mustCompileCorrelation(CorrelationDefinition{
+			Name:      "SSH_Brute_Force",
+			Match:     &ConditionDefinition{Field: "event_type", Equals: stringPtr("ssh_login_failed")},
+			GroupBy:   []string{"fields.src_ip"},
+			Threshold: 10,
+			Window:    60,
+			Severity:  "CRITICAL",
+			EventType: "brute_force_attack",
+		}),
+		mustCompileCorrelation(CorrelationDefinition{
+			Name: "Sudo_Failure_Then_Success",
+			Sequence: []ConditionDefinition{
+				{Field: "event_type", Equals: stringPtr("sudo_auth_failure")},
+				{Field: "event_type", Equals: stringPtr("sudo_execution")},
+			},
+			GroupBy:   []string{"user"},
+			Window:    300,
+			Severity:  "WARNING",
+			EventType: "sudo_success_after_failure",
+		}),
+	}
+}
+
 // initializeFilters инициализирует фильтры событий
 func initializeFilters() []Filter {
 	return []Filter{
 		{
-			Name:     "Ignore_Systemd_Messages",
-			Pattern: regexp.MustCompile(`(?i)systemd\[.*\]:`),
-			Action:  "pass", // пропускаем systemd сообщения
+			Name:      "Ignore_Systemd_Messages",
+			Condition: mustCompileCondition(ConditionDefinition{Regex: `(?i)systemd\[.*\]:`}),
+			Action:    "pass", // пропускаем systemd сообщения
 		},
 		{
-			Name:    "Ignore_CRON",
-			Pattern: regexp.MustCompile(`(?i)CRON\[`),
-			Action:  "pass", // пропускаем CRON события
+			Name:      "Ignore_CRON",
+			Condition: mustCompileCondition(ConditionDefinition{Regex: `(?i)CRON\[`}),
+			Action:    "pass", // пропускаем CRON события
 		},
 	}
 }
@@ -0,0 +1,273 @@
+package processor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func ruleNames(event Event) []string {
+	var names []string
+	for _, hit := range event.RuleHits {
+		names = append(names, hit.Rule)
+	}
+	return names
+}
+
+func TestDetectAnomalyRecordsAllHits(t *testing.T) {
+	lp := NewLogProcessor()
+	event, ok := lp.Process(Event{
+		Source:    "bash_history",
+		EventType: "command",
+		Severity:  "info",
+		Command:   "sudo -u root rm -rf /srv/data",
+		RawLog:    "sudo -u root rm -rf /srv/data",
+	})
+	if !ok {
+		t.Fatal("event dropped")
+	}
+
+	if want := []string{"Privilege_Escalation", "Dangerous_Command"}; !reflect.DeepEqual(ruleNames(event), want) {
+		t.Errorf("rule hits = %v, want %v", ruleNames(event), want)
+	}
+	if event.Severity != "CRITICAL" {
+		t.Errorf("severity = %s, want CRITICAL (highest hit)", event.Severity)
+	}
+	if event.EventType != "command" {
+		t.Errorf("event_type = %s, want original command", event.EventType)
+	}
+}
+
+func TestDetectAnomalyNeverLowersSeverity(t *testing.T) {
+	lp := NewLogProcessor()
+	event, _ := lp.Process(Event{
+		Source:    "authlog",
+		EventType: "auth_event",
+		Severity:  "CRITICAL",
+		RawLog:    "sshd: access denied for user bob",
+	})
+	if want := []string{"Unauthorized_Access"}; !reflect.DeepEqual(ruleNames(event), want) {
+		t.Fatalf("rule hits = %v, want %v", ruleNames(event), want)
+	}
+	if event.Severity != "CRITICAL" {
+		t.Errorf("severity = %s, want collector's CRITICAL", event.Severity)
+	}
+}
+
+func TestProcessTwiceIsIdempotent(t *testing.T) {
+	lp := NewLogProcessor()
+	input := Event{
+		Source:    "bash_history",
+		EventType: "command",
+		Severity:  "INFO",
+		Command:   "sudo rm -rf / root",
+		RawLog:    "sudo rm -rf / root",
+	}
+
+	once, _ := lp.Process(input)
+	twice, _ := lp.Process(once)
+	if len(once.RuleHits) != 2 {
+		t.Fatalf("first pass recorded %d hits, want 2", len(once.RuleHits))
+	}
+	if !reflect.DeepEqual(once, twice) {
+		t.Errorf("second pass changed the event:\nfirst:  %+v\nsecond: %+v", once, twice)
+	}
+}
+
+func TestDetectAnomalyActions(t *testing.T) {
+	alert := func(name, severity string) AnomalyRule {
+		return AnomalyRule{
+			Name:      name,
+			Condition: mustCompileCondition(ConditionDefinition{Keywords: []string{"x"}}),
+			Severity:  severity,
+			EventType: name,
+			Action:    ActionAlert,
+		}
+	}
+	stop := func(action string) AnomalyRule {
+		return AnomalyRule{Name: action, Condition: mustCompileCondition(ConditionDefinition{Keywords: []string{"x"}}), Action: action}
+	}
+
+	tests := []struct {
+		name  string
+		rules []AnomalyRule
+		keep  bool
+		hits  []string
+	}{
+		{"all alerts", []AnomalyRule{alert("a", "WARNING"), alert("b", "INFO")}, true, []string{"a", "b"}},
+		{"pass stops", []AnomalyRule{alert("a", "WARNING"), stop(ActionPass), alert("b", "CRITICAL")}, true, []string{"a"}},
+		{"drop discards", []AnomalyRule{alert("a", "WARNING"), stop(ActionDrop)}, false, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := &LogProcessor{}
+			event := Event{EventType: "original", Severity: "INFO", RawLog: "x"}
+			if keep := lp.detectAnomaly(&event, tt.rules); keep != tt.keep {
+				t.Errorf("keep = %v, want %v", keep, tt.keep)
+			}
+			if !reflect.DeepEqual(ruleNames(event), tt.hits) {
+				t.Errorf("hits = %v, want %v", ruleNames(event), tt.hits)
+			}
+			if event.EventType != "original" {
+				t.Errorf("event_type changed to %s", event.EventType)
+			}
+		})
+	}
+}
+
+func TestAlertFiltersNeverLowerSeverity(t *testing.T) {
+	filter := func(name, severity string) Filter {
+		return Filter{
+			Name:      name,
+			Condition: mustCompileCondition(ConditionDefinition{Keywords: []string{"x"}}),
+			Severity:  severity,
+			EventType: name,
+			Action:    ActionAlert,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		severity string
+		filters  []Filter
+		want     string
+		hits     []string
+	}{
+		{"raises to highest", "INFO", []Filter{filter("high", "CRITICAL"), filter("low", "INFO")}, "CRITICAL", []string{"high", "low"}},
+		{"later low filter", "INFO", []Filter{filter("low", "INFO"), filter("mid", "WARNING")}, "WARNING", []string{"low", "mid"}},
+		{"keeps collector severity", "critical", []Filter{filter("mid", "WARNING")}, "critical", []string{"mid"}},
+		{"pass stops", "INFO", []Filter{filter("low", "INFO"), {Condition: mustCompileCondition(ConditionDefinition{Keywords: []string{"x"}}), Action: ActionPass}, filter("high", "CRITICAL")}, "INFO", []string{"low"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := &LogProcessor{}
+			event := Event{EventType: "original", Severity: tt.severity, RawLog: "x"}
+			if !lp.shouldProcess(&event, tt.filters) {
+				t.Fatal("event dropped")
+			}
+			if event.Severity != tt.want {
+				t.Errorf("severity = %s, want %s", event.Severity, tt.want)
+			}
+			if !reflect.DeepEqual(ruleNames(event), tt.hits) {
+				t.Errorf("hits = %v, want %v", ruleNames(event), tt.hits)
+			}
+			if event.EventType != "original" {
+				t.Errorf("event_type changed to %s", event.EventType)
+			}
+		})
+	}
+}
+
+func parseConditionYAML(t *testing.T, text string) ConditionDefinition {
+	t.Helper()
+	var def ConditionDefinition
+	if err := yaml.Unmarshal([]byte(text), &def); err != nil {
+		t.Fatalf("yaml %q: %v", text, err)
+	}
+	return def
+}
+
+func TestConditionOperators(t *testing.T) {
+	event := Event{
+		Source:    "authlog",
+		EventType: "ssh_login_failed",
+		User:      "root",
+		Command:   "sudo rm -rf /",
+		RawLog:    "Failed password for root",
+		Fields: map[string]string{
+			"src_ip":     "203.0.113.7",
+			"src_ip6":    "2001:db8::15",
+			"mapped_ip":  "::ffff:10.1.2.3",
+			"src_port":   "40000",
+			"bytes":      " 1536.5 ",
+			"tty":        "pts/0",
+			"not_number": "12abc",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+	}{
+		{"cidr v4", "{field: fields.src_ip, cidr: [10.0.0.0/8, 203.0.113.0/24]}", true},
+		{"cidr v4 outside", "{field: fields.src_ip, cidr: [10.0.0.0/8]}", false},
+		{"cidr single address", "{field: fields.src_ip, cidr: [203.0.113.7]}", true},
+		{"cidr v6", "{field: fields.src_ip6, cidr: ['2001:db8::/32']}", true},
+		{"cidr mapped v4", "{field: fields.mapped_ip, cidr: [10.0.0.0/8]}", true},
+		{"cidr non-ip value", "{field: fields.tty, cidr: [0.0.0.0/0]}", false},
+		{"cidr missing field", "{field: fields.dst_ip, cidr: [0.0.0.0/0]}", false},
+
+		{"gt", "{field: fields.src_port, gt: 1024}", true},
+		{"gt equal", "{field: fields.src_port, gt: 40000}", false},
+		{"gte equal", "{field: fields.src_port, gte: 40000}", true},
+		{"lt", "{field: fields.src_port, lt: 1024}", false},
+		{"lte equal", "{field: fields.src_port, lte: 40000}", true},
+		{"range with spaces and fraction", "{field: fields.bytes, gt: 1024, lte: 2048}", true},
+		{"range outside", "{field: fields.bytes, gte: 0, lt: 1000}", false},
+		{"numeric on text", "{field: user, gte: 0}", false},
+		{"numeric on partly numeric", "{field: fields.not_number, gt: 0}", false},
+		{"numeric on missing field", "{field: fields.count, lt: 100}", false},
+
+		{"in", "{field: user, in: [root, admin]}", true},
+		{"in is exact", "{field: user, in: [ROOT, roo]}", false},
+		{"equals", "{field: event_type, equals: ssh_login_failed}", true},
+		{"equals empty matches missing field", "{field: fields.dst_ip, equals: ''}", true},
+
+		{"not", "{not: {field: user, in: [alice]}}", true},
+		{"not matching", "{not: {field: fields.src_ip, cidr: [203.0.113.0/24]}}", false},
+		{"double not", "{not: {not: {field: user, equals: root}}}", true},
+		{"all", "{all: [{field: user, equals: root}, {field: fields.src_port, gt: 1024}, {not: {field: fields.src_ip, cidr: [10.0.0.0/8]}}]}", true},
+		{"all one fails", "{all: [{field: user, equals: root}, {field: fields.src_port, lt: 1024}]}", false},
+		{"any", "{any: [{field: user, equals: alice}, {field: command, regex: 'rm\\s+-rf'}]}", true},
+		{"any none", "{any: [{field: user, equals: alice}, {keywords: [accepted]}]}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, err := compileCondition(parseConditionYAML(t, tt.condition))
+			if err != nil {
+				t.Fatalf("compileCondition: %v", err)
+			}
+			if got := condition.Match(event); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		want      string
+	}{
+		{"no operator", "{field: user}", "is required"},
+		{"two operators", "{field: user, equals: root, in: [admin]}", "equals, in are mutually exclusive"},
+		{"regex and keywords", "{regex: a, keywords: [b]}", "regex, keywords are mutually exclusive"},
+		{"cidr and range", "{field: fields.src_ip, cidr: [10.0.0.0/8], gt: 1}", "cidr, gt/gte/lt/lte are mutually exclusive"},
+		{"invalid cidr", "{field: fields.src_ip, cidr: [10.0.0.0/33]}", `invalid cidr "10.0.0.0/33"`},
+		{"invalid address", "{field: fields.src_ip, cidr: [not-an-ip]}", `invalid cidr "not-an-ip"`},
+		{"empty cidr", "{field: fields.src_ip, cidr: []}", "cidr must not be empty"},
+		{"empty in", "{field: user, in: []}", "in must not be empty"},
+		{"empty keyword", "{keywords: ['']}", "keywords must not be empty"},
+		{"invalid regex", "{regex: '('}", "invalid regex"},
+		{"unknown field", "{field: src_ip, equals: x}", `unknown field "src_ip"`},
+		{"bare fields prefix", "{field: 'fields.', equals: x}", "unknown field"},
+		{"two combinators", "{all: [{equals: x}], any: [{equals: y}]}", "mutually exclusive"},
+		{"combinator with operator", "{not: {equals: x}, equals: y}", "cannot be combined"},
+		{"combinator with field", "{field: user, all: [{equals: x}]}", "cannot be combined"},
+		{"empty all", "{all: []}", "all must not be empty"},
+		{"nested error", "{any: [{equals: x}, {field: user}]}", "any[1]: "},
+		{"not error", "{not: {field: user, equals: x, regex: y}}", "not: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileCondition(parseConditionYAML(t, tt.condition))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
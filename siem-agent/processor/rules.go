@@ -0,0 +1,282 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Действия правил и фильтров. Фильтры и правила обнаружения проверяются
+// по порядку до pass или drop
+const (
+	ActionAlert = "alert" // добавить RuleHit и повысить severity
+	ActionDrop  = "drop"  // отбросить событие
+	ActionPass  = "pass"  // оставить событие как есть и не проверять остальные
+)
+
+// FieldRawLog поле события, по которому правила проверяются по умолчанию
+const FieldRawLog = "raw_log"
+
+// fieldsPrefix префикс дополнительных полей события: fields.src_ip
+const fieldsPrefix = "fields."
+
+// eventFieldNames поля события, доступные правилам
+var eventFieldNames = map[string]bool{
+	"timestamp": true, "hostname": true, "source": true, "event_type": true, "severity": true,
+	"user": true, "process": true, "command": true, FieldRawLog: true,
+}
+
+// RuleFile файл правил в каталоге rules_dir
+type RuleFile struct {
+	Rules        []RuleDefinition        `yaml:"rules"`
+	Filters      []RuleDefinition        `yaml:"filters"`
+	Correlations []CorrelationDefinition `yaml:"correlations"`
+}
+
+// RuleDefinition описание правила обнаружения или фильтра. Условие
+// задаётся ключами ConditionDefinition на уровне правила
+type RuleDefinition struct {
+	Name                string `yaml:"name"`
+	ConditionDefinition `yaml:",inline"`
+	Severity            string `yaml:"severity"`
+	EventType           string `yaml:"event_type"`
+	// Action alert, drop или pass. Для правил по умолчанию alert
+	Action string `yaml:"action"`
+}
+
+// RuleSet правила, загруженные из каталога
+type RuleSet struct {
+	Rules        []AnomalyRule
+	Filters      []Filter
+	Sigma        []*SigmaRule
+	Correlations []*CorrelationRule
+}
+
+// LoadRuleDir читает и компилирует правила и фильтры из файлов *.yaml и *.yml
+// каталога и его подкаталогов. Файлы с ключом detection разбираются как
+// правила Sigma. Файлы читаются по алфавиту; ошибки всех правил возвращаются вместе
+func LoadRuleDir(dir string) (RuleSet, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return RuleSet{}, fmt.Errorf("failed to read rules dir: %w", err)
+	}
+	sort.Strings(files)
+
+	var set RuleSet
+	var errs []error
+	names := make(map[string]string) // имя -> файл, где оно объявлено
+	sigmaIDs := make(map[string]string)
+
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read rules file: %w", err))
+			continue
+		}
+
+		if isSigmaFile(data) {
+			rules, skipped, err := parseSigmaFile(path, data)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			for _, skip := range skipped {
+				log.Printf("[Processor] Skipping Sigma rule %q from %s: %v", skip.Title, path, skip.Reason)
+			}
+			for _, rule := range rules {
+				if previous, exists := sigmaIDs[rule.ID]; exists {
+					errs = append(errs, fmt.Errorf("%s: sigma rule %q: duplicate id %s, already defined in %s", path, rule.Title, rule.ID, previous))
+					continue
+				}
+				sigmaIDs[rule.ID] = path
+				set.Sigma = append(set.Sigma, rule)
+			}
+			continue
+		}
+
+		file, err := parseRuleFile(path, data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for i, def := range file.Rules {
+			where := fmt.Sprintf("%s: rules[%d]", path, i)
+			rule, err := compileRule(def)
+			if err == nil {
+				err = checkRuleName(names, def.Name, path)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %q: %w", where, def.Name, err))
+				continue
+			}
+			set.Rules = append(set.Rules, rule)
+		}
+
+		for i, def := range file.Filters {
+			where := fmt.Sprintf("%s: filters[%d]", path, i)
+			filter, err := compileFilter(def)
+			if err == nil {
+				err = checkRuleName(names, def.Name, path)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %q: %w", where, def.Name, err))
+				continue
+			}
+			set.Filters = append(set.Filters, filter)
+		}
+
+		for i, def := range file.Correlations {
+			where := fmt.Sprintf("%s: correlations[%d]", path, i)
+			rule, err := compileCorrelation(def)
+			if err == nil {
+				err = checkRuleName(names, def.Name, path)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %q: %w", where, def.Name, err))
+				continue
+			}
+			set.Correlations = append(set.Correlations, rule)
+		}
+	}
+
+	if len(errs) > 0 {
+		return RuleSet{}, errors.Join(errs...)
+	}
+	return set, nil
+}
+
+// parseRuleFile разбирает файл правил. Неизвестные ключи — ошибка, чтобы
+// опечатка не превращала правило в срабатывающее на всё
+func parseRuleFile(path string, data []byte) (RuleFile, error) {
+	var file RuleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return RuleFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// checkRuleName проверяет, что имя не занято другим правилом или фильтром
+func checkRuleName(names map[string]string, name, path string) error {
+	if previous, exists := names[name]; exists {
+		return fmt.Errorf("duplicate name, already defined in %s", previous)
+	}
+	names[name] = path
+	return nil
+}
+
+// compileRule проверяет описание правила обнаружения
+func compileRule(def RuleDefinition) (AnomalyRule, error) {
+	if def.Action == "" {
+		def.Action = ActionAlert
+	}
+	condition, err := compileMatch(def)
+	if err != nil {
+		return AnomalyRule{}, err
+	}
+
+	return AnomalyRule{
+		Name:      def.Name,
+		Condition: condition,
+		Severity:  strings.ToUpper(def.Severity),
+		EventType: strings.ToLower(def.EventType),
+		Action:    def.Action,
+	}, nil
+}
+
+// compileFilter проверяет описание фильтра
+func compileFilter(def RuleDefinition) (Filter, error) {
+	if def.Action == "" {
+		return Filter{}, fmt.Errorf("action is required for filters")
+	}
+	condition, err := compileMatch(def)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	return Filter{
+		Name:      def.Name,
+		Condition: condition,
+		Severity:  strings.ToUpper(def.Severity),
+		EventType: strings.ToLower(def.EventType),
+		Action:    def.Action,
+	}, nil
+}
+
+// compileMatch проверяет общие поля описания и компилирует условие
+func compileMatch(def RuleDefinition) (Condition, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	switch def.Action {
+	case ActionAlert:
+		if !isValidSeverity(strings.ToUpper(def.Severity)) {
+			return nil, fmt.Errorf("severity must be INFO, WARNING or CRITICAL, got %q", def.Severity)
+		}
+		if def.EventType == "" {
+			return nil, fmt.Errorf("event_type is required for action %q", ActionAlert)
+		}
+	case ActionDrop, ActionPass:
+	default:
+		return nil, fmt.Errorf("action must be %q, %q or %q, got %q", ActionAlert, ActionDrop, ActionPass, def.Action)
+	}
+
+	return compileCondition(def.ConditionDefinition)
+}
+
+// validateFieldName проверяет имя поля события в правиле. Пустое имя — raw_log
+func validateFieldName(field string) error {
+	if field == "" || eventFieldNames[field] {
+		return nil
+	}
+	if !strings.HasPrefix(field, fieldsPrefix) || field == fieldsPrefix {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	return nil
+}
+
+// eventField возвращает значение поля события по имени из правила
+func eventField(event Event, field string) string {
+	switch field {
+	case "", FieldRawLog:
+		return event.RawLog
+	case "timestamp":
+		return event.Timestamp
+	case "hostname":
+		return event.Hostname
+	case "source":
+		return event.Source
+	case "event_type":
+		return event.EventType
+	case "severity":
+		return event.Severity
+	case "user":
+		return event.User
+	case "process":
+		return event.Process
+	case "command":
+		return event.Command
+	}
+	return event.Fields[strings.TrimPrefix(field, fieldsPrefix)]
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ruleHitsFile = `
+filters:
+  - name: Sudo_Usage
+    field: command
+    keywords: [sudo]
+    severity: warning
+    event_type: sudo_usage
+    action: alert
+
+rules:
+  - name: Root_Target
+    field: command
+    keywords: ["-u root"]
+    severity: INFO
+    event_type: root_target
+  - name: Shell_Spawn
+    field: command
+    regex: '/bin/(ba)?sh$'
+    severity: CRITICAL
+    event_type: shell_spawn
+`
+
+const ruleHitsSigma = `
+title: Sudo Invocation
+id: 5b0a8c1e-2f7d-4e63-9a14-c3d2e1f0a9b8
+logsource:
+    product: linux
+detection:
+    keywords:
+        - 'sudo'
+    condition: keywords
+level: low
+`
+
+func writeRuleDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadedRulesAccumulateHits(t *testing.T) {
+	lp := NewLogProcessor()
+	dir := writeRuleDir(t, map[string]string{"local.yaml": ruleHitsFile, "sudo.yml": ruleHitsSigma})
+	if err := lp.LoadRules(dir); err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		severity string
+		hits     []string
+	}{
+		// Фильтр, затем правила в порядке файла; серьёзность наивысшая
+		{"filter and both rules", "sudo -u root /bin/bash", "CRITICAL", []string{"Sudo_Usage", "Root_Target", "Shell_Spawn"}},
+		// Правило INFO и Sigma уровня low не понижают WARNING фильтра
+		{"low rules keep filter severity", "sudo -u root id", "WARNING", []string{"Sudo_Usage", "Root_Target"}},
+		{"no hits", "ls -la", "INFO", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := lp.Process(Event{
+				Source:    "bash_history",
+				EventType: "command_execution",
+				Severity:  "INFO",
+				Command:   tt.command,
+				RawLog:    tt.command,
+			})
+			if !ok {
+				t.Fatal("event dropped")
+			}
+			if !reflect.DeepEqual(ruleNames(event), tt.hits) {
+				t.Errorf("hits = %v, want %v", ruleNames(event), tt.hits)
+			}
+			if event.Severity != tt.severity {
+				t.Errorf("severity = %s, want %s", event.Severity, tt.severity)
+			}
+			if event.EventType != "command_execution" {
+				t.Errorf("event_type = %s, want original", event.EventType)
+			}
+			if sudo := strings.HasPrefix(tt.command, "sudo"); sudo != (event.Fields["sigma_title"] == "Sudo Invocation") {
+				t.Errorf("sigma_title = %q", event.Fields["sigma_title"])
+			}
+		})
+	}
+}
+
+func TestLoadRuleDirErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "rules:\n  - name: A\n    regex: x\n    severity: INFO\n    event_type: a\n    serverity: INFO\n", "serverity"},
+		{"bad regex", "rules:\n  - name: A\n    regex: '('\n    severity: INFO\n    event_type: a\n", `"A"`},
+		{"bad severity", "rules:\n  - name: A\n    regex: x\n    severity: HIGH\n    event_type: a\n", "severity"},
+		{"duplicate name", "rules:\n  - name: A\n    regex: x\n    severity: INFO\n    event_type: a\nfilters:\n  - name: A\n    regex: y\n    action: drop\n", "duplicate name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRuleDir(writeRuleDir(t, map[string]string{"rules.yaml": tt.content}))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want mention of %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRulesKeepsPreviousOnError(t *testing.T) {
+	lp := NewLogProcessor()
+	dir := writeRuleDir(t, map[string]string{"local.yaml": ruleHitsFile})
+	if err := lp.LoadRules(dir); err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("rules: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.LoadRules(dir); err == nil {
+		t.Fatal("expected error for broken file")
+	}
+	event, _ := lp.Process(Event{Source: "bash_history", Severity: "INFO", Command: "sudo id", RawLog: "sudo id"})
+	if want := []string{"Sudo_Usage"}; !reflect.DeepEqual(ruleNames(event), want) {
+		t.Errorf("hits after failed reload = %v, want %v", ruleNames(event), want)
+	}
+}
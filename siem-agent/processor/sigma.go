@@ -0,0 +1,795 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sigmaFile правило Sigma (https://sigmahq.io). Поля, которые агент не
+// использует (description, references, falsepositives...), игнорируются
+type sigmaFile struct {
+	Title     string               `yaml:"title"`
+	ID        string               `yaml:"id"`
+	Level     string               `yaml:"level"`
+	Tags      []string             `yaml:"tags"`
+	Logsource sigmaLogsource       `yaml:"logsource"`
+	Detection map[string]yaml.Node `yaml:"detection"`
+}
+
+// sigmaLogsource описание источника правила Sigma
+type sigmaLogsource struct {
+	Product  string `yaml:"product"`
+	Service  string `yaml:"service"`
+	Category string `yaml:"category"`
+}
+
+// sigmaTarget источник агента, к событиям которого применяется правило.
+// Пустые значения подходят под любые
+type sigmaTarget struct {
+	source    string
+	eventType string
+	process   string
+}
+
+// sigmaServices соответствие logsource.service источникам агента
+var sigmaServices = map[string][]sigmaTarget{
+	"auditd": {{source: "audit"}},
+	"auth":   {{source: "authlog"}},
+	"sshd":   {{source: "authlog", process: "sshd"}, {source: "journald", process: "sshd"}},
+	"sudo":   {{source: "authlog", process: "sudo"}, {source: "journald", process: "sudo"}},
+	"su":     {{source: "authlog", process: "su"}, {source: "journald", process: "su"}},
+	"syslog": {{source: "syslog"}, {source: "syslog_listener"}, {source: "journald"}},
+	"cron":   {{source: "syslog", process: "cron"}, {source: "journald", process: "cron"}},
+}
+
+// sigmaCategories соответствие logsource.category источникам агента
+var sigmaCategories = map[string][]sigmaTarget{
+	"process_creation": {
+		{source: "process", eventType: "process_started"},
+		{source: "bash_history"}, {source: "zsh_history"}, {source: "fish_history"},
+	},
+	"network_connection": {{source: "network", eventType: "connection_established"}},
+	"file_event":         {{source: "fim"}},
+	"file_change":        {{source: "fim", eventType: "file_modified"}},
+	"file_delete":        {{source: "fim", eventType: "file_deleted"}},
+}
+
+// sigmaFields соответствие полей таксономии Sigma полям события
+var sigmaFields = map[string]string{
+	"CommandLine":      "command",
+	"Image":            "fields.exe",
+	"ParentImage":      "fields.parent_exe",
+	"ProcessId":        "fields.pid",
+	"ParentProcessId":  "fields.ppid",
+	"CurrentDirectory": "fields.cwd",
+	"User":             "user",
+	"TargetFilename":   "fields.path",
+	"SourceIp":         "fields.src_ip",
+	"SourcePort":       "fields.src_port",
+	"DestinationIp":    "fields.remote_ip",
+	"DestinationPort":  "fields.remote_port",
+	"Hostname":         "hostname",
+	"ComputerName":     "hostname",
+	"type":             "event_type", // auditd: type: 'EXECVE'
+}
+
+// errSigmaUnsupported возможность Sigma, которой у агента нет. Такие правила
+// пропускаются с предупреждением, остальные правила каталога загружаются
+var errSigmaUnsupported = errors.New("unsupported")
+
+// sigmaSkip правило, пропущенное при загрузке, и причина
+type sigmaSkip struct {
+	Title  string
+	Reason error
+}
+
+// sigmaLevels уровни Sigma в порядке возрастания
+var sigmaLevels = []string{"informational", "low", "medium", "high", "critical"}
+
+// SigmaRule скомпилированное правило Sigma
+type SigmaRule struct {
+	ID    string
+	Title string
+	Level string
+	Tags  []string // теги ATT&CK (attack.*)
+
+	targets   []sigmaTarget // nil — любые источники
+	condition sigmaExpr
+}
+
+// Matches проверяет, подходит ли событие под источник и условие правила
+func (r *SigmaRule) Matches(event Event) bool {
+	if r.targets != nil {
+		applicable := false
+		for _, target := range r.targets {
+			if target.matches(event) {
+				applicable = true
+				break
+			}
+		}
+		if !applicable {
+			return false
+		}
+	}
+	return r.condition.eval(event)
+}
+
+// matches проверяет, относится ли событие к источнику
+func (t sigmaTarget) matches(event Event) bool {
+	if t.source != "" && event.Source != t.source {
+		return false
+	}
+	if t.eventType != "" && !strings.EqualFold(event.EventType, t.eventType) {
+		return false
+	}
+	return t.process == "" || strings.EqualFold(event.Process, t.process)
+}
+
+// severity переводит уровень Sigma в уровень серьёзности события
+func (r *SigmaRule) severity() string {
+	switch r.Level {
+	case "medium":
+		return "WARNING"
+	case "high", "critical":
+		return "CRITICAL"
+	default:
+		return "INFO"
+	}
+}
+
+// matchSigma возвращает правила Sigma, под которые подходит событие
+func matchSigma(event Event, rules []*SigmaRule) []*SigmaRule {
+	var hits []*SigmaRule
+	for _, rule := range rules {
+		if rule.Matches(event) {
+			hits = append(hits, rule)
+		}
+	}
+	return hits
+}
+
+// tagSigma помечает событие сработавшими правилами: sigma_rule_id,
+// sigma_title, sigma_level (наивысший) и sigma_tags (теги ATT&CK).
+// Уровень серьёзности повышается до уровня правила, но не понижается
+func tagSigma(event *Event, hits []*SigmaRule) {
+	if len(hits) == 0 {
+		return
+	}
+	if event.Fields == nil {
+		event.Fields = make(map[string]string)
+	}
+
+	var ids, titles, tags []string
+	seenTags := make(map[string]bool)
+	top := hits[0]
+	for _, rule := range hits {
+		ids = append(ids, rule.ID)
+		titles = append(titles, rule.Title)
+		for _, tag := range rule.Tags {
+			if !seenTags[tag] {
+				seenTags[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		if sigmaLevelRank(rule.Level) > sigmaLevelRank(top.Level) {
+			top = rule
+		}
+	}
+	sort.Strings(tags)
+
+	event.Fields["sigma_rule_id"] = strings.Join(ids, ",")
+	event.Fields["sigma_title"] = strings.Join(titles, "; ")
+	event.Fields["sigma_level"] = top.Level
+	if len(tags) > 0 {
+		event.Fields["sigma_tags"] = strings.Join(tags, ",")
+	}
+	raiseSeverity(event, top.severity())
+}
+
+// raiseSeverity повышает серьёзность события до severity, но не понижает.
+// Общее правило для фильтров, правил обнаружения и Sigma
+func raiseSeverity(event *Event, severity string) {
+	if severityRank(severity) > severityRank(event.Severity) {
+		event.Severity = severity
+	}
+}
+
+// severityRank порядок уровней серьёзности события. Фильтры видят уровень
+// до нормализации, поэтому регистр не важен
+func severityRank(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return 2
+	case "WARNING":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// isSigmaFile проверяет, что первый документ файла — правило Sigma
+func isSigmaFile(data []byte) bool {
+	var probe map[string]interface{}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, ok := probe["detection"]
+	return ok
+}
+
+// parseSigmaFile компилирует правила Sigma из файла. Файл может содержать
+// несколько документов. Правила для источников, которых у агента нет, или
+// с неподдерживаемыми модификаторами пропускаются и попадают в skipped
+func parseSigmaFile(path string, data []byte) (rules []*SigmaRule, skipped []sigmaSkip, err error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var errs []error
+	for {
+		var file sigmaFile
+		if err := decoder.Decode(&file); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if file.Detection == nil {
+			continue
+		}
+
+		rule, err := compileSigmaRule(file)
+		switch {
+		case errors.Is(err, errSigmaUnsupported):
+			skipped = append(skipped, sigmaSkip{Title: file.Title, Reason: err})
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: sigma rule %q: %w", path, file.Title, err))
+		default:
+			rules = append(rules, rule)
+		}
+	}
+	return rules, skipped, errors.Join(errs...)
+}
+
+// compileSigmaRule проверяет правило и компилирует его условие. Ошибка
+// errSigmaUnsupported — правило для источника или с возможностями, которых
+// у агента нет
+func compileSigmaRule(file sigmaFile) (*SigmaRule, error) {
+	if file.Title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	level := strings.ToLower(file.Level)
+	if level == "" {
+		level = "medium"
+	}
+	if sigmaLevelRank(level) < 0 {
+		return nil, fmt.Errorf("unknown level %q", file.Level)
+	}
+
+	targets, supported := sigmaTargets(file.Logsource)
+	if !supported {
+		return nil, fmt.Errorf("%w logsource", errSigmaUnsupported)
+	}
+
+	conditionNode, ok := file.Detection["condition"]
+	if !ok {
+		return nil, fmt.Errorf("detection.condition is required")
+	}
+	var conditions []string
+	switch conditionNode.Kind {
+	case yaml.ScalarNode:
+		conditions = []string{conditionNode.Value}
+	case yaml.SequenceNode:
+		if err := conditionNode.Decode(&conditions); err != nil {
+			return nil, fmt.Errorf("detection.condition: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("detection.condition must be a string or a list")
+	}
+
+	searches := make(map[string]sigmaExpr)
+	for name, node := range file.Detection {
+		if name == "condition" || name == "timeframe" {
+			continue
+		}
+		search, err := compileSigmaSearch(&node)
+		if err != nil {
+			return nil, fmt.Errorf("detection.%s: %w", name, err)
+		}
+		searches[name] = search
+	}
+
+	// Несколько условий эквивалентны их объединению через or
+	var alternatives sigmaAny
+	for _, condition := range conditions {
+		expr, err := parseSigmaCondition(condition, searches)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", condition, err)
+		}
+		alternatives = append(alternatives, expr)
+	}
+
+	rule := &SigmaRule{
+		ID:        file.ID,
+		Title:     file.Title,
+		Level:     level,
+		targets:   targets,
+		condition: alternatives,
+	}
+	if rule.ID == "" {
+		rule.ID = file.Title
+	}
+	for _, tag := range file.Tags {
+		if strings.HasPrefix(strings.ToLower(tag), "attack.") {
+			rule.Tags = append(rule.Tags, strings.ToLower(tag))
+		}
+	}
+	if len(alternatives) == 1 {
+		rule.condition = alternatives[0]
+	}
+	return rule, nil
+}
+
+// sigmaTargets определяет источники агента по logsource. false — источник
+// не поддерживается (другая ОС или неизвестная служба)
+func sigmaTargets(ls sigmaLogsource) ([]sigmaTarget, bool) {
+	product := strings.ToLower(ls.Product)
+	if product != "" && product != "linux" {
+		return nil, false
+	}
+
+	var targets []sigmaTarget
+	if ls.Service != "" {
+		service, ok := sigmaServices[strings.ToLower(ls.Service)]
+		if !ok {
+			return nil, false
+		}
+		targets = service
+	}
+	if ls.Category != "" {
+		category, ok := sigmaCategories[strings.ToLower(ls.Category)]
+		if !ok {
+			return nil, false
+		}
+		if targets == nil {
+			targets = category
+		} else {
+			targets = intersectSigmaTargets(targets, category)
+		}
+	}
+	if ls.Service != "" && ls.Category != "" && len(targets) == 0 {
+		return nil, false
+	}
+	// product: linux без service и category — все события агента
+	return targets, true
+}
+
+// intersectSigmaTargets оставляет источники, общие для службы и категории
+func intersectSigmaTargets(service, category []sigmaTarget) []sigmaTarget {
+	var result []sigmaTarget
+	for _, s := range service {
+		for _, c := range category {
+			if s.source == c.source {
+				merged := s
+				if merged.eventType == "" {
+					merged.eventType = c.eventType
+				}
+				result = append(result, merged)
+			}
+		}
+	}
+	return result
+}
+
+// sigmaLevelRank номер уровня Sigma, -1 для неизвестного
+func sigmaLevelRank(level string) int {
+	for i, known := range sigmaLevels {
+		if level == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// sigmaExpr узел условия правила Sigma
+type sigmaExpr interface {
+	eval(event Event) bool
+}
+
+// sigmaAll истинно, если истинны все подвыражения (and, all of, поля выборки)
+type sigmaAll []sigmaExpr
+
+func (e sigmaAll) eval(event Event) bool {
+	for _, expr := range e {
+		if !expr.eval(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// sigmaAny истинно, если истинно хотя бы одно подвыражение (or, 1 of, списки)
+type sigmaAny []sigmaExpr
+
+func (e sigmaAny) eval(event Event) bool {
+	for _, expr := range e {
+		if expr.eval(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// sigmaNot отрицание
+type sigmaNot struct {
+	expr sigmaExpr
+}
+
+func (e sigmaNot) eval(event Event) bool {
+	return !e.expr.eval(event)
+}
+
+// sigmaFieldMatch проверка одного поля: значение подходит под любой из
+// шаблонов, а с модификатором all — под все
+type sigmaFieldMatch struct {
+	field    string // поле события в нотации правил агента
+	patterns []*regexp.Regexp
+	all      bool
+	null     bool // поле должно отсутствовать или быть пустым
+}
+
+func (m sigmaFieldMatch) eval(event Event) bool {
+	value := sigmaEventField(event, m.field)
+	if m.null {
+		return value == ""
+	}
+	if m.all {
+		for _, pattern := range m.patterns {
+			if !pattern.MatchString(value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSigmaSearch компилирует выборку detection: словарь полей (and),
+// список словарей (or) или список ключевых слов (or по сообщению)
+func compileSigmaSearch(node *yaml.Node) (sigmaExpr, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return compileSigmaMap(node)
+	case yaml.SequenceNode:
+		var alternatives sigmaAny
+		for _, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				expr, err := compileSigmaMap(item)
+				if err != nil {
+					return nil, err
+				}
+				alternatives = append(alternatives, expr)
+				continue
+			}
+			expr, err := compileSigmaKeyword(item)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, expr)
+		}
+		return alternatives, nil
+	case yaml.ScalarNode:
+		return compileSigmaKeyword(node)
+	default:
+		return nil, fmt.Errorf("unsupported search definition")
+	}
+}
+
+// compileSigmaKeyword ключевое слово: подстрока сообщения
+func compileSigmaKeyword(node *yaml.Node) (sigmaExpr, error) {
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("keyword must be a string")
+	}
+	pattern, err := sigmaWildcard("*" + node.Value + "*")
+	if err != nil {
+		return nil, err
+	}
+	return sigmaFieldMatch{field: FieldRawLog, patterns: []*regexp.Regexp{pattern}}, nil
+}
+
+// compileSigmaMap компилирует словарь "поле|модификаторы: значения"
+func compileSigmaMap(node *yaml.Node) (sigmaExpr, error) {
+	var fields sigmaAll
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+
+		parts := strings.Split(key, "|")
+		match := sigmaFieldMatch{field: sigmaFieldName(parts[0])}
+		var transform func(string) string
+		useRegex := false
+		for _, modifier := range parts[1:] {
+			switch modifier {
+			case "contains":
+				transform = func(v string) string { return "*" + v + "*" }
+			case "startswith":
+				transform = func(v string) string { return v + "*" }
+			case "endswith":
+				transform = func(v string) string { return "*" + v }
+			case "re":
+				useRegex = true
+			case "all":
+				match.all = true
+			default:
+				return nil, fmt.Errorf("%s: %w modifier %q", key, errSigmaUnsupported, modifier)
+			}
+		}
+		if parts[0] == "" {
+			// Поле не указано: поиск по сообщению
+			match.field = FieldRawLog
+		}
+
+		var values []*yaml.Node
+		switch value.Kind {
+		case yaml.ScalarNode:
+			values = []*yaml.Node{value}
+		case yaml.SequenceNode:
+			values = value.Content
+		default:
+			return nil, fmt.Errorf("%s: value must be a scalar or a list", key)
+		}
+
+		for _, item := range values {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s: value must be a scalar", key)
+			}
+			if item.Tag == "!!null" {
+				match.null = true
+				continue
+			}
+
+			var pattern *regexp.Regexp
+			var err error
+			switch {
+			case useRegex:
+				pattern, err = regexp.Compile(item.Value)
+			case transform != nil:
+				pattern, err = sigmaWildcard(transform(item.Value))
+			default:
+				pattern, err = sigmaWildcard(item.Value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			match.patterns = append(match.patterns, pattern)
+		}
+		if match.null && len(match.patterns) > 0 {
+			return nil, fmt.Errorf("%s: null cannot be combined with other values", key)
+		}
+
+		fields = append(fields, match)
+	}
+	return fields, nil
+}
+
+// sigmaFieldName переводит имя поля Sigma в имя поля события
+func sigmaFieldName(name string) string {
+	if mapped, ok := sigmaFields[name]; ok {
+		return mapped
+	}
+	if lower := strings.ToLower(name); eventFieldNames[lower] {
+		return lower
+	}
+	return fieldsPrefix + name
+}
+
+// sigmaEventField возвращает значение поля. Дополнительные поля ищутся
+// также в нижнем регистре: у коллекторов ключи в snake_case
+func sigmaEventField(event Event, field string) string {
+	value := eventField(event, field)
+	if value == "" && strings.HasPrefix(field, fieldsPrefix) {
+		value = event.Fields[strings.ToLower(strings.TrimPrefix(field, fieldsPrefix))]
+	}
+	return value
+}
+
+// sigmaWildcard компилирует значение Sigma: * — любая строка, ? — любой
+// символ, \ экранирует их. Сравнение без учёта регистра по всему значению
+func sigmaWildcard(value string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(value) && (value[i+1] == '*' || value[i+1] == '?' || value[i+1] == '\\') {
+				i++
+				b.WriteString(regexp.QuoteMeta(value[i : i+1]))
+			} else {
+				b.WriteString(`\\`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// parseSigmaCondition разбирает условие: and, or, not, скобки,
+// "1 of selection*", "all of them"
+func parseSigmaCondition(condition string, searches map[string]sigmaExpr) (sigmaExpr, error) {
+	if strings.Contains(condition, "|") {
+		return nil, fmt.Errorf("%w aggregation", errSigmaUnsupported)
+	}
+
+	p := &sigmaParser{tokens: tokenizeSigmaCondition(condition), searches: searches}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+// tokenizeSigmaCondition разбивает условие на слова и скобки
+func tokenizeSigmaCondition(condition string) []string {
+	condition = strings.ReplaceAll(condition, "(", " ( ")
+	condition = strings.ReplaceAll(condition, ")", " ) ")
+	return strings.Fields(condition)
+}
+
+// sigmaParser разбор условия рекурсивным спуском
+type sigmaParser struct {
+	tokens   []string
+	pos      int
+	searches map[string]sigmaExpr
+}
+
+func (p *sigmaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *sigmaParser) parseOr() (sigmaExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	alternatives := sigmaAny{left}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, right)
+	}
+	if len(alternatives) == 1 {
+		return left, nil
+	}
+	return alternatives, nil
+}
+
+func (p *sigmaParser) parseAnd() (sigmaExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	all := sigmaAll{left}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, right)
+	}
+	if len(all) == 1 {
+		return left, nil
+	}
+	return all, nil
+}
+
+func (p *sigmaParser) parseNot() (sigmaExpr, error) {
+	if p.peek() == "not" {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return sigmaNot{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *sigmaParser) parsePrimary() (sigmaExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+
+	switch strings.ToLower(token) {
+	case "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	case ")", "and", "or":
+		return nil, fmt.Errorf("unexpected %q", token)
+	case "1", "any", "all":
+		if p.peek() != "of" {
+			return nil, fmt.Errorf("expected \"of\" after %q", token)
+		}
+		p.pos++
+		if p.pos >= len(p.tokens) {
+			return nil, fmt.Errorf("expected search pattern after \"of\"")
+		}
+		pattern := p.tokens[p.pos]
+		p.pos++
+
+		selected, err := p.selectSearches(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ToLower(token) == "all" {
+			return sigmaAll(selected), nil
+		}
+		return sigmaAny(selected), nil
+	}
+
+	search, ok := p.searches[token]
+	if !ok {
+		return nil, fmt.Errorf("unknown search %q", token)
+	}
+	return search, nil
+}
+
+// selectSearches выбирает выборки по шаблону имени ("selection*") или все ("them")
+func (p *sigmaParser) selectSearches(pattern string) ([]sigmaExpr, error) {
+	names := make([]string, 0, len(p.searches))
+	for name := range p.searches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var selected []sigmaExpr
+	for _, name := range names {
+		matched := pattern == "them"
+		if !matched {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				matched = strings.HasPrefix(name, prefix)
+			} else {
+				matched = name == pattern
+			}
+		}
+		if matched {
+			selected = append(selected, p.searches[name])
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no searches match %q", pattern)
+	}
+	return selected, nil
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sigmaSample пример события из testdata/sigma_events.yaml
+type sigmaSample struct {
+	Name  string `yaml:"name"`
+	Event struct {
+		Source    string            `yaml:"source"`
+		EventType string            `yaml:"event_type"`
+		User      string            `yaml:"user"`
+		Process   string            `yaml:"process"`
+		Command   string            `yaml:"command"`
+		RawLog    string            `yaml:"raw_log"`
+		Fields    map[string]string `yaml:"fields"`
+	} `yaml:"event"`
+	Match []string `yaml:"match"`
+}
+
+func (s sigmaSample) event() Event {
+	raw := s.Event.RawLog
+	if raw == "" {
+		raw = s.Event.Command
+	}
+	return Event{
+		Source:    s.Event.Source,
+		EventType: s.Event.EventType,
+		Severity:  "INFO",
+		User:      s.Event.User,
+		Process:   s.Event.Process,
+		Command:   s.Event.Command,
+		RawLog:    raw,
+		Fields:    s.Event.Fields,
+	}
+}
+
+func TestSigmaRulesCorpus(t *testing.T) {
+	set, err := LoadRuleDir(filepath.Join("..", "rules", "sigma"))
+	if err != nil {
+		t.Fatalf("LoadRuleDir: %v", err)
+	}
+	if len(set.Sigma) != 3 {
+		t.Fatalf("loaded %d Sigma rules, want 3", len(set.Sigma))
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "sigma_events.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []sigmaSample
+	if err := yaml.Unmarshal(data, &samples); err != nil {
+		t.Fatalf("testdata/sigma_events.yaml: %v", err)
+	}
+
+	for _, sample := range samples {
+		t.Run(sample.Name, func(t *testing.T) {
+			var ids []string
+			for _, rule := range matchSigma(sample.event(), set.Sigma) {
+				ids = append(ids, rule.ID)
+			}
+			if len(ids) == 0 && len(sample.Match) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(ids, sample.Match) {
+				t.Errorf("matched %v, want %v", ids, sample.Match)
+			}
+		})
+	}
+}
+
+func TestSigmaTagsProcessedEvent(t *testing.T) {
+	lp := NewLogProcessor()
+	set, err := LoadRuleDir(filepath.Join("..", "rules", "sigma"))
+	if err != nil {
+		t.Fatalf("LoadRuleDir: %v", err)
+	}
+	lp.sigmaRules = set.Sigma
+
+	// Правило аномалий Privilege_Escalation не мешает сработать правилу Sigma
+	event, _ := lp.Process(Event{
+		Source:    "authlog",
+		EventType: "sudo_execution",
+		Severity:  "INFO",
+		Process:   "sudo",
+		User:      "bob",
+		RawLog:    "sudo:      bob : TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/bin/bash",
+		Fields:    map[string]string{"target_user": "root"},
+	})
+	if !hasRuleHit(event, "Privilege_Escalation") {
+		t.Fatalf("rule hits = %v, want Privilege_Escalation", ruleNames(event))
+	}
+	if event.Fields["sigma_rule_id"] != "0f3c5d27-8e44-4b7a-a1c9-5d2f6e9b7c30" {
+		t.Errorf("sigma_rule_id = %q", event.Fields["sigma_rule_id"])
+	}
+	if event.EventType != "sudo_execution" {
+		t.Errorf("event_type = %s, want sudo_execution", event.EventType)
+	}
+}
+
+func TestParseSigmaFileSkipsUnsupported(t *testing.T) {
+	data := `title: CIDR Source
+logsource:
+    product: linux
+    service: sshd
+detection:
+    selection:
+        SourceIp|cidr: '10.0.0.0/8'
+    condition: selection
+---
+title: Base64 Command
+logsource:
+    category: process_creation
+detection:
+    selection:
+        CommandLine|base64offset|contains: 'curl'
+    condition: selection
+---
+title: Windows Process
+logsource:
+    product: windows
+    category: process_creation
+detection:
+    selection:
+        Image|endswith: '\cmd.exe'
+    condition: selection
+---
+title: Brute Force Count
+logsource:
+    service: sshd
+detection:
+    selection:
+        - 'Failed password'
+    condition: selection | count() by SourceIp > 10
+---
+title: Supported
+logsource:
+    category: process_creation
+detection:
+    selection:
+        CommandLine|contains: 'curl'
+    condition: selection
+`
+	rules, skipped, err := parseSigmaFile("test.yml", []byte(data))
+	if err != nil {
+		t.Fatalf("parseSigmaFile: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Title != "Supported" {
+		t.Fatalf("compiled %d rules, want only Supported", len(rules))
+	}
+
+	want := map[string]string{
+		"CIDR Source":       `unsupported modifier "cidr"`,
+		"Base64 Command":    `unsupported modifier "base64offset"`,
+		"Windows Process":   "unsupported logsource",
+		"Brute Force Count": "unsupported aggregation",
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("skipped %d rules, want %d", len(skipped), len(want))
+	}
+	for _, skip := range skipped {
+		if reason := skip.Reason.Error(); !strings.Contains(reason, want[skip.Title]) {
+			t.Errorf("%s: reason %q, want %q", skip.Title, reason, want[skip.Title])
+		}
+	}
+}
+
+func TestParseSigmaFileErrors(t *testing.T) {
+	// Ошибки в правиле, а не неподдерживаемые возможности, по-прежнему
+	// отклоняют файл
+	data := `title: Broken
+logsource:
+    product: linux
+detection:
+    selection:
+        CommandLine|re: '(['
+    condition: selection
+`
+	if _, _, err := parseSigmaFile("test.yml", []byte(data)); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
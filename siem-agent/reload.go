@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"agent/agent"
+	"agent/collector"
+	"agent/config"
+	"agent/processor"
+	"agent/sender"
+)
+
+// reloadDebounce задержка, за которую схлопываются изменения файла конфигурации
+const reloadDebounce = 500 * time.Millisecond
+
+// activeSource источник и созданные для него сборщики
+type activeSource struct {
+	source     config.Source
+	collectors []collector.Collector
+}
+
+// reloader перечитывает конфигурацию и применяет её к работающему агенту
+type reloader struct {
+	path      string
+	siem      *agent.Agent
+	processor *processor.LogProcessor
+	mu        sync.Mutex
+	current   config.Config
+	sources   map[string]activeSource
+}
+
+// newReloader создаёт reloader для уже загруженной конфигурации
+func newReloader(path string, cfg config.Config, siem *agent.Agent, lp *processor.LogProcessor) *reloader {
+	return &reloader{
+		path:      path,
+		siem:      siem,
+		processor: lp,
+		current:   cfg,
+		sources:   make(map[string]activeSource),
+	}
+}
+
+// applySources приводит набор сборщиков агента в соответствие с конфигурацией.
+// Сборщики неизменённых источников сохраняются вместе с позицией чтения
+func (r *reloader) applySources(sources []config.Source) {
+	wanted := make(map[string]config.Source, len(sources))
+	for _, source := range sources {
+		wanted[source.Name] = source
+	}
+
+	for name, active := range r.sources {
+		source, ok := wanted[name]
+		if ok && reflect.DeepEqual(source, active.source) {
+			continue
+		}
+
+		// Сменился только режим сбора: сохраняем сборщики вместе с позицией
+		// и очередью событий. Прежний цикл сбора останавливается до
+		// повторной регистрации, сборщик не закрывается
+		sameExceptMode := source
+		sameExceptMode.Mode = active.source.Mode
+		if ok && reflect.DeepEqual(sameExceptMode, active.source) {
+			for _, col := range active.collectors {
+				r.siem.DetachCollector(col)
+				r.siem.ReattachCollector(col, source.Mode == config.ModeWatch)
+			}
+			r.sources[name] = activeSource{source: source, collectors: active.collectors}
+			continue
+		}
+
+		for _, col := range active.collectors {
+			r.siem.UnregisterCollector(col)
+		}
+		delete(r.sources, name)
+	}
+
+	for _, source := range sources {
+		if _, ok := r.sources[source.Name]; ok {
+			continue
+		}
+		collectors, err := newCollectors(source, r.current.Agent.StateDir)
+		if err != nil {
+			log.Printf("[Main] Skipping source %s: %v", source.Name, err)
+			continue
+		}
+		r.register(source, collectors)
+	}
+}
+
+// register регистрирует сборщики в агенте в режиме, заданном для источника
+func (r *reloader) register(source config.Source, collectors []collector.Collector) {
+	for _, col := range collectors {
+		if source.Mode == config.ModeWatch {
+			r.siem.WatchCollector(col)
+		} else {
+			r.siem.RegisterCollector(col)
+		}
+	}
+	r.sources[source.Name] = activeSource{source: source, collectors: collectors}
+}
+
+// reload перечитывает файл конфигурации. При ошибке продолжает работать со старой конфигурацией
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("[Main] Reloading configuration from %s", r.path)
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		log.Printf("[Main] Reload failed, keeping current configuration: %v", err)
+		return
+	}
+
+	if cfg.Server != r.current.Server {
+		log.Printf("[Main] Server changed: %s:%d -> %s:%d",
+			r.current.Server.Host, r.current.Server.Port, cfg.Server.Host, cfg.Server.Port)
+		r.siem.SetSender(sender.NewTCPSender(cfg.Server.Host, cfg.Server.Port))
+	}
+
+	if cfg.Agent.StateDir != r.current.Agent.StateDir {
+		log.Printf("[Main] State dir change (%s -> %s) requires restart, ignoring", r.current.Agent.StateDir, cfg.Agent.StateDir)
+	}
+
+	if cfg.Agent.Timezone != r.current.Agent.Timezone {
+		log.Printf("[Main] Timezone changed: %q -> %q", r.current.Agent.Timezone, cfg.Agent.Timezone)
+		applyTimezone(cfg)
+	}
+
+	// Правила перечитываем всегда: файлы в каталоге могли измениться
+	if err := r.processor.LoadRules(cfg.Processor.RulesDir); err != nil {
+		log.Printf("[Main] Keeping current rules: %v", err)
+	}
+
+	r.siem.Reconfigure(agentConfig(cfg))
+	r.applySources(cfg.Logging.Sources)
+	r.current = cfg
+
+	log.Println("[Main] Configuration reloaded")
+}
+
+// reloadRules перечитывает каталог правил. При ошибке остаются прежние правила
+func (r *reloader) reloadRules() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("[Main] Reloading rules from %s", r.current.Processor.RulesDir)
+	if err := r.processor.LoadRules(r.current.Processor.RulesDir); err != nil {
+		log.Printf("[Main] Keeping current rules: %v", err)
+	}
+}
+
+// watch следит за файлом конфигурации и каталогом правил и перечитывает их
+// при изменении. Наблюдаем за каталогом, так как редакторы часто заменяют файл целиком
+func (r *reloader) watch(done <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	configPath := filepath.Clean(r.path)
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	// Каталог правил, заданный при запуске; его смена в конфигурации
+	// применяется при перечитывании, но наблюдение остаётся за прежним
+	rulesDir := filepath.Clean(r.current.Processor.RulesDir)
+	watchRules := r.current.Processor.RulesDir != ""
+	if watchRules && rulesDir != filepath.Dir(configPath) {
+		if err := watcher.Add(rulesDir); err != nil {
+			log.Printf("[Main] Rules dir watch disabled: %v", err)
+			watchRules = false
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer, rulesTimer *time.Timer
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				if rulesTimer != nil {
+					rulesTimer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) && !event.Has(fsnotify.Remove) {
+					continue
+				}
+				name := filepath.Clean(event.Name)
+				if name != configPath {
+					ext := filepath.Ext(name)
+					if watchRules && filepath.Dir(name) == rulesDir && (ext == ".yaml" || ext == ".yml") {
+						if rulesTimer != nil {
+							rulesTimer.Stop()
+						}
+						rulesTimer = time.AfterFunc(reloadDebounce, r.reloadRules)
+					}
+					continue
+				}
+				if event.Has(fsnotify.Remove) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, r.reload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[Main] Config watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// agentConfig строит конфигурацию агента из конфигурации файла
+func agentConfig(cfg config.Config) agent.Config {
+	return agent.Config{
+		AgentID:            cfg.Agent.ID,
+		ServerHost:         cfg.Server.Host,
+		ServerPort:         cfg.Server.Port,
+		CollectionInterval: cfg.Logging.CollectionInterval,
+		SenderInterval:     cfg.Logging.SendInterval,
+		BatchSize:          cfg.Logging.BatchSize,
+		BufferMaxSize:      cfg.Logging.BufferMaxSize,
+	}
+}
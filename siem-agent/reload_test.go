@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"agent/agent"
+	"agent/buffer"
+	"agent/checkpoint"
+	"agent/config"
+	"agent/processor"
+	"agent/sender"
+)
+
+// recordingSender запоминает отправленные события, пока не переведён в режим ошибки
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []string
+	fail bool
+}
+
+func (rs *recordingSender) Send(events []sender.Event) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.fail {
+		return fmt.Errorf("server unavailable")
+	}
+	for _, event := range events {
+		rs.sent = append(rs.sent, event.RawLog)
+	}
+	return nil
+}
+
+func (rs *recordingSender) IsConnected() bool { return true }
+func (rs *recordingSender) Close() error      { return nil }
+
+func (rs *recordingSender) setFail(fail bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.fail = fail
+}
+
+func (rs *recordingSender) sentLines() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]string(nil), rs.sent...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func appendLine(t *testing.T, path, line string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModeSwitchDoesNotDuplicateEvents(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "auth.log")
+	appendLine(t, logPath, "Mar  1 12:00:00 host sshd[100]: Accepted publickey for alice from 192.0.2.10 port 50022 ssh2")
+
+	store, err := checkpoint.Open(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{}
+	cfg.Agent.StateDir = filepath.Join(dir, "state")
+	cfg.Logging = config.LoggingConfig{CollectionInterval: 20, SendInterval: 20, BatchSize: 100, BufferMaxSize: 1000}
+
+	snd := &recordingSender{}
+	siem := agent.NewAgent(agentConfig(cfg), buffer.NewRingBuffer(1000), processor.NewLogProcessor(), snd)
+	siem.SetCheckpointStore(store)
+	if err := siem.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer siem.Stop()
+
+	r := newReloader("", cfg, siem, processor.NewLogProcessor())
+	source := config.Source{Name: "authlog", Path: logPath, Mode: config.ModePoll}
+	r.applySources([]config.Source{source})
+	waitFor(t, "first event to be sent", func() bool { return len(snd.sentLines()) == 1 })
+
+	// Вторая строка собрана, но не отправлена: позиция сохранена только для первой
+	snd.setFail(true)
+	appendLine(t, logPath, "Mar  1 12:00:01 host sshd[101]: Failed password for root from 203.0.113.7 port 40000 ssh2")
+	waitFor(t, "second event to be collected", func() bool { return siem.GetBufferSize() == 1 })
+
+	source.Mode = config.ModeWatch
+	r.applySources([]config.Source{source})
+	time.Sleep(100 * time.Millisecond)
+
+	snd.setFail(false)
+	waitFor(t, "second event to be sent", func() bool { return siem.GetBufferSize() == 0 && len(snd.sentLines()) >= 2 })
+	time.Sleep(100 * time.Millisecond)
+	if sent := snd.sentLines(); len(sent) != 2 {
+		t.Fatalf("sent %d events after mode switch, want 2: %q", len(sent), sent)
+	}
+}
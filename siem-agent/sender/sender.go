@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,6 +23,19 @@ type Event struct {
 	Process   string `json:"process,omitempty"`
 	Command   string `json:"command,omitempty"`
 	RawLog    string `json:"raw_log"`
+
+	// Fields дополнительные поля, извлечённые из записи (pid, src_ip, tty и т.д.)
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// RuleHits сработавшие правила обнаружения
+	RuleHits []RuleHit `json:"rule_hits,omitempty"`
+}
+
+// RuleHit сработавшее на событии правило
+type RuleHit struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	EventType string `json:"event_type"`
 }
 
 // Response ответ от сервера
@@ -123,7 +137,7 @@ func (ts *TCPSender) Close() error {
 
 // connect подключается к серверу
 func (ts *TCPSender) connect() error {
-	addr := fmt.Sprintf("%s:%d", ts.host, ts. port)
+	addr := net.JoinHostPort(ts.host, strconv.Itoa(ts.port))
 	log.Printf("[Sender] Connecting to server at %s", addr)
 	conn, err := net. DialTimeout("tcp", addr, ts.timeout)
 	if err != nil {
@@ -137,7 +151,7 @@ func (ts *TCPSender) connect() error {
 
 // checkConnection проверяет, возможно ли подключиться к серверу
 func (ts *TCPSender) checkConnection() bool {
-	addr := fmt.Sprintf("%s:%d", ts.host, ts. port)
+	addr := net.JoinHostPort(ts.host, strconv.Itoa(ts.port))
 	conn, err := net.DialTimeout("tcp", addr, ts.timeout)
 	if err != nil {
 		log.Printf("[Sender] Server check failed: %v", err)